@@ -0,0 +1,127 @@
+package log
+
+import (
+	"strconv"
+)
+
+// Array is a builder of heterogeneous JSON arrays, it is added to an event by Event.Array, e.g.
+//
+//	log.Info().Array("items", log.Arr().Str("a").Int64(1).Dict(log.Dict().Bool("ok", true))).Msg("")
+//
+// It shares the pooled buffers of events, so it does not allocate in the steady state.
+type Array Event
+
+// Arr starts a new array builder, it is released by Event.Array.
+func Arr() *Array {
+	e := epool.Get().(*Event)
+	e.buf = e.buf[:0]
+	e.escapes = &escapes
+	e.ecs = false
+	e.names = nil
+	e.maxiface = 0
+	return (*Array)(e)
+}
+
+// Array adds the field key with the elements of a as a JSON array to the event,
+// a is started by Arr and is released after this call. A nil a is added as [].
+func (e *Event) Array(key string, a *Array) *Event {
+	if e == nil {
+		a.release()
+		return nil
+	}
+	e.key(key)
+	e.buf = append(e.buf, '[')
+	if a != nil && len(a.buf) > 0 {
+		// skip the leading comma of the first element.
+		e.buf = append(e.buf, a.buf[1:]...)
+	}
+	e.buf = append(e.buf, ']')
+	a.release()
+	return e
+}
+
+// Str appends s as a string to the array.
+func (a *Array) Str(s string) *Array {
+	if a == nil {
+		return nil
+	}
+	a.buf = append(a.buf, ',')
+	(*Event)(a).string(s)
+	return a
+}
+
+// Bytes appends b as a string to the array.
+func (a *Array) Bytes(b []byte) *Array {
+	if a == nil {
+		return nil
+	}
+	a.buf = append(a.buf, ',')
+	(*Event)(a).bytes(b)
+	return a
+}
+
+// Bool appends b as a bool to the array.
+func (a *Array) Bool(b bool) *Array {
+	if a == nil {
+		return nil
+	}
+	a.buf = append(a.buf, ',')
+	a.buf = strconv.AppendBool(a.buf, b)
+	return a
+}
+
+// Int appends i as a int to the array.
+func (a *Array) Int(i int) *Array {
+	return a.Int64(int64(i))
+}
+
+// Int64 appends i as a int64 to the array.
+func (a *Array) Int64(i int64) *Array {
+	if a == nil {
+		return nil
+	}
+	a.buf = append(a.buf, ',')
+	a.buf = strconv.AppendInt(a.buf, i, 10)
+	return a
+}
+
+// Uint64 appends i as a uint64 to the array.
+func (a *Array) Uint64(i uint64) *Array {
+	if a == nil {
+		return nil
+	}
+	a.buf = append(a.buf, ',')
+	a.buf = strconv.AppendUint(a.buf, i, 10)
+	return a
+}
+
+// Float64 appends f as a float64 to the array.
+func (a *Array) Float64(f float64) *Array {
+	if a == nil {
+		return nil
+	}
+	a.buf = append(a.buf, ',')
+	(*Event)(a).float(f, 64)
+	return a
+}
+
+// Dict appends the fields of dict as an object to the array, dict is started by
+// Dict and is released after this call.
+func (a *Array) Dict(dict *Event) *Array {
+	if a == nil {
+		if dict != nil {
+			eventPut(dict)
+		}
+		return nil
+	}
+	e := (*Event)(a)
+	e.buf = append(e.buf, ',')
+	e.dict(dict)
+	return a
+}
+
+func (a *Array) release() {
+	if a != nil {
+		eventPut((*Event)(a))
+	}
+}
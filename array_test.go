@@ -0,0 +1,50 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+func TestLoggerArray(t *testing.T) {
+	cases := []struct {
+		Array *Array
+		JSON  string
+	}{
+		{nil, `"items":[]`},
+		{Arr(), `"items":[]`},
+		{Arr().Str("a").Str("b\"c"), `"items":["a","b\"c"]`},
+		{Arr().Bytes([]byte("a")).Bool(true).Int(-1).Int64(2).Uint64(3).Float64(0.5), `"items":["a",true,-1,2,3,0.5]`},
+		{Arr().Dict(Dict().Str("name", "a")).Dict(nil).Dict(Dict()), `"items":[{"name":"a"},null,{}]`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{Writer: &buf}
+		logger.Info().Array("items", c.Array).Msg("")
+		if !bytes.Contains(buf.Bytes(), []byte(c.JSON)) {
+			t.Errorf("array output %s does not contain %s", buf.Bytes(), c.JSON)
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("array output %s is not valid json", buf.Bytes())
+		}
+	}
+
+	var a *Array
+	if a.Str("a").Int(1).Dict(Dict()) != nil {
+		t.Errorf("nil array builder should stay nil")
+	}
+
+	logger := Logger{Level: InfoLevel, Writer: ioutil.Discard}
+	logger.Debug().Array("items", Arr().Str("a")).Msg("")
+}
+
+func BenchmarkArray(b *testing.B) {
+	logger := Logger{Writer: ioutil.Discard}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Info().Array("items", Arr().Str("a").Int64(1).Bool(true).Dict(Dict().Str("b", "c"))).Msg("")
+	}
+}
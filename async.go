@@ -0,0 +1,321 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	_ "unsafe"
+)
+
+// AsyncDropPolicy defines how AsyncWriter handles a write when its queue is full.
+type AsyncDropPolicy int
+
+const (
+	// AsyncBlock blocks the write until the queue has room.
+	AsyncBlock AsyncDropPolicy = iota
+	// AsyncDropNewest drops the write.
+	AsyncDropNewest
+	// AsyncDropOldest drops the oldest queued write to make room for the write.
+	AsyncDropOldest
+)
+
+// AsyncWriter is an io.WriteCloser and LevelWriter which queues the writes and
+// writes them to Writer in order by a background goroutine, so a slow Writer does
+// not block the callers unless the queue is full.
+type AsyncWriter struct {
+	// make aligncheck happy
+	dropped uint64
+
+	// Writer specifies the writer of output. It uses os.Stderr in if empty.
+	Writer io.Writer
+
+	// ChannelSize specifies the size of the queue. It uses 4096 in if zero.
+	ChannelSize int
+
+	// DropPolicy specifies how a write is handled when the queue is full. It uses AsyncBlock in if empty.
+	DropPolicy AsyncDropPolicy
+
+	once sync.Once
+	ch   chan asyncEntry
+	done chan struct{}
+}
+
+type asyncEntry struct {
+	level Level
+	b     *bb
+}
+
+// Write implements io.Writer, it copies p into the queue. It must not be called after Close.
+func (w *AsyncWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(NoLevel, p)
+}
+
+// WriteLevel implements LevelWriter, level is passed to Writer if it is a LevelWriter.
+// It must not be called after Close.
+func (w *AsyncWriter) WriteLevel(level Level, p []byte) (n int, err error) {
+	w.once.Do(w.init)
+
+	b := bbpool.Get().(*bb)
+	b.B = append(b.B[:0], p...)
+	entry := asyncEntry{level, b}
+
+	switch w.DropPolicy {
+	case AsyncDropNewest:
+		select {
+		case w.ch <- entry:
+		default:
+			atomic.AddUint64(&w.dropped, 1)
+			bbpool.Put(b)
+		}
+	case AsyncDropOldest:
+		for {
+			select {
+			case w.ch <- entry:
+				return len(p), nil
+			default:
+			}
+			select {
+			case old := <-w.ch:
+				atomic.AddUint64(&w.dropped, 1)
+				bbpool.Put(old.b)
+			default:
+			}
+		}
+	default:
+		w.ch <- entry
+	}
+
+	return len(p), nil
+}
+
+// Dropped returns the number of writes dropped by DropPolicy.
+func (w *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Close implements io.Closer, it drains the queue and closes Writer if it is an io.Closer.
+func (w *AsyncWriter) Close() (err error) {
+	w.once.Do(w.init)
+
+	close(w.ch)
+	<-w.done
+
+	if closer, ok := w.writer().(io.Closer); ok && closer != io.Closer(os.Stderr) {
+		err = closer.Close()
+	}
+	return
+}
+
+// Describe implements WriterDescriber.
+func (w *AsyncWriter) Describe() string {
+	size := w.ChannelSize
+	if size <= 0 {
+		size = 4096
+	}
+	return fmt.Sprintf("AsyncWriter{ChannelSize:%d DropPolicy:%d} -> %s", size, w.DropPolicy, describeWriter(w.Writer))
+}
+
+func (w *AsyncWriter) init() {
+	size := w.ChannelSize
+	if size <= 0 {
+		size = 4096
+	}
+	w.ch = make(chan asyncEntry, size)
+	w.done = make(chan struct{})
+	go w.flush()
+}
+
+func (w *AsyncWriter) flush() {
+	defer close(w.done)
+
+	writer := w.writer()
+	lw, _ := writer.(LevelWriter)
+	for entry := range w.ch {
+		var err error
+		if lw != nil && entry.level != NoLevel {
+			_, err = lw.WriteLevel(entry.level, entry.b.B)
+		} else {
+			_, err = writer.Write(entry.b.B)
+		}
+		if cap(entry.b.B) <= bbcap {
+			bbpool.Put(entry.b)
+		}
+		if err != nil && ErrorHandler != nil {
+			ErrorHandler(fmt.Errorf("log: AsyncWriter write error: %v", err))
+		}
+	}
+}
+
+func (w *AsyncWriter) writer() io.Writer {
+	if w.Writer != nil {
+		return w.Writer
+	}
+	return os.Stderr
+}
+
+// ShardedAsyncWriter is an io.WriteCloser and LevelWriter which queues the writes
+// into several shards and writes them to Writer asynchronously, each shard is drained
+// by its own goroutine which coalesces the queued writes into one write to Writer.
+// If Writer is a LevelWriter, only the writes of the same level are coalesced.
+//
+// The shard of a write is selected by the P of the calling goroutine, so the writes
+// of different goroutines are not ordered globally, and the writes of a goroutine
+// keep their order unless it is migrated to another P. It trades the global order for
+// throughput at very high event rates, where a single queue is contended.
+type ShardedAsyncWriter struct {
+	// Writer specifies the writer of output. It uses os.Stderr in if empty.
+	Writer io.Writer
+
+	// Shards specifies the number of shards. It uses runtime.GOMAXPROCS(0) in if zero.
+	Shards int
+
+	// ChannelSize specifies the queue size of each shard. It uses 1024 in if zero.
+	ChannelSize int
+
+	// BatchSize specifies the maximum size in bytes of a coalesced write. It uses 64KB in if zero.
+	BatchSize int
+
+	once   sync.Once
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	shards []chan asyncEntry
+}
+
+// Write implements io.Writer, it copies p into the queue of a shard. It must not
+// be called after Close.
+func (w *ShardedAsyncWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(NoLevel, p)
+}
+
+// WriteLevel implements LevelWriter, level is passed to Writer if it is a LevelWriter.
+// It must not be called after Close.
+func (w *ShardedAsyncWriter) WriteLevel(level Level, p []byte) (n int, err error) {
+	w.once.Do(w.init)
+
+	b := bbpool.Get().(*bb)
+	b.B = append(b.B[:0], p...)
+
+	i := procPin()
+	procUnpin()
+	w.shards[i%len(w.shards)] <- asyncEntry{level, b}
+
+	return len(p), nil
+}
+
+// Close implements io.Closer, it drains the queues of all shards and closes Writer
+// if it is an io.Closer.
+func (w *ShardedAsyncWriter) Close() (err error) {
+	w.once.Do(w.init)
+
+	for _, ch := range w.shards {
+		close(ch)
+	}
+	w.wg.Wait()
+
+	if closer, ok := w.writer().(io.Closer); ok && closer != io.Closer(os.Stderr) {
+		err = closer.Close()
+	}
+	return
+}
+
+// Describe implements WriterDescriber.
+func (w *ShardedAsyncWriter) Describe() string {
+	shards := w.Shards
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	return fmt.Sprintf("ShardedAsyncWriter{Shards:%d} -> %s", shards, describeWriter(w.Writer))
+}
+
+func (w *ShardedAsyncWriter) init() {
+	shards := w.Shards
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	size := w.ChannelSize
+	if size <= 0 {
+		size = 1024
+	}
+
+	w.shards = make([]chan asyncEntry, shards)
+	for i := range w.shards {
+		w.shards[i] = make(chan asyncEntry, size)
+		w.wg.Add(1)
+		go w.drain(w.shards[i])
+	}
+}
+
+func (w *ShardedAsyncWriter) drain(ch chan asyncEntry) {
+	defer w.wg.Done()
+
+	max := w.BatchSize
+	if max <= 0 {
+		max = 64 * 1024
+	}
+
+	writer := w.writer()
+	lw, _ := writer.(LevelWriter)
+
+	var batch []byte
+	entry, ok := <-ch
+	for ok {
+		level := entry.level
+		batch = append(batch[:0], entry.b.B...)
+		if cap(entry.b.B) <= bbcap {
+			bbpool.Put(entry.b)
+		}
+		ok = false
+	coalesce:
+		for len(batch) < max {
+			select {
+			case entry, ok = <-ch:
+				if !ok {
+					break coalesce
+				}
+				if lw != nil && entry.level != level {
+					// keep the entry of another level for the next batch.
+					break coalesce
+				}
+				batch = append(batch, entry.b.B...)
+				if cap(entry.b.B) <= bbcap {
+					bbpool.Put(entry.b)
+				}
+				ok = false
+			default:
+				break coalesce
+			}
+		}
+
+		var err error
+		w.mu.Lock()
+		if lw != nil && level != NoLevel {
+			_, err = lw.WriteLevel(level, batch)
+		} else {
+			_, err = writer.Write(batch)
+		}
+		w.mu.Unlock()
+		if err != nil && ErrorHandler != nil {
+			ErrorHandler(fmt.Errorf("log: ShardedAsyncWriter write error: %v", err))
+		}
+
+		if !ok {
+			entry, ok = <-ch
+		}
+	}
+}
+
+func (w *ShardedAsyncWriter) writer() io.Writer {
+	if w.Writer != nil {
+		return w.Writer
+	}
+	return os.Stderr
+}
+
+//go:linkname procPin runtime.procPin
+func procPin() int
+
+//go:linkname procUnpin runtime.procUnpin
+func procUnpin()
@@ -0,0 +1,246 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func TestShardedAsyncWriter(t *testing.T) {
+	var buf lockedBuffer
+	w := &ShardedAsyncWriter{
+		Writer:      &buf,
+		Shards:      4,
+		ChannelSize: 8,
+	}
+
+	logger := Logger{
+		Writer: w,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Info().Int("producer", i).Int("seq", j).Msg("hello sharded")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("sharded async writer close error: %+v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(buf.buf.String()), "\n") {
+		i := strings.Index(line, `"producer":`)
+		if i < 0 {
+			t.Fatalf("unexpected sharded async writer line: %s", line)
+		}
+		seen[line[i:]] = true
+	}
+	if len(seen) != 1600 {
+		t.Errorf("sharded async writer got %d distinct lines, want 1600", len(seen))
+	}
+}
+
+func TestShardedAsyncWriterClose(t *testing.T) {
+	var buf bytes.Buffer
+	w := &ShardedAsyncWriter{Writer: &buf}
+
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(w, "line %d\n", i)
+	}
+	w.Close()
+
+	if n := strings.Count(buf.String(), "\n"); n != 10 {
+		t.Errorf("sharded async writer should drain the queue on close, got %d lines", n)
+	}
+}
+
+func TestShardedAsyncWriterLevel(t *testing.T) {
+	var w levelWriter
+	sw := &ShardedAsyncWriter{Writer: &w, Shards: 1}
+
+	logger := Logger{Writer: sw}
+	levels := []Level{WarnLevel, WarnLevel, ErrorLevel, InfoLevel, InfoLevel, WarnLevel}
+	for _, level := range levels {
+		logger.WithLevel(level).Msg("hello sharded level")
+	}
+	sw.Close()
+
+	lines := 0
+	for i, level := range w.levels {
+		if level == NoLevel {
+			t.Fatalf("sharded async writer should pass the level to a LevelWriter, got %v", w.levels)
+		}
+		// a coalesced write must hold the lines of its level only.
+		n := strings.Count(w.lines[i], "\n")
+		if strings.Count(w.lines[i], `"level":"`+level.String()+`"`) != n {
+			t.Errorf("sharded async writer wrote %q by level %s", w.lines[i], level)
+		}
+		lines += n
+	}
+	if lines != len(levels) {
+		t.Errorf("sharded async writer got %d lines, want %d", lines, len(levels))
+	}
+}
+
+type blockingWriter struct {
+	lockedBuffer
+	ready chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.ready
+	return w.lockedBuffer.Write(p)
+}
+
+func TestAsyncWriter(t *testing.T) {
+	var buf lockedBuffer
+	w := &AsyncWriter{
+		Writer:      &buf,
+		ChannelSize: 8,
+	}
+
+	logger := Logger{
+		Writer: w,
+	}
+
+	for i := 0; i < 100; i++ {
+		logger.Info().Int("seq", i).Msg("hello async")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("async writer close error: %+v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.buf.String()), "\n")
+	if len(lines) != 100 {
+		t.Fatalf("async writer got %d lines, want 100", len(lines))
+	}
+	for i, line := range lines {
+		if !strings.Contains(line, `"seq":`+strconv.Itoa(i)+`,`) {
+			t.Errorf("async writer line %d is out of order: %s", i, line)
+		}
+	}
+	if n := w.Dropped(); n != 0 {
+		t.Errorf("async writer with AsyncBlock dropped %d lines", n)
+	}
+}
+
+func TestAsyncWriterDropPolicy(t *testing.T) {
+	cases := []struct {
+		Policy AsyncDropPolicy
+		First  string
+		Last   string
+	}{
+		{AsyncDropNewest, "line 0\n", "line 4\n"},
+		{AsyncDropOldest, "line 0\n", "line 9\n"},
+	}
+
+	for _, c := range cases {
+		bw := &blockingWriter{ready: make(chan struct{})}
+		w := &AsyncWriter{
+			Writer:      bw,
+			ChannelSize: 4,
+			DropPolicy:  c.Policy,
+		}
+
+		// the flusher takes line 0 and blocks on it, the queue holds 4 lines.
+		fmt.Fprintf(w, "line %d\n", 0)
+		for len(w.ch) != 0 {
+			time.Sleep(time.Millisecond)
+		}
+		for i := 1; i < 10; i++ {
+			fmt.Fprintf(w, "line %d\n", i)
+		}
+		close(bw.ready)
+		w.Close()
+
+		out := bw.buf.String()
+		if n := strings.Count(out, "\n"); n != 5 {
+			t.Errorf("async writer policy %d got %d lines, want 5: %q", c.Policy, n, out)
+		}
+		if !strings.HasPrefix(out, c.First) || !strings.HasSuffix(out, c.Last) {
+			t.Errorf("async writer policy %d got unexpected lines: %q", c.Policy, out)
+		}
+		if n := w.Dropped(); n != 5 {
+			t.Errorf("async writer policy %d dropped %d lines, want 5", c.Policy, n)
+		}
+	}
+}
+
+func TestAsyncWriterLevel(t *testing.T) {
+	var w levelWriter
+	aw := &AsyncWriter{Writer: &w}
+
+	logger := Logger{Writer: aw}
+	logger.Warn().Msg("hello async level")
+	aw.Close()
+
+	if len(w.levels) != 1 || w.levels[0] != WarnLevel {
+		t.Errorf("async writer should pass the level to a LevelWriter, got %v", w.levels)
+	}
+}
+
+func BenchmarkAsyncWriter(b *testing.B) {
+	w := &AsyncWriter{Writer: ioutil.Discard}
+	logger := Logger{Writer: w}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info().Str("foo", "bar").Msg("hello world")
+	}
+	w.Close()
+}
+
+func BenchmarkShardedAsyncWriter(b *testing.B) {
+	const producers = 32
+
+	for _, shards := range []int{1, 8} {
+		b.Run(strconv.Itoa(shards), func(b *testing.B) {
+			w := &ShardedAsyncWriter{
+				Writer: ioutil.Discard,
+				Shards: shards,
+			}
+			logger := Logger{Writer: w}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			var wg sync.WaitGroup
+			for i := 0; i < producers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < b.N/producers; j++ {
+						logger.Info().Str("foo", "bar").Msg("hello world")
+					}
+				}()
+			}
+			wg.Wait()
+			w.Close()
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Config is a snapshot of the effective configuration of a Logger, see Logger.Config.
+type Config struct {
+	Level             Level
+	Timestamp         bool
+	TimestampMode     TimestampMode
+	Caller            int
+	CallerSkipFrames  int
+	CallerFullPath    bool
+	CallerPackagePath bool
+	CallerWithFunc    bool
+	TimeField         string
+	LevelField        string
+	MessageField      string
+	CallerField       string
+	ErrorField        string
+	ErrorTypeField    string
+	TraceIDField      string
+	SpanIDField       string
+	TimeFormat        string
+	HostField         string
+	GoroutineField    string
+	PIDField          string
+	ProgramField      string
+	Context           string
+	ValidateJSON      bool
+	RawJSONMode       RawJSONMode
+	MaxInterfaceSize  int
+	EscapeHTML        bool
+	LevelEncoder      LevelEncoder
+	Schema            Schema
+
+	// Writer is the description of the writer chain, e.g.
+	// "KeyedLimiter{Interval:1s Capacity:1024} -> os.Stderr".
+	Writer string
+
+	// LevelCallbacks is the number of callbacks registered by OnLevelChange.
+	LevelCallbacks int
+
+	// Hooks is the number of Hooks.
+	Hooks int
+
+	// Sampler is the description of the sampler, e.g. "BasicSampler{N:10}".
+	Sampler string
+}
+
+// WriterDescriber is the interface implemented by writers that describe their
+// configuration in Logger.Config, a wrapper writer describes its wrapped writer too.
+type WriterDescriber interface {
+	Describe() string
+}
+
+// Config returns a snapshot of the effective configuration of the logger, e.g. to
+// debug why nothing is logged. It is safe against concurrent SetLevel and SetWriter.
+func (l *Logger) Config() Config {
+	c := Config{
+		Level:             l.level(),
+		Timestamp:         l.Timestamp,
+		TimestampMode:     l.TimestampMode,
+		Caller:            l.Caller,
+		CallerSkipFrames:  l.CallerSkipFrames,
+		CallerFullPath:    l.CallerFullPath,
+		CallerPackagePath: l.CallerPackagePath,
+		CallerWithFunc:    l.CallerWithFunc,
+		TimeField:         l.TimeField,
+		LevelField:        l.LevelField,
+		MessageField:      l.MessageField,
+		CallerField:       l.CallerField,
+		ErrorField:        l.ErrorField,
+		ErrorTypeField:    l.ErrorTypeField,
+		TraceIDField:      l.TraceIDField,
+		SpanIDField:       l.SpanIDField,
+		TimeFormat:        l.TimeFormat,
+		HostField:         l.HostField,
+		GoroutineField:    l.GoroutineField,
+		PIDField:          l.PIDField,
+		ProgramField:      l.ProgramField,
+		Context:           string(l.Context),
+		ValidateJSON:      l.ValidateJSON,
+		RawJSONMode:       l.RawJSONMode,
+		MaxInterfaceSize:  l.MaxInterfaceSize,
+		EscapeHTML:        l.EscapeHTML,
+		LevelEncoder:      l.LevelEncoder,
+		Schema:            l.Schema,
+		Writer:            describeWriter(l.writer()),
+		Hooks:             len(l.Hooks),
+	}
+	if l.Sampler != nil {
+		c.Sampler = describeSampler(l.Sampler)
+	}
+	if c.TimeField == "" {
+		c.TimeField = "time"
+	}
+	if c.LevelField == "" {
+		c.LevelField = "level"
+	}
+	if c.MessageField == "" {
+		c.MessageField = "message"
+	}
+	if c.CallerField == "" {
+		c.CallerField = "caller"
+	}
+	if c.ErrorField == "" {
+		c.ErrorField = "error"
+	}
+	if c.TraceIDField == "" {
+		c.TraceIDField = "trace_id"
+	}
+	if c.SpanIDField == "" {
+		c.SpanIDField = "span_id"
+	}
+	if p := atomic.LoadPointer(&l.levelfns); p != nil {
+		n := (*levelNotifier)(p)
+		n.mu.Lock()
+		c.LevelCallbacks = len(n.fns)
+		n.mu.Unlock()
+	}
+	return c
+}
+
+// String returns the effective configuration of the logger in a line.
+func (l *Logger) String() string {
+	c := l.Config()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Logger{Level:%s", c.Level)
+	if c.Timestamp {
+		fmt.Fprintf(&b, " Timestamp:true TimestampMode:%d", c.TimestampMode)
+	} else {
+		fmt.Fprintf(&b, " TimeField:%q TimeFormat:%q", c.TimeField, c.TimeFormat)
+	}
+	if c.LevelField != "level" {
+		fmt.Fprintf(&b, " LevelField:%q", c.LevelField)
+	}
+	if c.MessageField != "message" {
+		fmt.Fprintf(&b, " MessageField:%q", c.MessageField)
+	}
+	if c.Caller != 0 {
+		fmt.Fprintf(&b, " Caller:%d", c.Caller)
+	}
+	if c.CallerSkipFrames != 0 {
+		fmt.Fprintf(&b, " CallerSkipFrames:%d", c.CallerSkipFrames)
+	}
+	if c.CallerFullPath {
+		b.WriteString(" CallerFullPath:true")
+	}
+	if c.CallerPackagePath {
+		b.WriteString(" CallerPackagePath:true")
+	}
+	if c.CallerWithFunc {
+		b.WriteString(" CallerWithFunc:true")
+	}
+	if c.CallerField != "caller" {
+		fmt.Fprintf(&b, " CallerField:%q", c.CallerField)
+	}
+	if c.ErrorField != "error" {
+		fmt.Fprintf(&b, " ErrorField:%q", c.ErrorField)
+	}
+	if c.ErrorTypeField != "" {
+		fmt.Fprintf(&b, " ErrorTypeField:%q", c.ErrorTypeField)
+	}
+	if c.TraceIDField != "trace_id" {
+		fmt.Fprintf(&b, " TraceIDField:%q", c.TraceIDField)
+	}
+	if c.SpanIDField != "span_id" {
+		fmt.Fprintf(&b, " SpanIDField:%q", c.SpanIDField)
+	}
+	if c.HostField != "" {
+		fmt.Fprintf(&b, " HostField:%q", c.HostField)
+	}
+	if c.PIDField != "" {
+		fmt.Fprintf(&b, " PIDField:%q", c.PIDField)
+	}
+	if c.ProgramField != "" {
+		fmt.Fprintf(&b, " ProgramField:%q", c.ProgramField)
+	}
+	if c.GoroutineField != "" {
+		fmt.Fprintf(&b, " GoroutineField:%q", c.GoroutineField)
+	}
+	if c.Context != "" {
+		fmt.Fprintf(&b, " Context:%s", c.Context)
+	}
+	if c.ValidateJSON {
+		b.WriteString(" ValidateJSON:true")
+	}
+	if c.RawJSONMode != RawJSONValidate {
+		fmt.Fprintf(&b, " RawJSONMode:%d", c.RawJSONMode)
+	}
+	if c.MaxInterfaceSize != 0 {
+		fmt.Fprintf(&b, " MaxInterfaceSize:%d", c.MaxInterfaceSize)
+	}
+	if c.EscapeHTML {
+		b.WriteString(" EscapeHTML:true")
+	}
+	if c.LevelEncoder != LevelEncoderLowercase {
+		fmt.Fprintf(&b, " LevelEncoder:%d", c.LevelEncoder)
+	}
+	if c.Schema != DefaultSchema {
+		fmt.Fprintf(&b, " Schema:%d", c.Schema)
+	}
+	if c.LevelCallbacks != 0 {
+		fmt.Fprintf(&b, " LevelCallbacks:%d", c.LevelCallbacks)
+	}
+	if c.Hooks != 0 {
+		fmt.Fprintf(&b, " Hooks:%d", c.Hooks)
+	}
+	if c.Sampler != "" {
+		fmt.Fprintf(&b, " Sampler:%s", c.Sampler)
+	}
+	fmt.Fprintf(&b, " Writer:%s}", c.Writer)
+
+	return b.String()
+}
+
+// describeWriter returns the description of w by WriterDescriber, or its type otherwise.
+func describeWriter(w io.Writer) string {
+	switch w := w.(type) {
+	case nil:
+		return "os.Stderr"
+	case WriterDescriber:
+		return w.Describe()
+	case *os.File:
+		switch w {
+		case os.Stderr:
+			return "os.Stderr"
+		case os.Stdout:
+			return "os.Stdout"
+		}
+		return "os.File(" + w.Name() + ")"
+	}
+	return fmt.Sprintf("%T", w)
+}
@@ -0,0 +1,73 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoggerConfig(t *testing.T) {
+	logger := Logger{
+		Level:     InfoLevel,
+		Caller:    1,
+		HostField: "host",
+		Context:   NewContext(nil).Str("service", "api").Value(),
+		Writer: &KeyedLimiter{
+			Interval: time.Second,
+			Writer: &ConsoleWriter{
+				Out: &bytes.Buffer{},
+			},
+		},
+		ValidateJSON: true,
+	}
+	defer logger.OnLevelChange(func(old, new Level) {})()
+
+	c := logger.Config()
+	if c.Level != InfoLevel || c.TimeField != "time" || c.LevelCallbacks != 1 {
+		t.Errorf("unexpected logger config %+v", c)
+	}
+
+	want := `Logger{Level:info TimeField:"time" TimeFormat:"" Caller:1 HostField:"host" Context:,"service":"api" ValidateJSON:true LevelCallbacks:1 ` +
+		`Writer:KeyedLimiter{Interval:1s Capacity:1024} -> ConsoleWriter{ANSIColor:false PrettyJSON:false} -> *bytes.Buffer}`
+	if s := logger.String(); s != want {
+		t.Errorf("logger string got %s, want %s", s, want)
+	}
+
+	for _, c := range []struct {
+		Logger *Logger
+		Writer string
+	}{
+		{&Logger{}, "os.Stderr"},
+		{&Logger{Writer: &FileWriter{Filename: "app.log", MaxBackups: 7}}, `FileWriter{Filename:"app.log" MaxSize:0 MaxBackups:7}`},
+		{&Logger{Writer: &LevelFileWriter{FileWriter: FileWriter{Filename: "app.{level}.log"}}}, `LevelFileWriter{FileWriter{Filename:"app.{level}.log" MaxSize:0 MaxBackups:0}}`},
+		{&Logger{Writer: &ShardedAsyncWriter{Shards: 2, Writer: ioutil.Discard}}, "ShardedAsyncWriter{Shards:2} -> " + describeWriter(ioutil.Discard)},
+		{&Logger{Writer: &HMACWriter{Writer: GzipWriter(&bytes.Buffer{}, 1)}}, "HMACWriter -> GzipWriter{Level:1} -> *bytes.Buffer"},
+	} {
+		if s := c.Logger.Config().Writer; s != c.Writer {
+			t.Errorf("logger config writer got %s, want %s", s, c.Writer)
+		}
+	}
+}
+
+func TestLoggerConfigRace(t *testing.T) {
+	logger := Logger{Writer: ioutil.Discard}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			logger.SetLevel(Level(i%2)*10 + InfoLevel)
+			logger.SetWriter(ioutil.Discard)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_ = logger.String()
+		}
+	}()
+	wg.Wait()
+}
@@ -4,13 +4,182 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+	"unsafe"
 )
 
 // ConsoleWriter parses the JSON input and writes it in an
 // (optionally) colorized, human-friendly format to Out.
 type ConsoleWriter struct {
+	// make 64-bit atomic operations aligned
+	start int64
+	prev  int64
+
+	// ANSIColor determines if the output is colorized. The colors are disabled if the
+	// NO_COLOR environment variable is not empty, see https://no-color.org
 	ANSIColor bool
+
+	// ColorAuto determines if the output is colorized only when Out is a terminal,
+	// it overrides ANSIColor. Out is checked once by the first write.
+	ColorAuto bool
+
+	// ColorScheme specifies the colors of the output. It uses DefaultColorScheme in if nil.
+	ColorScheme *ColorScheme
+
+	// Out specifies the writer of output. It uses os.Stderr in if empty.
+	Out io.Writer
+
+	// TimeMode specifies how the time field is rendered. It uses TimeAbsolute in if empty.
+	TimeMode TimeMode
+
+	// HideTime determines if the time column is omitted.
+	HideTime bool
+
+	// HideLevel determines if the level column is omitted, the message is colorized
+	// by the level instead if ANSIColor is set.
+	HideLevel bool
+
+	// TimeFormat specifies the layout of the time column, the time field is parsed
+	// and formatted by it, e.g. "15:04:05.000". The time field is written as is if
+	// empty or it cannot be parsed. It is ignored unless TimeMode is TimeAbsolute.
+	TimeFormat string
+
+	// HideFields specifies the keys of the fields to omit, e.g. "caller" and "host".
+	// The time and level fields are omitted by HideTime and HideLevel.
+	HideFields []string
+
+	// PrettyJSON determines if the whole event is written as indented JSON below the
+	// header line of time, level, caller and message, instead of key=value fields.
+	// The JSON tokens are colorized by type if ANSIColor is set.
+	PrettyJSON bool
+
+	// Indent specifies the indentation of PrettyJSON output. It uses two spaces in if empty.
+	Indent string
+
+	// PrettyStack determines if the multi-line string values like a stack trace are
+	// written indented below the line of event, instead of key=value fields. The rest
+	// lines of a multi-line message are also written indented. The lines are dimmed
+	// if ANSIColor is set.
+	PrettyStack bool
+
+	// Formatter specifies the renderer of an event parsed into entry, it writes the
+	// event to w which is Out. It takes over all the rendering options above if not nil,
+	// a line which is not a JSON object is still written to Out as is.
+	Formatter func(w io.Writer, entry map[string]interface{}) (int, error)
+
+	mu       sync.Mutex
+	pending  []byte
+	once     sync.Once
+	terminal bool
+}
+
+// TimeMode defines how ConsoleWriter renders the time field.
+type TimeMode int
+
+const (
+	// TimeAbsolute renders the time field as is.
+	TimeAbsolute TimeMode = iota
+	// TimeSinceStart renders the elapsed time since the first line, e.g. +1.234s
+	TimeSinceStart
+	// TimeDelta renders the elapsed time since the previous line, e.g. +0.003s
+	TimeDelta
+)
+
+// ColorScheme defines the colors of ConsoleWriter, a color is an ANSI escape
+// sequence like "\x1b[34m" and the text is not colorized by an empty color.
+// It is not used by the legacy Windows console without virtual terminal processing.
+type ColorScheme struct {
+	// Trace, Debug, Info, Warn, Error, Fatal and Panic specify the colors of the
+	// levels, a custom level uses the color of the nearest lower one.
+	Trace string
+	Debug string
+	Info  string
+	Warn  string
+	Error string
+	Fatal string
+	Panic string
+
+	// Time specifies the color of the time column.
+	Time string
+
+	// Caller specifies the color of the caller column.
+	Caller string
+
+	// Message specifies the color of the ">" before the message.
+	Message string
+
+	// Key specifies the color of the field keys.
+	Key string
+
+	// Value, Number, Bool and Null specify the colors of the field values by type,
+	// Value is used by the strings and the others.
+	Value  string
+	Number string
+	Bool   string
+	Null   string
+
+	// ErrorField specifies the color of the non-null error field.
+	ErrorField string
+}
+
+// DefaultColorScheme is the default colors of ConsoleWriter.
+var DefaultColorScheme = ColorScheme{
+	Trace:      ansiColorMagenta,
+	Debug:      ansiColorYellow,
+	Info:       ansiColorGreen,
+	Warn:       ansiColorRed,
+	Error:      ansiColorRed,
+	Fatal:      ansiColorRed,
+	Panic:      ansiColorRed,
+	Time:       ansiColorDarkGray,
+	Message:    ansiColorCyan,
+	Key:        ansiColorCyan,
+	Value:      ansiColorReset,
+	Number:     ansiColorCyan,
+	Bool:       ansiColorYellow,
+	Null:       ansiColorDarkGray,
+	ErrorField: ansiColorRed,
+}
+
+// level returns the color of level, an unknown level uses the Error color.
+func (cs *ColorScheme) level(level Level) string {
+	switch {
+	case levelAbbr(level) == "???":
+		return cs.Error
+	case level >= PanicLevel:
+		return cs.Panic
+	case level >= FatalLevel:
+		return cs.Fatal
+	case level >= ErrorLevel:
+		return cs.Error
+	case level >= WarnLevel:
+		return cs.Warn
+	case level >= InfoLevel:
+		return cs.Info
+	case level >= DebugLevel:
+		return cs.Debug
+	}
+	return cs.Trace
+}
+
+// value returns the color of the field value v by its JSON type.
+func (cs *ColorScheme) value(v interface{}) string {
+	switch v.(type) {
+	case json.Number:
+		return cs.Number
+	case bool:
+		return cs.Bool
+	case nil:
+		return cs.Null
+	}
+	return cs.Value
 }
 
 const (
@@ -18,90 +187,591 @@ const (
 	ansiColorRed      = "\x1b[31m"
 	ansiColorGreen    = "\x1b[32m"
 	ansiColorYellow   = "\x1b[33m"
+	ansiColorBlue     = "\x1b[34m"
+	ansiColorMagenta  = "\x1b[35m"
 	ansiColorCyan     = "\x1b[36m"
 	ansiColorDarkGray = "\x1b[90m"
 )
 
-func (w *ConsoleWriter) write(p []byte) (n int, err error) {
-	var m map[string]interface{}
+func (w *ConsoleWriter) write(p []byte, level Level, parse bool) (n int, err error) {
+	if w.Formatter != nil {
+		return w.format(w.out(), p)
+	}
 
-	decoder := json.NewDecoder(bytes.NewReader(p))
-	decoder.UseNumber()
-	err = decoder.Decode(&m)
-	if err != nil {
-		n, err = os.Stderr.Write(p)
-		return
+	var array [32]consoleField
+	fields, ok := parseConsoleFields(array[:0], p)
+	if !ok {
+		return w.out().Write(p)
 	}
 
 	b := bbpool.Get().(*bb)
 	b.Reset()
 	defer bbpool.Put(b)
 
-	if v, ok := m["time"]; ok {
-		if w.ANSIColor {
-			fmt.Fprintf(b, "%s%s%s ", ansiColorDarkGray, v, ansiColorReset)
-		} else {
-			fmt.Fprintf(b, "%s ", v)
-		}
-	}
+	color, scheme := w.colored(), w.colorScheme()
 
-	if v, ok := m["level"]; ok {
-		var c, s string
-		switch s, _ = v.(string); ParseLevel(s) {
+	var c, s string
+	if v, ok := consoleLookup(fields, "level"); ok {
+		if parse {
+			s, _ = v.(string)
+			level = parseLevel(s)
+		}
+		switch level {
+		case TraceLevel:
+			s = "TRC"
 		case DebugLevel:
-			c, s = ansiColorYellow, "DBG"
+			s = "DBG"
 		case InfoLevel:
-			c, s = ansiColorGreen, "INF"
+			s = "INF"
 		case WarnLevel:
-			c, s = ansiColorRed, "WRN"
+			s = "WRN"
 		case ErrorLevel:
-			c, s = ansiColorRed, "ERR"
+			s = "ERR"
 		case FatalLevel:
-			c, s = ansiColorRed, "FTL"
+			s = "FTL"
 		case PanicLevel:
-			c, s = ansiColorRed, "PNC"
+			s = "PNC"
 		default:
-			c, s = ansiColorRed, "???"
+			s = levelAbbr(level)
+		}
+		c = scheme.level(level)
+	}
+
+	if v, ok := consoleLookup(fields, "time"); ok && !w.HideTime {
+		if w.TimeMode != TimeAbsolute || w.TimeFormat != "" {
+			v = w.formatTime(v)
 		}
-		if w.ANSIColor {
+		if color && scheme.Time != "" {
+			fmt.Fprintf(b, "%s%s%s ", scheme.Time, v, ansiColorReset)
+		} else {
+			fmt.Fprintf(b, "%s ", v)
+		}
+	}
+
+	if s != "" && !w.HideLevel {
+		if color && c != "" {
 			fmt.Fprintf(b, "%s%s%s ", c, s, ansiColorReset)
 		} else {
 			fmt.Fprintf(b, "%s ", s)
 		}
 	}
 
-	if v, ok := m["caller"]; ok {
-		fmt.Fprintf(b, "%s ", v)
+	if v, ok := consoleLookup(fields, "caller"); ok && !w.hidden("caller") {
+		if color && scheme.Caller != "" {
+			fmt.Fprintf(b, "%s%s%s ", scheme.Caller, v, ansiColorReset)
+		} else {
+			fmt.Fprintf(b, "%s ", v)
+		}
 	}
 
-	if v, ok := m["message"]; ok {
+	var rest string
+	if v, ok := consoleLookup(fields, "message"); ok && !w.hidden("message") {
 		if s, _ := v.(string); s != "" && s[len(s)-1] == '\n' {
 			v = s[:len(s)-1]
 		}
-		if w.ANSIColor {
-			fmt.Fprintf(b, "%s>%s %s", ansiColorCyan, ansiColorReset, v)
+		if s, _ := v.(string); w.PrettyStack {
+			if i := strings.IndexByte(s, '\n'); i >= 0 {
+				v, rest = s[:i], s[i+1:]
+			}
+		}
+		if color {
+			if w.HideLevel && c != "" {
+				fmt.Fprintf(b, "%s>%s %s%s%s", scheme.Message, ansiColorReset, c, v, ansiColorReset)
+			} else {
+				fmt.Fprintf(b, "%s>%s %s", scheme.Message, ansiColorReset, v)
+			}
 		} else {
 			fmt.Fprintf(b, "> %s", v)
 		}
 	}
 
-	for k, v := range m {
+	if w.PrettyJSON {
+		if b.B = bytes.TrimRight(b.B, " "); len(b.B) != 0 {
+			b.B = append(b.B, '\n')
+		}
+		if rest != "" {
+			b.B = append(appendConsoleLines(b.B, "  ", rest, color), '\n')
+			rest = ""
+		}
+		b.B = w.appendPrettyJSON(b.B, p, color)
+		fields = nil
+	}
+
+	var stacks []consoleField
+	for _, f := range fields {
+		k, v := f.key, f.value
 		switch k {
 		case "time", "level", "caller", "message":
 			continue
 		}
-		if w.ANSIColor {
+		if w.hidden(k) {
+			continue
+		}
+		if s, _ := v.(string); w.PrettyStack && strings.IndexByte(s, '\n') >= 0 {
+			stacks = append(stacks, f)
+			continue
+		}
+		if len(b.B) != 0 {
+			b.B = append(b.B, ' ')
+		}
+		if color {
 			if k == "error" && v != nil {
-				fmt.Fprintf(b, " %s%s=%v%s", ansiColorRed, k, v, ansiColorReset)
+				fmt.Fprintf(b, "%s%s=%v%s", scheme.ErrorField, k, v, ansiColorReset)
 			} else {
-				fmt.Fprintf(b, " %s%s=%s%v%s", ansiColorCyan, k, ansiColorDarkGray, v, ansiColorReset)
+				fmt.Fprintf(b, "%s%s=%s%v%s", scheme.Key, k, scheme.value(v), v, ansiColorReset)
 			}
 		} else {
-			fmt.Fprintf(b, " %s=%v", k, v)
+			fmt.Fprintf(b, "%s=%v", k, v)
+		}
+	}
+
+	if rest != "" {
+		b.B = appendConsoleLines(b.B, "  ", rest, color)
+	}
+	for _, f := range stacks {
+		if color {
+			fmt.Fprintf(b, "\n  %s%s:%s", scheme.Key, f.key, ansiColorReset)
+		} else {
+			fmt.Fprintf(b, "\n  %s:", f.key)
 		}
+		b.B = appendConsoleLines(b.B, "    ", f.value.(string), color)
 	}
 
 	b.B = append(b.B, '\n')
 
-	return os.Stderr.Write(b.B)
+	return w.out().Write(b.B)
+}
+
+// appendConsoleLines appends each line of s to dst on a new line prefixed by indent,
+// the lines are dimmed if color is set.
+func appendConsoleLines(dst []byte, indent, s string, color bool) []byte {
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		dst = append(dst, '\n')
+		if color {
+			dst = append(dst, ansiColorDarkGray...)
+		}
+		dst = append(dst, indent...)
+		dst = append(dst, strings.TrimSuffix(line, "\r")...)
+		if color {
+			dst = append(dst, ansiColorReset...)
+		}
+	}
+	return dst
+}
+
+// format parses p into an entry and writes it to out by Formatter.
+func (w *ConsoleWriter) format(out io.Writer, p []byte) (n int, err error) {
+	var m map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	if decoder.Decode(&m) != nil {
+		return out.Write(p)
+	}
+	return w.Formatter(out, m)
+}
+
+// Describe implements WriterDescriber.
+func (w *ConsoleWriter) Describe() string {
+	return fmt.Sprintf("ConsoleWriter{ANSIColor:%t PrettyJSON:%t} -> %s", w.ANSIColor, w.PrettyJSON, describeWriter(w.Out))
+}
+
+// consoleMaxPending is the maximum size of an incomplete event buffered by ConsoleWriter.
+var consoleMaxPending = 64 * 1024
+
+// lines calls f with each line of p, the incomplete line of a JSON object is held
+// until the rest of it arrives in next calls, or its size exceeds consoleMaxPending.
+func (w *ConsoleWriter) lines(p []byte, f func(line []byte) (int, error)) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n = len(p)
+	for len(p) != 0 {
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line, p = p[:i+1], p[i+1:]
+		} else {
+			p = nil
+		}
+
+		if len(w.pending) != 0 || line[len(line)-1] != '\n' {
+			w.pending = append(w.pending, line...)
+			line = w.pending
+			if line[len(line)-1] != '\n' && line[0] == '{' && len(line) <= consoleMaxPending && !json.Valid(line) {
+				break
+			}
+		}
+
+		if _, err1 := f(line); err1 != nil && err == nil {
+			err = err1
+		}
+		w.pending = w.pending[:0]
+	}
+
+	return
+}
+
+// colored reports whether the output is colorized by ANSIColor, ColorAuto and the
+// NO_COLOR environment variable.
+func (w *ConsoleWriter) colored() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if w.ColorAuto {
+		w.once.Do(func() {
+			if f, ok := w.out().(*os.File); ok {
+				w.terminal = IsTerminal(f.Fd())
+			}
+		})
+		return w.terminal
+	}
+	return w.ANSIColor
+}
+
+func (w *ConsoleWriter) colorScheme() *ColorScheme {
+	if w.ColorScheme != nil {
+		return w.ColorScheme
+	}
+	return &DefaultColorScheme
+}
+
+func (w *ConsoleWriter) out() io.Writer {
+	if w.Out != nil {
+		return w.Out
+	}
+	return os.Stderr
+}
+
+// AutoWriter returns a colorized ConsoleWriter writing to f if f is a terminal,
+// or f itself otherwise. The LOG_FORMAT environment variable overrides the choice,
+// "json" always returns f and "console" always returns a ConsoleWriter which is
+// only colorized on a terminal.
+func AutoWriter(f *os.File) io.Writer {
+	terminal := IsTerminal(f.Fd())
+	switch os.Getenv("LOG_FORMAT") {
+	case "json":
+		return f
+	case "console":
+		return &ConsoleWriter{ANSIColor: terminal, Out: f}
+	}
+	if terminal {
+		return &ConsoleWriter{ANSIColor: true, Out: f}
+	}
+	return f
+}
+
+// appendPrettyJSON appends the indented JSON p to dst, the tokens are colorized by
+// type if color is set, i.e. keys blue, strings green, numbers cyan, booleans
+// yellow and null dark gray.
+func (w *ConsoleWriter) appendPrettyJSON(dst, p []byte, color bool) []byte {
+	indent := w.Indent
+	if indent == "" {
+		indent = "  "
+	}
+
+	var buf bytes.Buffer
+	p = bytes.TrimSpace(p)
+	if json.Indent(&buf, p, "", indent) != nil {
+		return append(dst, p...)
+	}
+	p = buf.Bytes()
+
+	if !color {
+		return append(dst, p...)
+	}
+
+	for i := 0; i < len(p); {
+		var j int
+		var color string
+		switch c := p[i]; {
+		case c == '"':
+			for j = i + 1; j < len(p) && p[j] != '"'; j++ {
+				if p[j] == '\\' {
+					j++
+				}
+			}
+			if j++; j < len(p) && p[j] == ':' {
+				color = ansiColorBlue
+			} else {
+				color = ansiColorGreen
+			}
+		case c == '-' || c >= '0' && c <= '9':
+			for j = i + 1; j < len(p) && strings.IndexByte("+-.eE0123456789", p[j]) >= 0; j++ {
+			}
+			color = ansiColorCyan
+		case c == 't' || c == 'f':
+			for j = i + 1; j < len(p) && p[j] >= 'a' && p[j] <= 'z'; j++ {
+			}
+			color = ansiColorYellow
+		case c == 'n':
+			for j = i + 1; j < len(p) && p[j] >= 'a' && p[j] <= 'z'; j++ {
+			}
+			color = ansiColorDarkGray
+		default:
+			dst = append(dst, c)
+			i++
+			continue
+		}
+		dst = append(dst, color...)
+		dst = append(dst, p[i:j]...)
+		dst = append(dst, ansiColorReset...)
+		i = j
+	}
+
+	return dst
+}
+
+// hidden reports whether the field key is omitted by HideFields.
+func (w *ConsoleWriter) hidden(key string) bool {
+	for _, k := range w.HideFields {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// consoleField is a top level field of a JSON line parsed by parseConsoleFields.
+type consoleField struct {
+	key   string
+	value interface{}
+}
+
+// consoleLookup returns the value of the last field of key.
+func consoleLookup(fields []consoleField, key string) (interface{}, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].key == key {
+			return fields[i].value, true
+		}
+	}
+	return nil, false
+}
+
+// parseConsoleFields appends the top level fields of the JSON object p to fields in
+// order of appearance, it returns false if p is not a JSON object. The values are
+// string, json.Number, bool or nil, an object or array value is kept as its JSON string.
+func parseConsoleFields(fields []consoleField, p []byte) ([]consoleField, bool) {
+	i := skipSpace(p, 0)
+	if i >= len(p) || p[i] != '{' {
+		return fields, false
+	}
+	if i = skipSpace(p, i+1); i < len(p) && p[i] == '}' {
+		return fields, true
+	}
+	for {
+		if i >= len(p) || p[i] != '"' {
+			return fields, false
+		}
+		j := scanValue(p, i)
+		if j < 0 {
+			return fields, false
+		}
+		key, ok := consoleValue(p[i:j])
+		if !ok {
+			return fields, false
+		}
+		if i = skipSpace(p, j); i >= len(p) || p[i] != ':' {
+			return fields, false
+		}
+		i = skipSpace(p, i+1)
+		if j = scanValue(p, i); j < 0 {
+			return fields, false
+		}
+		value, ok := consoleValue(p[i:j])
+		if !ok {
+			return fields, false
+		}
+		fields = append(fields, consoleField{key.(string), value})
+		if i = skipSpace(p, j); i >= len(p) {
+			return fields, false
+		}
+		switch p[i] {
+		case ',':
+			i = skipSpace(p, i+1)
+		case '}':
+			return fields, true
+		default:
+			return fields, false
+		}
+	}
+}
+
+func skipSpace(p []byte, i int) int {
+	for i < len(p) && (p[i] == ' ' || p[i] == '\t' || p[i] == '\r' || p[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+// scanValue returns the end of the JSON value starting at p[i], or -1 if it is incomplete.
+func scanValue(p []byte, i int) int {
+	if i >= len(p) {
+		return -1
+	}
+	depth := 0
+	for j := i; j < len(p); j++ {
+		switch p[j] {
+		case '"':
+			for j++; j < len(p) && p[j] != '"'; j++ {
+				if p[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(p) {
+				return -1
+			}
+			if depth == 0 {
+				return j + 1
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return j
+			}
+			if depth--; depth == 0 {
+				return j + 1
+			}
+		case ',', ' ', '\t', '\r', '\n':
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	if depth != 0 {
+		return -1
+	}
+	return len(p)
+}
+
+// consoleValue returns the value of the JSON value b, the strings of the value share
+// the memory of b, so it must not be retained after the line is written.
+func consoleValue(b []byte) (interface{}, bool) {
+	if len(b) == 0 {
+		return nil, false
+	}
+	switch b[0] {
+	case '"':
+		if bytes.IndexByte(b, '\\') < 0 && utf8.Valid(b) {
+			b = b[1 : len(b)-1]
+			return *(*string)(unsafe.Pointer(&b)), true
+		}
+		var s string
+		if json.Unmarshal(b, &s) != nil {
+			return nil, false
+		}
+		return s, true
+	case '{', '[':
+		if !json.Valid(b) {
+			return nil, false
+		}
+		return string(b), true
+	case 't':
+		return true, string(b) == "true"
+	case 'f':
+		return false, string(b) == "false"
+	case 'n':
+		return nil, string(b) == "null"
+	}
+	if b[0] != '-' && (b[0] < '0' || b[0] > '9') {
+		return nil, false
+	}
+	n := *(*string)(unsafe.Pointer(&b))
+	if _, err := strconv.ParseFloat(n, 64); err != nil {
+		return nil, false
+	}
+	return json.Number(n), true
+}
+
+// levelAbbr returns the upper case abbreviation of a custom level registered by
+// RegisterLevel, e.g. "NOT" of "notice", or "???" if the level is unknown.
+func levelAbbr(level Level) string {
+	if level >= Level(len(levelNames)) || levelNames[level] == "" {
+		return "???"
+	}
+	s := strings.ToUpper(levelNames[level])
+	if len(s) > 3 {
+		s = s[:3]
+	}
+	return s
+}
+
+// isCygwinPipeName reports whether name is the pipe name of MSYS2/Cygwin terminals,
+// i.e. \{cygwin,msys}-XXXXXXXXXXXXXXXX-ptyN-{from,to}-master
+func isCygwinPipeName(name string) bool {
+	token := strings.Split(name, "-")
+	if len(token) < 5 {
+		return false
+	}
+
+	switch token[0] {
+	case `\msys`, `\cygwin`, `\Device\NamedPipe\msys`, `\Device\NamedPipe\cygwin`:
+	default:
+		return false
+	}
+
+	return token[1] != "" &&
+		strings.HasPrefix(token[2], "pty") &&
+		(token[3] == "from" || token[3] == "to") &&
+		token[4] == "master"
+}
+
+// formatTime returns the elapsed time of the time field v according to TimeMode,
+// or v formatted by TimeFormat, or v itself if it cannot be parsed.
+func (w *ConsoleWriter) formatTime(v interface{}) interface{} {
+	var t time.Time
+	switch v := v.(type) {
+	case string:
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return v
+		}
+	case json.Number:
+		if strings.Contains(string(v), ".") {
+			f, err := v.Float64()
+			if err != nil {
+				return v
+			}
+			t = time.Unix(0, int64(f*1e9))
+		} else {
+			i, err := v.Int64()
+			if err != nil {
+				return v
+			}
+			// the unit of the timestamp is told by its digits, see TimestampMode.
+			switch n := len(strings.TrimPrefix(string(v), "-")); {
+			case n <= 10:
+				t = time.Unix(i, 0)
+			case n <= 13:
+				t = time.Unix(0, i*int64(time.Millisecond))
+			case n <= 16:
+				t = time.Unix(0, i*int64(time.Microsecond))
+			default:
+				t = time.Unix(0, i)
+			}
+		}
+	default:
+		return v
+	}
+
+	now := t.UnixNano()
+	var base int64
+	switch w.TimeMode {
+	case TimeSinceStart:
+		if !atomic.CompareAndSwapInt64(&w.start, 0, now) {
+			base = atomic.LoadInt64(&w.start)
+		} else {
+			base = now
+		}
+	case TimeDelta:
+		if base = atomic.SwapInt64(&w.prev, now); base == 0 {
+			base = now
+		}
+	default:
+		if w.TimeFormat != "" {
+			return t.Format(w.TimeFormat)
+		}
+		return v
+	}
+
+	return fmt.Sprintf("%+.3fs", time.Duration(now-base).Seconds())
 }
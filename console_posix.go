@@ -8,8 +8,17 @@ import (
 	"unsafe"
 )
 
+// Write implements io.Writer, the newline delimited events in p are rendered
+// separately and an incomplete event is buffered until the rest of it is written.
 func (w *ConsoleWriter) Write(p []byte) (int, error) {
-	return w.write(p)
+	return w.lines(p, func(line []byte) (int, error) {
+		return w.write(line, NoLevel, true)
+	})
+}
+
+// WriteLevel implements LevelWriter, it uses level instead of parsing the level field.
+func (w *ConsoleWriter) WriteLevel(level Level, p []byte) (int, error) {
+	return w.write(p, level, false)
 }
 
 // IsTerminal returns whether the given file descriptor is a terminal.
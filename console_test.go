@@ -1,11 +1,37 @@
 package log
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
+func captureStderr(t *testing.T, f func()) string {
+	file, err := ioutil.TempFile("", "console-")
+	if err != nil {
+		t.Fatalf("create temp file error: %+v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	stderr := os.Stderr
+	os.Stderr = file
+	f()
+	os.Stderr = stderr
+
+	data, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatalf("read temp file error: %+v", err)
+	}
+	return string(data)
+}
+
 func TestConsoleWriter(t *testing.T) {
 	w := &ConsoleWriter{}
 
@@ -17,6 +43,21 @@ func TestConsoleWriter(t *testing.T) {
 	}
 }
 
+func TestConsoleWriterLevel(t *testing.T) {
+	w := &ConsoleWriter{
+		ANSIColor: true,
+	}
+
+	var lw LevelWriter = w
+
+	for _, level := range []Level{TraceLevel, DebugLevel, InfoLevel, WarnLevel, ErrorLevel, FatalLevel, PanicLevel, NoLevel} {
+		_, err := lw.WriteLevel(level, []byte(`{"time":"2019-07-10T05:35:54.277Z","level":"whatever","caller":"test.go:42","foo":"bar","message":"hello json console level writer"}`+"\n"))
+		if err != nil {
+			t.Errorf("test json console level writer error: %+v", err)
+		}
+	}
+}
+
 func TestIsTerminal(t *testing.T) {
 	file, _ := os.Open(os.DevNull)
 	if IsTerminal(file.Fd()) {
@@ -24,6 +65,46 @@ func TestIsTerminal(t *testing.T) {
 	}
 }
 
+func TestIsTerminalPipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe error: %+v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	if IsTerminal(r.Fd()) || IsTerminal(w.Fd()) {
+		t.Errorf("test is terminal mode for pipe failed")
+	}
+}
+
+func TestIsCygwinPipeName(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Terminal bool
+	}{
+		{`\msys-1888ae32e00d56aa-pty0-from-master`, true},
+		{`\msys-1888ae32e00d56aa-pty0-to-master`, true},
+		{`\cygwin-e022582115c10879-pty4-from-master`, true},
+		{`\Device\NamedPipe\msys-1888ae32e00d56aa-pty0-to-master`, true},
+		{`\msys-1888ae32e00d56aa-pty0-from-master-nat`, true},
+		{`\msys--pty0-from-master`, false},
+		{`\msys-1888ae32e00d56aa-tty0-from-master`, false},
+		{`\msys-1888ae32e00d56aa-pty0-upto-master`, false},
+		{`\msys-1888ae32e00d56aa-pty0-from-slave`, false},
+		{`\msys-1888ae32e00d56aa-pty0`, false},
+		{`\mingw-1888ae32e00d56aa-pty0-from-master`, false},
+		{`\Device\NamedPipe\Win32Pipes.000008c4.00000001`, false},
+		{``, false},
+	}
+
+	for _, c := range cases {
+		if got := isCygwinPipeName(c.Name); got != c.Terminal {
+			t.Errorf("isCygwinPipeName(%q) got %v, want %v", c.Name, got, c.Terminal)
+		}
+	}
+}
+
 func TestConsoleWriterColor(t *testing.T) {
 	w := &ConsoleWriter{
 		ANSIColor: true,
@@ -58,3 +139,437 @@ func TestConsoleWriterInvaild(t *testing.T) {
 		t.Errorf("test plain text console writer error: %+v", err)
 	}
 }
+
+func TestConsoleWriterTimeMode(t *testing.T) {
+	cases := []struct {
+		Mode  TimeMode
+		Times []interface{}
+		Wants []interface{}
+	}{
+		{
+			TimeSinceStart,
+			[]interface{}{"2019-07-10T05:35:54.277Z", "2019-07-10T05:35:54.280Z", "2019-07-10T05:35:55.500Z", "invalid", json.Number("1562736956277")},
+			[]interface{}{"+0.000s", "+0.003s", "+1.223s", "invalid", "+2.000s"},
+		},
+		{
+			TimeDelta,
+			[]interface{}{json.Number("1562736954"), json.Number("1562736955.250"), "2019-07-10T05:35:55.253Z", true},
+			[]interface{}{"+0.000s", "+1.250s", "+0.003s", true},
+		},
+		{
+			TimeDelta,
+			[]interface{}{json.Number("1562736954277123"), json.Number("1562736954527123"), json.Number("1562736955527123456"), json.Number("1562736956527")},
+			[]interface{}{"+0.000s", "+0.250s", "+1.000s", "+1.000s"},
+		},
+		{
+			TimeAbsolute,
+			[]interface{}{"2019-07-10T05:35:54.277Z"},
+			[]interface{}{"2019-07-10T05:35:54.277Z"},
+		},
+	}
+
+	for _, c := range cases {
+		w := &ConsoleWriter{TimeMode: c.Mode}
+		for i := range c.Times {
+			if v := w.formatTime(c.Times[i]); v != c.Wants[i] {
+				t.Errorf("console writer time mode %v got %v for %v, want %v", c.Mode, v, c.Times[i], c.Wants[i])
+			}
+		}
+	}
+
+	// the micro and nano seconds timestamps are formatted by TimeFormat.
+	for _, v := range []json.Number{"1562736954", "1562736954277", "1562736954277123", "1562736954277123456"} {
+		w := &ConsoleWriter{TimeFormat: "2006-01-02T15:04:05Z07:00"}
+		if s := w.formatTime(v); s != time.Unix(1562736954, 0).Format(w.TimeFormat) {
+			t.Errorf("console writer time format got %v for %v", s, v)
+		}
+	}
+
+	w := &ConsoleWriter{
+		ANSIColor: true,
+		TimeMode:  TimeDelta,
+	}
+	for i := 0; i < 3; i++ {
+		_, err := fmt.Fprintf(w, `{"time":"2019-07-10T05:35:54.%03dZ","level":"info","caller":"pretty.go:42","foo":"bar","message":"hello console delta time"}`+"\n", i*100)
+		if err != nil {
+			t.Errorf("test json console writer time mode error: %+v", err)
+		}
+	}
+}
+
+func TestConsoleWriterHide(t *testing.T) {
+	line := `{"time":"2019-07-10T05:35:54.277Z","level":"error","foo":"bar","message":"hello console hide"}` + "\n"
+
+	cases := []struct {
+		Writer *ConsoleWriter
+		Output string
+	}{
+		{&ConsoleWriter{HideTime: true}, "ERR > hello console hide foo=bar\n"},
+		{&ConsoleWriter{HideLevel: true}, "2019-07-10T05:35:54.277Z > hello console hide foo=bar\n"},
+		{&ConsoleWriter{HideTime: true, HideLevel: true}, "> hello console hide foo=bar\n"},
+		{&ConsoleWriter{HideTime: true, HideLevel: true, ANSIColor: true}, "\x1b[36m>\x1b[0m \x1b[31mhello console hide\x1b[0m \x1b[36mfoo=\x1b[0mbar\x1b[0m\n"},
+	}
+
+	for _, c := range cases {
+		output := captureStderr(t, func() {
+			fmt.Fprint(c.Writer, line)
+		})
+		if output != c.Output {
+			t.Errorf("console writer hide output %q, want %q", output, c.Output)
+		}
+	}
+
+	output := captureStderr(t, func() {
+		fmt.Fprint(&ConsoleWriter{HideTime: true, HideLevel: true}, `{"time":"2019-07-10T05:35:54.277Z","level":"info","foo":"bar"}`+"\n")
+	})
+	if strings.HasPrefix(output, " ") {
+		t.Errorf("console writer hide output %q has leading space", output)
+	}
+}
+
+func TestConsoleWriterValueColor(t *testing.T) {
+	line := `{"level":"info","str":"bar","num":42,"float":1.5,"yes":true,"no":false,"nil":null,"message":"hello"}` + "\n"
+
+	output := captureStderr(t, func() {
+		fmt.Fprint(&ConsoleWriter{ANSIColor: true}, line)
+	})
+	for _, s := range []string{
+		"\x1b[36mstr=\x1b[0mbar\x1b[0m",
+		"\x1b[36mnum=\x1b[36m42\x1b[0m",
+		"\x1b[36mfloat=\x1b[36m1.5\x1b[0m",
+		"\x1b[36myes=\x1b[33mtrue\x1b[0m",
+		"\x1b[36mno=\x1b[33mfalse\x1b[0m",
+		"\x1b[36mnil=\x1b[90m<nil>\x1b[0m",
+	} {
+		if !strings.Contains(output, s) {
+			t.Errorf("console writer value color output %q does not contain %q", output, s)
+		}
+	}
+
+	output = captureStderr(t, func() {
+		fmt.Fprint(&ConsoleWriter{}, line)
+	})
+	if strings.Contains(output, "\x1b[") {
+		t.Errorf("console writer without color output %q contains color", output)
+	}
+}
+
+func TestConsoleWriterOut(t *testing.T) {
+	var buf bytes.Buffer
+	w := &ConsoleWriter{Out: &buf}
+
+	fmt.Fprint(w, `{"time":"2019-07-10T05:35:54.277Z","level":"info","foo":"bar","message":"hello console out"}`+"\n")
+	if s := buf.String(); s != "2019-07-10T05:35:54.277Z INF > hello console out foo=bar\n" {
+		t.Errorf("console writer out got %q", s)
+	}
+
+	buf.Reset()
+	fmt.Fprint(w, "not a json line\n")
+	if s := buf.String(); s != "not a json line\n" {
+		t.Errorf("console writer out got %q", s)
+	}
+}
+
+func TestConsoleWriterPrettyJSON(t *testing.T) {
+	var buf bytes.Buffer
+	w := &ConsoleWriter{Out: &buf, PrettyJSON: true, Indent: "\t"}
+
+	line := `{"time":"2019-07-10T05:35:54.277Z","level":"info","n":[1,-2.5e3],"ok":true,"v":null,"message":"hello"}` + "\n"
+	fmt.Fprint(w, line)
+	want := "2019-07-10T05:35:54.277Z INF > hello\n{\n\t\"time\": \"2019-07-10T05:35:54.277Z\",\n\t\"level\": \"info\",\n\t\"n\": [\n\t\t1,\n\t\t-2.5e3\n\t],\n\t\"ok\": true,\n\t\"v\": null,\n\t\"message\": \"hello\"\n}\n"
+	if s := buf.String(); s != want {
+		t.Errorf("pretty json got %q, want %q", s, want)
+	}
+
+	buf.Reset()
+	w.ANSIColor = true
+	w.HideTime = true
+	fmt.Fprint(w, `{"level":"warn","a":{"b\"c":"d:"},"x":false}`+"\n")
+	want = "\x1b[31mWRN\x1b[0m\n{\n\t\x1b[34m\"level\"\x1b[0m: \x1b[32m\"warn\"\x1b[0m,\n\t\x1b[34m\"a\"\x1b[0m: {\n\t\t\x1b[34m\"b\\\"c\"\x1b[0m: \x1b[32m\"d:\"\x1b[0m\n\t},\n\t\x1b[34m\"x\"\x1b[0m: \x1b[33mfalse\x1b[0m\n}\n"
+	if s := buf.String(); s != want {
+		t.Errorf("pretty json got %q, want %q", s, want)
+	}
+
+	buf.Reset()
+	fmt.Fprint(w, "not a json line\n")
+	if s := buf.String(); s != "not a json line\n" {
+		t.Errorf("pretty json of invalid json got %q", s)
+	}
+}
+
+func TestConsoleWriterLines(t *testing.T) {
+	var buf bytes.Buffer
+	w := &ConsoleWriter{Out: &buf, HideTime: true}
+
+	line := `{"level":"info","foo":"bar","message":"hello split"}` + "\n"
+	fmt.Fprint(w, line[:10])
+	fmt.Fprint(w, line[10:30])
+	if buf.Len() != 0 {
+		t.Errorf("console writer should hold the incomplete event, got %q", buf.String())
+	}
+	fmt.Fprint(w, line[30:])
+	if s := buf.String(); s != "INF > hello split foo=bar\n" {
+		t.Errorf("console writer split event got %q", s)
+	}
+
+	buf.Reset()
+	fmt.Fprint(w, `{"level":"info","message":"one"}`+"\n"+`{"level":"warn","message":"two"}`+"\n")
+	if s := buf.String(); s != "INF > one\nWRN > two\n" {
+		t.Errorf("console writer batched events got %q", s)
+	}
+
+	buf.Reset()
+	fmt.Fprint(w, `{"level":"info","message":"complete"}`)
+	fmt.Fprint(w, "plain text")
+	if s := buf.String(); s != "INF > complete\nplain text" {
+		t.Errorf("console writer complete event without newline got %q", s)
+	}
+
+	defer func(n int) { consoleMaxPending = n }(consoleMaxPending)
+	consoleMaxPending = 16
+
+	buf.Reset()
+	fmt.Fprint(w, `{"level":"info","message":"garbage`)
+	if s := buf.String(); s != `{"level":"info","message":"garbage` {
+		t.Errorf("console writer should flush the oversize incomplete event, got %q", s)
+	}
+}
+
+func TestAutoWriter(t *testing.T) {
+	file, err := ioutil.TempFile("", "autowriter")
+	if err != nil {
+		t.Fatalf("create temp file error: %+v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	defer os.Setenv("LOG_FORMAT", os.Getenv("LOG_FORMAT"))
+
+	os.Setenv("LOG_FORMAT", "")
+	if w := AutoWriter(file); w != io.Writer(file) {
+		t.Errorf("AutoWriter of a regular file should return the file, got %T", w)
+	}
+
+	os.Setenv("LOG_FORMAT", "json")
+	if w := AutoWriter(file); w != io.Writer(file) {
+		t.Errorf("AutoWriter with LOG_FORMAT=json should return the file, got %T", w)
+	}
+
+	os.Setenv("LOG_FORMAT", "console")
+	if w, ok := AutoWriter(file).(*ConsoleWriter); !ok || w.ANSIColor || w.Out != io.Writer(file) {
+		t.Errorf("AutoWriter with LOG_FORMAT=console should return a ConsoleWriter without color, got %+v", w)
+	}
+
+	if l := NewDefault(); l.Writer == nil {
+		t.Errorf("NewDefault should have a writer")
+	}
+}
+
+func TestConsoleWriterFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &ConsoleWriter{
+		Out: &buf,
+		Formatter: func(w io.Writer, entry map[string]interface{}) (int, error) {
+			return fmt.Fprintf(w, "%s|%s|%s|%s\n", entry["level"], entry["message"], entry["foo"], entry["n"])
+		},
+	}
+
+	logger := Logger{Writer: w}
+	logger.Info().Str("foo", "bar").Int("n", 42).Msg("hello formatter")
+	fmt.Fprint(w, "not a json line\n")
+
+	if got, want := buf.String(), "info|hello formatter|bar|42\nnot a json line\n"; got != want {
+		t.Errorf("console writer formatter output %q, want %q", got, want)
+	}
+}
+
+func TestConsoleWriterOrder(t *testing.T) {
+	line := `{"time":"2019-07-10T05:35:54.277Z","level":"info","zeta":1,"alpha":"a b","obj":{"x":[1,2]},"zeta":null,"ok":false,"esc":"a\"b","message":"hello order"}` + "\n"
+
+	output := captureStderr(t, func() {
+		fmt.Fprint(&ConsoleWriter{}, line)
+	})
+	want := `2019-07-10T05:35:54.277Z INF > hello order zeta=1 alpha=a b obj={"x":[1,2]} zeta=<nil> ok=false esc=a"b` + "\n"
+	if output != want {
+		t.Errorf("console writer order output %q, want %q", output, want)
+	}
+
+	for _, s := range []string{`{"a":1`, `{"a":}`, `{"a":tru}`, `{a:1}`, `{"a":1,}`, `{"a":[1}`, `[1]`} {
+		if _, ok := parseConsoleFields(nil, []byte(s)); ok {
+			t.Errorf("parse console fields of %s should fail", s)
+		}
+	}
+}
+
+func BenchmarkConsoleWriterParse(b *testing.B) {
+	line := []byte(`{"time":"2019-07-10T05:35:54.277Z","level":"info","caller":"test.go:42","error":"i am test error","foo":"bar","n":42,"message":"hello json console writer"}` + "\n")
+
+	b.Run("Map", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var m map[string]interface{}
+			decoder := json.NewDecoder(bytes.NewReader(line))
+			decoder.UseNumber()
+			decoder.Decode(&m)
+		}
+	})
+
+	b.Run("Scan", func(b *testing.B) {
+		b.ReportAllocs()
+		var array [32]consoleField
+		for i := 0; i < b.N; i++ {
+			parseConsoleFields(array[:0], line)
+		}
+	})
+}
+
+func BenchmarkConsoleWriter(b *testing.B) {
+	w := &ConsoleWriter{Out: ioutil.Discard}
+	line := []byte(`{"time":"2019-07-10T05:35:54.277Z","level":"info","caller":"test.go:42","error":"i am test error","foo":"bar","n":42,"message":"hello json console writer"}` + "\n")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.Write(line)
+	}
+}
+
+func TestConsoleWriterTimeFormat(t *testing.T) {
+	cases := []struct {
+		Line   string
+		Output string
+	}{
+		{`{"time":"2019-07-10T05:35:54.277Z","level":"info","host":"h1","caller":"test.go:42","foo":"bar","message":"hello"}`, "05:35:54.277 INF > hello foo=bar\n"},
+		{`{"time":"yesterday","level":"info","host":"h1","message":"hello"}`, "yesterday INF > hello\n"},
+		{`{"time":1562736954,"level":"info","message":"hello"}`, time.Unix(1562736954, 0).Format("15:04:05.000") + " INF > hello\n"},
+	}
+
+	w := &ConsoleWriter{
+		TimeFormat: "15:04:05.000",
+		HideFields: []string{"host", "caller"},
+	}
+	for _, c := range cases {
+		output := captureStderr(t, func() {
+			fmt.Fprintln(w, c.Line)
+		})
+		if output != c.Output {
+			t.Errorf("console writer time format output %q, want %q", output, c.Output)
+		}
+	}
+}
+
+func TestConsoleWriterColorScheme(t *testing.T) {
+	scheme := &ColorScheme{
+		Trace: "\x1b[1;35m",
+		Debug: "\x1b[1;33m",
+		Info:  "\x1b[1;32m",
+		Warn:  "\x1b[1;34m",
+		Error: "\x1b[1;31m",
+		Fatal: "\x1b[1;91m",
+		Panic: "\x1b[1;41m",
+		Key:   "\x1b[1;36m",
+		Value: "\x1b[1;37m",
+	}
+
+	defer func(s string) { os.Setenv("NO_COLOR", s) }(os.Getenv("NO_COLOR"))
+
+	for _, c := range []struct {
+		Level string
+		Abbr  string
+		Color string
+	}{
+		{"trace", "TRC", scheme.Trace},
+		{"debug", "DBG", scheme.Debug},
+		{"info", "INF", scheme.Info},
+		{"warn", "WRN", scheme.Warn},
+		{"error", "ERR", scheme.Error},
+		{"fatal", "FTL", scheme.Fatal},
+		{"panic", "PNC", scheme.Panic},
+	} {
+		line := `{"time":"2019-07-10T05:35:54.277Z","level":"` + c.Level + `","caller":"console_test.go:42","foo":"bar","message":"hello color"}` + "\n"
+
+		os.Setenv("NO_COLOR", "")
+		var b bytes.Buffer
+		fmt.Fprint(&ConsoleWriter{ANSIColor: true, ColorScheme: scheme, Out: &b}, line)
+		for _, s := range []string{
+			c.Color + c.Abbr + "\x1b[0m",
+			"\x1b[1;36mfoo=\x1b[1;37mbar\x1b[0m",
+			" console_test.go:42 ",
+		} {
+			if !strings.Contains(b.String(), s) {
+				t.Errorf("console writer color scheme output %q, want %q", b.String(), s)
+			}
+		}
+
+		os.Setenv("NO_COLOR", "1")
+		b.Reset()
+		fmt.Fprint(&ConsoleWriter{ANSIColor: true, ColorScheme: scheme, Out: &b}, line)
+		if want := "2019-07-10T05:35:54.277Z " + c.Abbr + " console_test.go:42 > hello color foo=bar\n"; b.String() != want {
+			t.Errorf("console writer NO_COLOR output %q, want %q", b.String(), want)
+		}
+	}
+}
+
+func TestConsoleWriterColorAuto(t *testing.T) {
+	defer func(s string) { os.Setenv("NO_COLOR", s) }(os.Getenv("NO_COLOR"))
+	os.Setenv("NO_COLOR", "")
+
+	line := `{"level":"info","foo":"bar","message":"hello auto"}` + "\n"
+
+	var b bytes.Buffer
+	fmt.Fprint(&ConsoleWriter{ANSIColor: true, ColorAuto: true, Out: &b}, line)
+	if strings.Contains(b.String(), "\x1b[") {
+		t.Errorf("console writer color auto output %q to buffer has escape codes", b.String())
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe() error: %+v", err)
+	}
+	defer r.Close()
+	fmt.Fprint(&ConsoleWriter{ColorAuto: true, Out: w}, line)
+	w.Close()
+	data, _ := ioutil.ReadAll(r)
+	if strings.Contains(string(data), "\x1b[") {
+		t.Errorf("console writer color auto output %q to pipe has escape codes", data)
+	}
+}
+
+func TestConsoleWriterPrettyStack(t *testing.T) {
+	defer func(s string) { os.Setenv("NO_COLOR", s) }(os.Getenv("NO_COLOR"))
+	os.Setenv("NO_COLOR", "")
+
+	line := `{"time":"2019-07-10T05:35:54.277Z","level":"error","foo":"bar","stack":"goroutine 1 [running]:\nmain.main()\n\tmain.go:10 +0x1d\n","message":"hello\nworld"}` + "\n"
+
+	cases := []struct {
+		Writer *ConsoleWriter
+		Output string
+	}{
+		{
+			&ConsoleWriter{},
+			"2019-07-10T05:35:54.277Z ERR > hello\nworld foo=bar stack=goroutine 1 [running]:\nmain.main()\n\tmain.go:10 +0x1d\n\n",
+		},
+		{
+			&ConsoleWriter{PrettyStack: true},
+			"2019-07-10T05:35:54.277Z ERR > hello foo=bar\n  world\n  stack:\n    goroutine 1 [running]:\n    main.main()\n    \tmain.go:10 +0x1d\n",
+		},
+		{
+			&ConsoleWriter{PrettyStack: true, ANSIColor: true, HideTime: true, HideLevel: true},
+			"\x1b[36m>\x1b[0m \x1b[31mhello\x1b[0m \x1b[36mfoo=\x1b[0mbar\x1b[0m\n\x1b[90m  world\x1b[0m\n  \x1b[36mstack:\x1b[0m\n\x1b[90m    goroutine 1 [running]:\x1b[0m\n\x1b[90m    main.main()\x1b[0m\n\x1b[90m    \tmain.go:10 +0x1d\x1b[0m\n",
+		},
+	}
+
+	for _, c := range cases {
+		var b bytes.Buffer
+		c.Writer.Out = &b
+		fmt.Fprint(c.Writer, line)
+		if b.String() != c.Output {
+			t.Errorf("console writer pretty stack output %q, want %q", b.String(), c.Output)
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprint(&ConsoleWriter{PrettyStack: true, HideTime: true, Out: &b}, `{"level":"info","foo":"bar","message":"hello"}`+"\n")
+	if want := "INF > hello foo=bar\n"; b.String() != want {
+		t.Errorf("console writer pretty stack single line output %q, want %q", b.String(), want)
+	}
+}
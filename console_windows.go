@@ -3,88 +3,107 @@
 package log
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
-	"sync/atomic"
 	"syscall"
 	"unsafe"
 )
 
-var (
-	vtInited  uint32
-	vtEnabled bool
-	muConsole sync.Mutex
-)
-
+// Write implements io.Writer, the newline delimited events in p are rendered
+// separately and an incomplete event is buffered until the rest of it is written.
 func (w *ConsoleWriter) Write(p []byte) (n int, err error) {
-	// try init windows 10 virtual terminal
-	if atomic.LoadUint32(&vtInited) == 0 {
-		muConsole.Lock()
-		if atomic.LoadUint32(&vtInited) == 0 {
-			if tryEnableVirtualTerminalProcessing() == nil {
-				vtEnabled = true
-			}
-			atomic.StoreUint32(&vtInited, 1)
-		}
-		muConsole.Unlock()
+	return w.lines(p, func(line []byte) (int, error) {
+		return w.output(line, NoLevel, true)
+	})
+}
+
+// WriteLevel implements LevelWriter, it uses level instead of parsing the level field.
+func (w *ConsoleWriter) WriteLevel(level Level, p []byte) (n int, err error) {
+	return w.output(p, level, false)
+}
+
+// output writes p to a console with virtual terminal processing enabled as is, or
+// renders p with the console text attributes if it cannot be enabled on old Windows.
+// The escape codes written by Formatter are stripped on such a console.
+func (w *ConsoleWriter) output(p []byte, level Level, parse bool) (n int, err error) {
+	f, ok := w.out().(*os.File)
+	if !ok || !w.colored() && w.Formatter == nil {
+		return w.write(p, level, parse)
 	}
-	// write
-	if vtEnabled {
-		n, err = w.write(p)
-	} else {
-		n, err = w.writeWindows(p)
+
+	if consoleModeOf(syscall.Handle(f.Fd())) == consoleLegacy {
+		if w.Formatter != nil {
+			return w.format(ansiStripWriter{f}, p)
+		}
+		return w.writeWindows(f, p, level, parse)
 	}
-	return
+
+	return w.write(p, level, parse)
 }
 
-var (
-	kernel32                = syscall.NewLazyDLL("kernel32.dll")
-	setConsoleMode          = kernel32.NewProc("SetConsoleMode").Call
-	setConsoleTextAttribute = kernel32.NewProc("SetConsoleTextAttribute").Call
+const (
+	consoleNone = iota + 1
+	consoleVirtualTerminal
+	consoleLegacy
 )
 
-func tryEnableVirtualTerminalProcessing() error {
-	var h syscall.Handle
-	var b [64]uint16
-	var n uint32
+var (
+	muConsole    sync.Mutex
+	consoleModes = map[syscall.Handle]int{}
+)
 
-	// open registry
-	err := syscall.RegOpenKeyEx(syscall.HKEY_LOCAL_MACHINE, syscall.StringToUTF16Ptr(`SOFTWARE\Microsoft\Windows NT\CurrentVersion`), 0, syscall.KEY_READ, &h)
-	if err != nil {
-		return err
-	}
-	defer syscall.RegCloseKey(h)
+// consoleModeOf returns whether the handle h is a console and its virtual terminal
+// processing is enabled, it tries to enable the processing at the first call of h.
+func consoleModeOf(h syscall.Handle) int {
+	muConsole.Lock()
+	defer muConsole.Unlock()
 
-	// read windows build number
-	n = uint32(len(b))
-	err = syscall.RegQueryValueEx(h, syscall.StringToUTF16Ptr(`CurrentBuild`), nil, nil, (*byte)(unsafe.Pointer(&b[0])), &n)
-	if err != nil {
-		return err
-	}
-	for i := 0; i < len(b); i++ {
-		if b[i] == 0 {
-			break
+	mode, ok := consoleModes[h]
+	if !ok {
+		switch err := enableVirtualTerminalProcessing(h); {
+		case err == nil:
+			mode = consoleVirtualTerminal
+		case err == errNotConsole:
+			mode = consoleNone
+		default:
+			mode = consoleLegacy
 		}
-		n = n*10 + uint32(b[i]-'0')
+		consoleModes[h] = mode
 	}
 
-	// return if lower than windows 10 16257
-	if n < 16257 {
-		return errors.New("not implemented")
-	}
+	return mode
+}
 
-	// get console mode
-	err = syscall.GetConsoleMode(syscall.Stderr, &n)
-	if err != nil {
-		return err
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	setConsoleMode               = kernel32.NewProc("SetConsoleMode").Call
+	setConsoleTextAttribute      = kernel32.NewProc("SetConsoleTextAttribute").Call
+	getFileInformationByHandleEx = kernel32.NewProc("GetFileInformationByHandleEx").Call
+	getConsoleMode               = syscall.GetConsoleMode
+	getFileType                  = syscall.GetFileType
+	getFileName                  = getFileNameByHandle
+)
+
+var errNotConsole = errors.New("log: handle is not a console")
+
+// enableVirtualTerminalProcessing sets ENABLE_VIRTUAL_TERMINAL_PROCESSING of the
+// console handle h, it fails on the Windows versions before Windows 10 1511.
+func enableVirtualTerminalProcessing(h syscall.Handle) error {
+	const enableVirtualTerminalProcessing = 0x4
+
+	var mode uint32
+	if getConsoleMode(h, &mode) != nil {
+		return errNotConsole
+	}
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return nil
 	}
 
-	// enable ENABLE_VIRTUAL_TERMINAL_PROCESSING
-	ret, _, err := setConsoleMode(uintptr(syscall.Stderr), uintptr(n|0x4))
+	ret, _, err := setConsoleMode(uintptr(h), uintptr(mode|enableVirtualTerminalProcessing))
 	if ret == 0 {
 		return err
 	}
@@ -92,7 +111,37 @@ func tryEnableVirtualTerminalProcessing() error {
 	return nil
 }
 
-func (w *ConsoleWriter) writeWindows(p []byte) (n int, err error) {
+// ansiStripWriter writes to File with the ANSI escape codes removed.
+type ansiStripWriter struct {
+	File *os.File
+}
+
+func (w ansiStripWriter) Write(p []byte) (n int, err error) {
+	b := bbpool.Get().(*bb)
+	b.Reset()
+	defer bbpool.Put(b)
+
+	b.B = stripANSI(b.B, p)
+	if _, err = w.File.Write(b.B); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// stripANSI appends p to dst without the ANSI CSI sequences, e.g. "\x1b[31m".
+func stripANSI(dst, p []byte) []byte {
+	for i := 0; i < len(p); i++ {
+		if p[i] != 0x1b || i+1 == len(p) || p[i+1] != '[' {
+			dst = append(dst, p[i])
+			continue
+		}
+		for i += 2; i < len(p) && (p[i] < 0x40 || p[i] > 0x7e); i++ {
+		}
+	}
+	return dst
+}
+
+func (w *ConsoleWriter) writeWindows(f *os.File, p []byte, level Level, parse bool) (n int, err error) {
 	muConsole.Lock()
 	defer muConsole.Unlock()
 
@@ -107,40 +156,37 @@ func (w *ConsoleWriter) writeWindows(p []byte) (n int, err error) {
 		windowsColorGray   = 8
 	)
 
-	var m map[string]interface{}
-
-	decoder := json.NewDecoder(bytes.NewReader(p))
-	decoder.UseNumber()
-	err = decoder.Decode(&m)
-	if err != nil {
-		n, err = os.Stderr.Write(p)
+	var array [32]consoleField
+	fields, ok := parseConsoleFields(array[:0], p)
+	if !ok {
+		n, err = f.Write(p)
 		return
 	}
 
 	var printf = func(color uintptr, format string, args ...interface{}) {
 		if color != windowsColorWhite {
-			setConsoleTextAttribute(uintptr(syscall.Stderr), color)
+			setConsoleTextAttribute(f.Fd(), color)
 		}
 		var i int
-		i, err = fmt.Fprintf(os.Stderr, format, args...)
+		i, err = fmt.Fprintf(f, format, args...)
 		n += i
 		if color != windowsColorWhite {
-			setConsoleTextAttribute(uintptr(syscall.Stderr), windowsColorWhite)
+			setConsoleTextAttribute(f.Fd(), windowsColorWhite)
 		}
 	}
 
-	if v, ok := m["time"]; ok {
-		if w.ANSIColor {
-			printf(windowsColorGray, "%s ", v)
-		} else {
-			printf(windowsColorWhite, "%s ", v)
-		}
-	}
+	color := w.colored()
 
-	if v, ok := m["level"]; ok {
-		var s string
-		var c uintptr
-		switch s, _ = v.(string); ParseLevel(s) {
+	var s string
+	var c uintptr
+	if v, ok := consoleLookup(fields, "level"); ok {
+		if parse {
+			s, _ = v.(string)
+			level = parseLevel(s)
+		}
+		switch level {
+		case TraceLevel:
+			c, s = windowsColorPurple, "TRC"
 		case DebugLevel:
 			c, s = windowsColorYellow, "DBG"
 		case InfoLevel:
@@ -154,46 +200,123 @@ func (w *ConsoleWriter) writeWindows(p []byte) (n int, err error) {
 		case PanicLevel:
 			c, s = windowsColorRed, "PNC"
 		default:
-			c, s = windowsColorRed, "???"
+			s = levelAbbr(level)
+			switch {
+			case s == "???" || level >= WarnLevel:
+				c = windowsColorRed
+			case level >= InfoLevel:
+				c = windowsColorGreen
+			case level >= DebugLevel:
+				c = windowsColorYellow
+			default:
+				c = windowsColorPurple
+			}
+		}
+	}
+
+	if v, ok := consoleLookup(fields, "time"); ok && !w.HideTime {
+		if w.TimeMode != TimeAbsolute || w.TimeFormat != "" {
+			v = w.formatTime(v)
+		}
+		if color {
+			printf(windowsColorGray, "%s ", v)
+		} else {
+			printf(windowsColorWhite, "%s ", v)
 		}
-		if w.ANSIColor {
+	}
+
+	if s != "" && !w.HideLevel {
+		if color {
 			printf(c, "%s ", s)
 		} else {
 			printf(windowsColorWhite, "%s ", s)
 		}
 	}
 
-	if v, ok := m["caller"]; ok {
+	if v, ok := consoleLookup(fields, "caller"); ok && !w.hidden("caller") {
 		printf(windowsColorWhite, "%s ", v)
 	}
 
-	if v, ok := m["message"]; ok {
+	var printLines = func(indent, s string) {
+		for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+			if color {
+				printf(windowsColorGray, "\n%s%s", indent, strings.TrimSuffix(line, "\r"))
+			} else {
+				printf(windowsColorWhite, "\n%s%s", indent, strings.TrimSuffix(line, "\r"))
+			}
+		}
+	}
+
+	var rest string
+	if v, ok := consoleLookup(fields, "message"); ok && !w.hidden("message") {
 		if s, _ := v.(string); s != "" && s[len(s)-1] == '\n' {
 			v = s[:len(s)-1]
 		}
-		if w.ANSIColor {
+		if s, _ := v.(string); w.PrettyStack {
+			if i := strings.IndexByte(s, '\n'); i >= 0 {
+				v, rest = s[:i], s[i+1:]
+			}
+		}
+		if color {
 			printf(windowsColorAqua, ">")
 		} else {
 			printf(windowsColorWhite, ">")
 		}
-		printf(windowsColorWhite, " %s", v)
+		if color && w.HideLevel && s != "" {
+			printf(c, " %s", v)
+		} else {
+			printf(windowsColorWhite, " %s", v)
+		}
 	}
 
-	for k, v := range m {
+	var stacks []consoleField
+	for _, f := range fields {
+		k, v := f.key, f.value
 		switch k {
 		case "time", "level", "caller", "message":
 			continue
 		}
-		if w.ANSIColor {
+		if w.hidden(k) {
+			continue
+		}
+		if s, _ := v.(string); w.PrettyStack && strings.IndexByte(s, '\n') >= 0 {
+			stacks = append(stacks, f)
+			continue
+		}
+		if n != 0 {
+			printf(windowsColorWhite, " ")
+		}
+		if color {
 			if k == "error" && v != nil {
-				printf(windowsColorRed, " %s=%v", k, v)
+				printf(windowsColorRed, "%s=%v", k, v)
 			} else {
-				printf(windowsColorAqua, " %s=", k)
-				printf(windowsColorGray, "%v", v)
+				printf(windowsColorAqua, "%s=", k)
+				switch v.(type) {
+				case json.Number:
+					printf(windowsColorAqua, "%v", v)
+				case bool:
+					printf(windowsColorYellow, "%v", v)
+				case nil:
+					printf(windowsColorGray, "%v", v)
+				default:
+					printf(windowsColorWhite, "%v", v)
+				}
 			}
 		} else {
-			printf(windowsColorWhite, " %s=%v", k, v)
+			printf(windowsColorWhite, "%s=%v", k, v)
+		}
+	}
+
+	if rest != "" {
+		printLines("  ", rest)
+	}
+	for _, f := range stacks {
+		if color {
+			printf(windowsColorAqua, "\n  %s:", f.key)
+		} else {
+			printf(windowsColorWhite, "\n  %s:", f.key)
 		}
+		printLines("    ", f.value.(string))
 	}
 
 	printf(windowsColorWhite, " \n")
@@ -201,13 +324,48 @@ func (w *ConsoleWriter) writeWindows(p []byte) (n int, err error) {
 	return n, err
 }
 
-// IsTerminal returns whether the given file descriptor is a terminal.
+// IsTerminal returns whether the given file descriptor is a terminal,
+// the pipes of MSYS2/Cygwin terminals like mintty are also considered as terminals.
 func IsTerminal(fd uintptr) bool {
 	var mode uint32
-	err := syscall.GetConsoleMode(syscall.Handle(fd), &mode)
+	err := getConsoleMode(syscall.Handle(fd), &mode)
+	if err == nil {
+		return true
+	}
+
+	return isCygwinTerminal(fd)
+}
+
+func isCygwinTerminal(fd uintptr) bool {
+	ft, err := getFileType(syscall.Handle(fd))
+	if err != nil || ft != syscall.FILE_TYPE_PIPE {
+		return false
+	}
+
+	name, err := getFileName(fd)
 	if err != nil {
 		return false
 	}
 
-	return true
+	return isCygwinPipeName(name)
+}
+
+func getFileNameByHandle(fd uintptr) (string, error) {
+	// FILE_NAME_INFO
+	var info struct {
+		Length uint32
+		Name   [syscall.MAX_PATH]uint16
+	}
+	const fileNameInfo = 2
+	ret, _, err := getFileInformationByHandleEx(fd, fileNameInfo, uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info))
+	if ret == 0 {
+		return "", err
+	}
+
+	n := info.Length / 2
+	if n > syscall.MAX_PATH {
+		n = syscall.MAX_PATH
+	}
+
+	return syscall.UTF16ToString(info.Name[:n]), nil
 }
@@ -0,0 +1,107 @@
+// +build windows
+
+package log
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestIsTerminalWindows(t *testing.T) {
+	defer func(f1 func(syscall.Handle, *uint32) error, f2 func(syscall.Handle) (uint32, error), f3 func(uintptr) (string, error)) {
+		getConsoleMode, getFileType, getFileName = f1, f2, f3
+	}(getConsoleMode, getFileType, getFileName)
+
+	cases := []struct {
+		Console  bool
+		FileType uint32
+		PipeName string
+		Terminal bool
+	}{
+		{true, syscall.FILE_TYPE_CHAR, "", true},
+		{false, syscall.FILE_TYPE_DISK, "", false},
+		{false, syscall.FILE_TYPE_PIPE, `\msys-1888ae32e00d56aa-pty0-to-master`, true},
+		{false, syscall.FILE_TYPE_PIPE, `\cygwin-e022582115c10879-pty4-from-master`, true},
+		{false, syscall.FILE_TYPE_PIPE, `\Win32Pipes.000008c4.00000001`, false},
+	}
+
+	for _, c := range cases {
+		getConsoleMode = func(syscall.Handle, *uint32) error {
+			if c.Console {
+				return nil
+			}
+			return syscall.EINVAL
+		}
+		getFileType = func(syscall.Handle) (uint32, error) {
+			return c.FileType, nil
+		}
+		getFileName = func(uintptr) (string, error) {
+			return c.PipeName, nil
+		}
+
+		if got := IsTerminal(0); got != c.Terminal {
+			t.Errorf("IsTerminal(%+v) got %v, want %v", c, got, c.Terminal)
+		}
+	}
+}
+
+func TestConsoleModeOf(t *testing.T) {
+	defer func(f1 func(syscall.Handle, *uint32) error, f2 func(...uintptr) (uintptr, uintptr, error)) {
+		getConsoleMode, setConsoleMode = f1, f2
+	}(getConsoleMode, setConsoleMode)
+
+	cases := []struct {
+		Handle  syscall.Handle
+		Console bool
+		Mode    uint32
+		SetOK   bool
+		Result  int
+	}{
+		{1001, false, 0, false, consoleNone},
+		{1002, true, 0x4, false, consoleVirtualTerminal},
+		{1003, true, 0x3, true, consoleVirtualTerminal},
+		{1004, true, 0x3, false, consoleLegacy},
+	}
+
+	for _, c := range cases {
+		getConsoleMode = func(h syscall.Handle, mode *uint32) error {
+			if !c.Console {
+				return syscall.EINVAL
+			}
+			*mode = c.Mode
+			return nil
+		}
+		setConsoleMode = func(args ...uintptr) (uintptr, uintptr, error) {
+			if args[1] != uintptr(c.Mode|0x4) {
+				t.Errorf("SetConsoleMode(%+v) got mode %x", c, args[1])
+			}
+			if c.SetOK {
+				return 1, 0, nil
+			}
+			return 0, 0, syscall.EINVAL
+		}
+
+		if got := consoleModeOf(c.Handle); got != c.Result {
+			t.Errorf("consoleModeOf(%+v) got %v, want %v", c, got, c.Result)
+		}
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output string
+	}{
+		{"hello", "hello"},
+		{"\x1b[31mERR\x1b[0m > hello", "ERR > hello"},
+		{"\x1b[1;36mfoo=\x1b[0mbar", "foo=bar"},
+		{"tail \x1b[", "tail "},
+		{"esc \x1b only", "esc \x1b only"},
+	}
+
+	for _, c := range cases {
+		if got := string(stripANSI(nil, []byte(c.Input))); got != c.Output {
+			t.Errorf("stripANSI(%q) got %q, want %q", c.Input, got, c.Output)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package log
+
+import (
+	"bytes"
+	"context"
+)
+
+// Context represents the pre-encoded contextual fields.
+type Context []byte
+
+// NewContext starts a new contextual event appending to dst, its fields are built
+// by the Value method instead of Msg.
+func NewContext(dst []byte) (e *Event) {
+	e = epool.Get().(*Event)
+	e.buf = dst
+	e.escapes = &escapes
+	e.ecs = false
+	e.names = nil
+	return
+}
+
+// Value returns the fields of the contextual event started by NewContext.
+func (e *Event) Value() Context {
+	if e == nil {
+		return nil
+	}
+	b := e.buf
+	e.buf = nil
+	eventPut(e)
+	return b
+}
+
+// With starts a contextual event of the logger, its fields are pre-encoded into the
+// Context of a new logger returned by the Logger method, so they are copied into
+// every event of the new logger without encoding them again, e.g.
+//
+//	sublogger := logger.With().Str("service", "api").Str("region", "us").Logger()
+//
+// The nested With calls accumulate the fields.
+func (l *Logger) With() (e *Event) {
+	e = NewContext(append(make([]byte, 0, len(l.Context)+128), l.Context...))
+	e.parent = l
+	e.escapes = l.escapes()
+	if e.ecs = l.Schema == ECS; e.ecs {
+		e.names = &defaultFieldNames
+	} else {
+		e.names = l.fieldNames()
+	}
+	e.rawjson = l.RawJSONMode
+	e.maxiface = l.MaxInterfaceSize
+	return
+}
+
+// Logger returns a clone of the logger which started the contextual event by With,
+// whose Context is the fields of the event.
+func (e *Event) Logger() *Logger {
+	if e == nil {
+		return nil
+	}
+	l := e.parent
+	if l == nil {
+		l = &DefaultLogger
+	}
+	e.parent = nil
+	ctx := e.Value()
+	return l.Clone(func(c *Logger) {
+		c.Context = ctx
+	})
+}
+
+// Context appends the contextual fields to the event.
+func (e *Event) Context(ctx Context) *Event {
+	if e == nil {
+		return nil
+	}
+	e.buf = append(e.buf, ctx...)
+	return e
+}
+
+type fieldsContextKey struct{}
+
+// ContextWithFields returns a copy of ctx in which the fields are stored, they are
+// merged into the logger returned by Ctx. The fields accumulate over nested calls,
+// a later field replaces an earlier one with the same key.
+func ContextWithFields(ctx context.Context, fields Context) context.Context {
+	if v, ok := ctx.Value(fieldsContextKey{}).(Context); ok {
+		fields = mergeContext(v, fields)
+	}
+	return context.WithValue(ctx, fieldsContextKey{}, fields)
+}
+
+// FieldsFromContext returns the fields stored in ctx by ContextWithFields, or nil if none, e.g.
+//
+//	log.Info().Context(log.FieldsFromContext(ctx)).Msg("hello")
+func FieldsFromContext(ctx context.Context) Context {
+	fields, _ := ctx.Value(fieldsContextKey{}).(Context)
+	return fields
+}
+
+type levelContextKey struct{}
+
+// ContextWithMinLevel returns a copy of ctx in which the minimum level is stored,
+// the logger returned by Ctx logs the events at or above level even if its own
+// level is higher.
+func ContextWithMinLevel(ctx context.Context, level Level) context.Context {
+	return context.WithValue(ctx, levelContextKey{}, level)
+}
+
+// contextMinLevel returns the level stored in ctx by ContextWithMinLevel.
+func contextMinLevel(ctx context.Context) (level Level, ok bool) {
+	level, ok = ctx.Value(levelContextKey{}).(Level)
+	return
+}
+
+type loggerContextKey struct{}
+
+// WithContext returns a copy of ctx in which the logger is stored.
+func (l *Logger) WithContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, l)
+}
+
+// Ctx returns the logger stored in ctx by WithContext, or &DefaultLogger if none.
+// If ctx also carries fields stored by ContextWithFields or a level stored by
+// ContextWithMinLevel, it returns a clone of the logger whose Context is merged
+// with the fields and whose Level is lowered to the level. The fields of ctx take
+// precedence over the fields of the logger with the same key.
+func Ctx(ctx context.Context) *Logger {
+	l, _ := ctx.Value(loggerContextKey{}).(*Logger)
+	if l == nil {
+		l = &DefaultLogger
+	}
+	fields := FieldsFromContext(ctx)
+	level, ok := contextMinLevel(ctx)
+	ok = ok && level < l.level()
+	if len(fields) != 0 || ok {
+		l = l.Clone(func(c *Logger) {
+			if len(fields) != 0 {
+				c.Context = mergeContext(c.Context, fields)
+			}
+			if ok {
+				c.Level = level
+			}
+		})
+	}
+	return l
+}
+
+// mergeContext returns the fields of dst not present in src followed by src.
+func mergeContext(dst, src Context) Context {
+	c := make(Context, 0, len(dst)+len(src))
+	eachField(dst, func(key, field []byte) {
+		found := false
+		eachField(src, func(k, _ []byte) {
+			found = found || bytes.Equal(k, key)
+		})
+		if !found {
+			c = append(c, field...)
+		}
+	})
+	return append(c, src...)
+}
+
+// eachField calls f with the key and the whole encoded field for each field of c.
+func eachField(c Context, f func(key, field []byte)) {
+	for i := 0; i+1 < len(c); {
+		// skip the leading `,"` and find the end of key
+		j := i + 2
+		for ; j < len(c) && c[j] != '"'; j++ {
+			if c[j] == '\\' {
+				j++
+			}
+		}
+		key := c[i+2 : j]
+		// find the end of value
+		depth, quoted := 0, false
+		k := j + 2
+	value:
+		for ; k < len(c); k++ {
+			if quoted {
+				switch c[k] {
+				case '\\':
+					k++
+				case '"':
+					quoted = false
+				}
+				continue
+			}
+			switch c[k] {
+			case '"':
+				quoted = true
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			case ',':
+				if depth == 0 {
+					break value
+				}
+			}
+		}
+		if k > len(c) {
+			k = len(c)
+		}
+		f(key, c[i:k])
+		i = k
+	}
+}
@@ -0,0 +1,173 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoggerContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{
+		Writer:  &buf,
+		Context: NewContext(nil).Str("service", "api").Int("shard", 3).Value(),
+	}
+
+	logger.Info().Context(NewContext(nil).Bool("retry", true).Value()).Msg("hello")
+	if !strings.Contains(buf.String(), `"level":"info","service":"api","shard":3,"retry":true,"message":"hello"}`) {
+		t.Errorf("unexpected context output %s", buf.String())
+	}
+}
+
+func TestLoggerWith(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &Logger{
+		Level:  InfoLevel,
+		Writer: &buf,
+	}
+
+	sublogger := logger.With().Str("service", "api").Str("region", "us").Logger()
+	reqlogger := sublogger.With().Int64("request_id", 42).Bool("retry", false).Dur("timeout", time.Second).Logger()
+
+	reqlogger.Info().Str("foo", "bar").Msg("hello")
+	if s := buf.String(); !strings.Contains(s, `"level":"info","service":"api","region":"us","request_id":42,"retry":false,"timeout":"1s","foo":"bar","message":"hello"}`) {
+		t.Errorf("unexpected with output %s", s)
+	}
+
+	buf.Reset()
+	sublogger.Info().Msg("hello")
+	logger.Info().Msg("hello")
+	if s := buf.String(); !strings.Contains(s, `"level":"info","service":"api","region":"us","message":"hello"}`) ||
+		!strings.Contains(s, `"level":"info","message":"hello"}`) {
+		t.Errorf("the parent loggers should not be changed, got %s", s)
+	}
+
+	if reqlogger.Level != InfoLevel || reqlogger.Writer != logger.Writer {
+		t.Errorf("the sub logger should inherit the config, got %+v", reqlogger)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l := sublogger.With().Int("worker", i).Logger()
+			l.Debug().Msg("dropped")
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestContextWithFields(t *testing.T) {
+	if fields := FieldsFromContext(context.Background()); fields != nil {
+		t.Errorf("FieldsFromContext of empty context should return nil, got %s", fields)
+	}
+
+	ctx := ContextWithFields(context.Background(), NewContext(nil).Str("request_id", "abc").Str("tenant", "t1").Value())
+	ctx = ContextWithFields(ctx, NewContext(nil).Str("user", `"bob",{}`).Str("tenant", "t2").Value())
+
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+	logger.Info().Context(FieldsFromContext(ctx)).Msg("hello")
+	if s := buf.String(); !strings.Contains(s, `"level":"info","request_id":"abc","user":"\"bob\",{}","tenant":"t2","message":"hello"}`) {
+		t.Errorf("later fields in context should take precedence, got %s", s)
+	}
+}
+
+func TestCtx(t *testing.T) {
+	if l := Ctx(context.Background()); l != &DefaultLogger {
+		t.Errorf("Ctx of empty context should return DefaultLogger, got %p", l)
+	}
+
+	var buf bytes.Buffer
+	logger := &Logger{
+		Writer:  &buf,
+		Context: NewContext(nil).Str("service", "api").Str("request_id", "from-logger").Value(),
+	}
+
+	ctx := logger.WithContext(context.Background())
+	if l := Ctx(ctx); l != logger {
+		t.Errorf("Ctx should return the stored logger %p, got %p", logger, l)
+	}
+
+	ctx = ContextWithFields(ctx, NewContext(nil).Str("request_id", "abc").Str("tenant", "t1").Value())
+	ctx = ContextWithFields(ctx, NewContext(nil).Str("user", `"bob",{}`).Str("tenant", "t2").Value())
+
+	Ctx(ctx).Info().Msg("hello")
+	if s := buf.String(); !strings.Contains(s, `"service":"api","request_id":"abc","user":"\"bob\",{}","tenant":"t2","message":"hello"}`) {
+		t.Errorf("fields in context should take precedence, got %s", s)
+	}
+
+	buf.Reset()
+	logger.Info().Msg("hello")
+	if s := buf.String(); !strings.Contains(s, `"service":"api","request_id":"from-logger","message":"hello"}`) {
+		t.Errorf("the stored logger should not be changed, got %s", s)
+	}
+}
+
+func TestCtxWith(t *testing.T) {
+	var buf bytes.Buffer
+	logger := (&Logger{Writer: &buf}).With().Str("request_id", "abc").Logger()
+
+	ctx := logger.WithContext(context.Background())
+	if n := allocsPerRun(100, func() {
+		if Ctx(ctx) != logger {
+			t.Fatalf("Ctx should return the stored sub logger %p", logger)
+		}
+	}); n != 0 {
+		t.Errorf("Ctx allocates %v times", n)
+	}
+
+	Ctx(ctx).Info().Msg("hello")
+	if s := buf.String(); !strings.Contains(s, `"request_id":"abc","message":"hello"}`) {
+		t.Errorf("the stored sub logger should log its fields, got %s", s)
+	}
+}
+
+func TestMergeContext(t *testing.T) {
+	cases := []struct {
+		Dst  Context
+		Src  Context
+		Want string
+	}{
+		{nil, nil, ``},
+		{Context(`,"a":1`), nil, `,"a":1`},
+		{nil, Context(`,"a":1`), `,"a":1`},
+		{Context(`,"a":1,"b":{"a":[1,2],"c":"}"},"c":"x\",y"`), Context(`,"c":2`), `,"a":1,"b":{"a":[1,2],"c":"}"},"c":2`},
+		{Context(`,"a\"b":1,"c":[{"a":1},2]`), Context(`,"a\"b":2`), `,"c":[{"a":1},2],"a\"b":2`},
+	}
+
+	for _, c := range cases {
+		if got := string(mergeContext(c.Dst, c.Src)); got != c.Want {
+			t.Errorf("mergeContext(%s, %s) got %s, want %s", c.Dst, c.Src, got, c.Want)
+		}
+	}
+}
+
+func TestContextWithMinLevel(t *testing.T) {
+	if nodebug {
+		t.Skip("debug events are eliminated by log_nodebug")
+	}
+
+	var buf bytes.Buffer
+	logger := &Logger{
+		Level:  InfoLevel,
+		Writer: &buf,
+	}
+
+	ctx := logger.WithContext(context.Background())
+	Ctx(ContextWithMinLevel(ctx, DebugLevel)).Debug().Msg("forced")
+	Ctx(ctx).Debug().Msg("dropped")
+	Ctx(ContextWithMinLevel(ctx, ErrorLevel)).Info().Msg("kept")
+	logger.Debug().Msg("dropped")
+
+	if s := buf.String(); strings.Count(s, "\n") != 2 || !strings.Contains(s, `"message":"forced"`) || !strings.Contains(s, `"message":"kept"`) {
+		t.Errorf("unexpected min level output %s", s)
+	}
+	if logger.Level != InfoLevel {
+		t.Errorf("the stored logger level should not be changed, got %v", logger.Level)
+	}
+}
@@ -0,0 +1,46 @@
+// +build !log_nodebug
+
+package log
+
+import (
+	"runtime"
+)
+
+// nodebug reports whether the trace and debug events are eliminated by the log_nodebug build tag.
+const nodebug = false
+
+// Trace starts a new message with trace level.
+func Trace() (e *Event) {
+	e = DefaultLogger.header(TraceLevel)
+	if e != nil && DefaultLogger.Caller > 0 {
+		e.caller(runtime.Caller(DefaultLogger.Caller + DefaultLogger.CallerSkipFrames))
+	}
+	return
+}
+
+// Debug starts a new message with debug level.
+func Debug() (e *Event) {
+	e = DefaultLogger.header(DebugLevel)
+	if e != nil && DefaultLogger.Caller > 0 {
+		e.caller(runtime.Caller(DefaultLogger.Caller + DefaultLogger.CallerSkipFrames))
+	}
+	return
+}
+
+// Trace starts a new message with trace level.
+func (l *Logger) Trace() (e *Event) {
+	e = l.header(TraceLevel)
+	if e != nil && l.Caller > 0 {
+		e.caller(runtime.Caller(l.Caller + l.CallerSkipFrames))
+	}
+	return
+}
+
+// Debug starts a new message with debug level.
+func (l *Logger) Debug() (e *Event) {
+	e = l.header(DebugLevel)
+	if e != nil && l.Caller > 0 {
+		e.caller(runtime.Caller(l.Caller + l.CallerSkipFrames))
+	}
+	return
+}
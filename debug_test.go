@@ -0,0 +1,48 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestLoggerTrace(t *testing.T) {
+	if nodebug {
+		t.Skip("trace events are eliminated by log_nodebug")
+	}
+
+	var buf bytes.Buffer
+	logger := Logger{
+		Level:  TraceLevel,
+		Writer: &buf,
+	}
+	logger.Trace().Str("foo", "bar").Msg("hello trace")
+	if !strings.Contains(buf.String(), `"level":"trace","foo":"bar","message":"hello trace"`) {
+		t.Errorf("unexpected trace output %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.SetLevel(DebugLevel)
+	logger.Trace().Msg("hello trace")
+	if buf.Len() != 0 {
+		t.Errorf("trace event should be dropped at debug level, got %s", buf.String())
+	}
+
+	DefaultLogger.SetLevel(TraceLevel)
+	Trace().Msg("hello trace")
+	DefaultLogger.SetLevel(DebugLevel)
+}
+
+func BenchmarkDebugDisabled(b *testing.B) {
+	logger := Logger{
+		Level:  InfoLevel,
+		Writer: ioutil.Discard,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debug().Str("foo", "bar").Int("n", i).Msg("hello world")
+	}
+}
@@ -0,0 +1,261 @@
+package log
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// DedupWriter is an io.WriteCloser and LevelWriter that suppresses the identical
+// events within Window, the events are identical if their levels, messages and the
+// values of Fields are equal. The first event of a window is written, and when the
+// window closes a summary of the suppressed events is written at their level, which
+// is the last suppressed event with the "suppressed" count field added, e.g.
+//
+//	{"time":"2019-07-10T05:35:54.277Z","level":"error","message":"dial tcp: connection refused","suppressed":1234}
+//
+// The keys are tracked in a LRU list so the memory is bounded by Capacity, the
+// summary of an evicted key is written at once.
+type DedupWriter struct {
+	// Window specifies the duration in which the identical events are suppressed. It uses 1s in if zero.
+	Window time.Duration
+
+	// Fields specifies the keys of the fields which identify an event in addition to its level and message.
+	Fields []string
+
+	// Capacity specifies the maximum number of keys tracked. It uses 1024 in if zero.
+	Capacity int
+
+	// Writer specifies the writer of output. It uses os.Stderr in if empty.
+	Writer io.Writer
+
+	mu    sync.Mutex
+	key   []byte
+	list  *list.List
+	keys  map[string]*list.Element
+	timer *time.Timer
+	next  int64
+}
+
+type dedupEntry struct {
+	key        string
+	start      int64
+	level      Level
+	suppressed int64
+	last       []byte
+}
+
+// Write implements io.Writer, the level is parsed from the level field of p.
+func (w *DedupWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(levelOf(p), p)
+}
+
+// WriteLevel implements LevelWriter. The message is parsed from the "message" field
+// of p, or the whole p is used if p has no such field, e.g. the events of a Logger with
+// MessageField written through another writer. Msg of a Logger writing to w directly
+// passes the message instead.
+func (w *DedupWriter) WriteLevel(level Level, p []byte) (n int, err error) {
+	msg := messageOf(p)
+	if msg == nil {
+		msg = p
+	}
+	return w.dedup(level, msg, p)
+}
+
+func (w *DedupWriter) writeMessage(level Level, msg string, p []byte) (n int, err error) {
+	return w.dedup(level, *(*[]byte)(unsafe.Pointer(&sliceHeader{msg, len(msg)})), p)
+}
+
+// dedup writes p unless the key of level, msg and Fields is written in Window.
+func (w *DedupWriter) dedup(level Level, msg, p []byte) (n int, err error) {
+	sec, nsec := walltime()
+	now := sec*1000000000 + int64(nsec)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.keys == nil {
+		w.list = list.New()
+		w.keys = make(map[string]*list.Element)
+	}
+
+	w.key = append(append(w.key[:0], byte(level)), msg...)
+	for _, field := range w.Fields {
+		w.key = append(append(w.key, 0), fieldOf(p, field)...)
+	}
+
+	if elem := w.keys[string(w.key)]; elem != nil {
+		entry := elem.Value.(*dedupEntry)
+		w.list.MoveToFront(elem)
+		if now-entry.start < int64(w.window()) {
+			entry.suppressed++
+			entry.last = append(entry.last[:0], p...)
+			if end := entry.start + int64(w.window()); w.timer == nil || end < w.next {
+				w.schedule(now, end)
+			}
+			return len(p), nil
+		}
+		if _, err = w.summary(entry); err != nil {
+			return 0, err
+		}
+		entry.start = now
+		return writeLevel(w.writer(), level, p)
+	}
+
+	w.keys[string(w.key)] = w.list.PushFront(&dedupEntry{key: string(w.key), start: now, level: level})
+	capacity := w.Capacity
+	if capacity <= 0 {
+		capacity = 1024
+	}
+	for w.list.Len() > capacity {
+		back := w.list.Back()
+		entry := back.Value.(*dedupEntry)
+		delete(w.keys, entry.key)
+		w.list.Remove(back)
+		if _, err = w.summary(entry); err != nil {
+			return 0, err
+		}
+	}
+
+	return writeLevel(w.writer(), level, p)
+}
+
+// Close implements io.Closer, it writes the summaries of the suppressed events
+// and closes Writer if it is an io.Closer.
+func (w *DedupWriter) Close() (err error) {
+	w.mu.Lock()
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.list != nil {
+		for elem := w.list.Back(); elem != nil; elem = elem.Prev() {
+			if _, err1 := w.summary(elem.Value.(*dedupEntry)); err == nil {
+				err = err1
+			}
+		}
+	}
+	w.mu.Unlock()
+
+	if err1 := closeWriter(w.writer()); err == nil {
+		err = err1
+	}
+	return
+}
+
+// Describe implements WriterDescriber.
+func (w *DedupWriter) Describe() string {
+	capacity := w.Capacity
+	if capacity <= 0 {
+		capacity = 1024
+	}
+	return fmt.Sprintf("DedupWriter{Window:%s Fields:%v Capacity:%d} -> %s", w.window(), w.Fields, capacity, describeWriter(w.Writer))
+}
+
+func (w *DedupWriter) window() time.Duration {
+	if w.Window > 0 {
+		return w.Window
+	}
+	return time.Second
+}
+
+func (w *DedupWriter) writer() io.Writer {
+	if w.Writer != nil {
+		return w.Writer
+	}
+	return os.Stderr
+}
+
+// expire writes the summaries of the closed windows, and schedules itself for the
+// earliest window which is still open.
+func (w *DedupWriter) expire() {
+	sec, nsec := walltime()
+	now := sec*1000000000 + int64(nsec)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.timer = nil
+	var next int64
+	for elem := w.list.Back(); elem != nil; elem = elem.Prev() {
+		entry := elem.Value.(*dedupEntry)
+		if entry.suppressed == 0 {
+			continue
+		}
+		end := entry.start + int64(w.window())
+		if now < end {
+			if next == 0 || end < next {
+				next = end
+			}
+			continue
+		}
+		if _, err := w.summary(entry); err != nil && ErrorHandler != nil {
+			ErrorHandler(fmt.Errorf("log: DedupWriter write error: %v", err))
+		}
+	}
+	if next != 0 {
+		w.schedule(now, next)
+	}
+}
+
+// schedule runs expire at the time end.
+func (w *DedupWriter) schedule(now, end int64) {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.next = end
+	w.timer = time.AfterFunc(time.Duration(end-now), w.expire)
+}
+
+// summary writes the last suppressed event of entry with the "suppressed" field
+// added, and resets the count.
+func (w *DedupWriter) summary(entry *dedupEntry) (n int, err error) {
+	if entry.suppressed == 0 {
+		return
+	}
+	p := entry.last
+	if i := bytes.LastIndexByte(p, '}'); i > 0 {
+		p = append(p[:i], ",\"suppressed\":"...)
+		p = strconv.AppendInt(p, entry.suppressed, 10)
+		p = append(p, '}', '\n')
+	}
+	entry.suppressed = 0
+	entry.last = entry.last[:0]
+	return writeLevel(w.writer(), entry.level, p)
+}
+
+// fieldOf returns the raw JSON value of the top level field key of the JSON event p.
+func fieldOf(p []byte, key string) []byte {
+	i := skipSpace(p, 0)
+	if i >= len(p) || p[i] != '{' {
+		return nil
+	}
+	for i = skipSpace(p, i+1); i < len(p) && p[i] == '"'; {
+		j := scanValue(p, i)
+		if j < 0 {
+			return nil
+		}
+		k := p[i+1 : j-1]
+		if i = skipSpace(p, j); i >= len(p) || p[i] != ':' {
+			return nil
+		}
+		i = skipSpace(p, i+1)
+		if j = scanValue(p, i); j < 0 {
+			return nil
+		}
+		if string(k) == key {
+			return p[i:j]
+		}
+		if i = skipSpace(p, j); i >= len(p) || p[i] != ',' {
+			return nil
+		}
+		i = skipSpace(p, i+1)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package log
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupWriter(t *testing.T) {
+	lw := &levelWriter{}
+	w := &DedupWriter{Window: time.Hour, Writer: lw}
+	logger := Logger{Writer: w}
+
+	for i := 0; i < 5; i++ {
+		logger.Error().Int("i", i).Msg("connection refused")
+	}
+	logger.Info().Msg("connected")
+	logger.Info().Msg("connection refused")
+
+	if len(lw.lines) != 3 {
+		t.Fatalf("dedup writer output %d lines, want 3: %v", len(lw.lines), lw.lines)
+	}
+	if !strings.Contains(lw.lines[0], `"i":0,`) || strings.Contains(lw.lines[0], "suppressed") {
+		t.Errorf("dedup writer first line %s", lw.lines[0])
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("dedup writer close error: %+v", err)
+	}
+	if len(lw.lines) != 4 {
+		t.Fatalf("dedup writer output %d lines after close, want 4: %v", len(lw.lines), lw.lines)
+	}
+	if !strings.HasSuffix(lw.lines[3], `"i":4,"message":"connection refused","suppressed":4}`+"\n") {
+		t.Errorf("dedup writer summary %s", lw.lines[3])
+	}
+	if lw.levels[3] != ErrorLevel {
+		t.Errorf("dedup writer summary level %v, want error", lw.levels[3])
+	}
+}
+
+func TestDedupWriterMessageField(t *testing.T) {
+	lw := &levelWriter{}
+	w := &DedupWriter{Window: time.Hour, Writer: lw}
+	logger := Logger{Writer: w, MessageField: "msg"}
+
+	logger.Error().Msg("connection refused")
+	logger.Error().Msg("timeout")
+	logger.Error().Int("i", 1).Msg("connection refused")
+	logger.Error().Int("i", 2).Msg("timeout")
+	if len(lw.lines) != 2 || !strings.Contains(lw.lines[1], `"msg":"timeout"`) {
+		t.Fatalf("dedup writer with MessageField output %v, want 2 distinct lines", lw.lines)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("dedup writer close error: %+v", err)
+	}
+	if len(lw.lines) != 4 {
+		t.Fatalf("dedup writer output %d lines after close, want 4: %v", len(lw.lines), lw.lines)
+	}
+	if !strings.HasSuffix(lw.lines[2], `"i":1,"msg":"connection refused","suppressed":1}`+"\n") ||
+		!strings.HasSuffix(lw.lines[3], `"i":2,"msg":"timeout","suppressed":1}`+"\n") {
+		t.Errorf("dedup writer summaries %v", lw.lines[2:])
+	}
+
+	lw = &levelWriter{}
+	w = &DedupWriter{Window: time.Hour, Writer: lw}
+	logger = Logger{Writer: struct{ io.Writer }{w}, MessageField: "msg"}
+	logger.Error().Msg("connection refused")
+	logger.Error().Msg("timeout")
+	if len(lw.lines) != 2 {
+		t.Errorf("dedup writer with MessageField through a wrapper output %v, want 2 lines", lw.lines)
+	}
+}
+
+func TestDedupWriterWindow(t *testing.T) {
+	var buf lockedBuffer
+	w := &DedupWriter{Window: 20 * time.Millisecond, Writer: &buf}
+	defer w.Close()
+
+	for i := 0; i < 3; i++ {
+		w.Write([]byte(`{"level":"warn","message":"flapping"}` + "\n"))
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	buf.mu.Lock()
+	output := buf.buf.String()
+	buf.mu.Unlock()
+	if want := `{"level":"warn","message":"flapping"}` + "\n" + `{"level":"warn","message":"flapping","suppressed":2}` + "\n"; output != want {
+		t.Errorf("dedup writer window output %q, want %q", output, want)
+	}
+
+	w.Write([]byte(`{"level":"warn","message":"flapping"}` + "\n"))
+	buf.mu.Lock()
+	n := strings.Count(buf.buf.String(), "\n")
+	buf.mu.Unlock()
+	if n != 3 {
+		t.Errorf("dedup writer should write the first event of a new window, got %d lines", n)
+	}
+}
+
+func TestDedupWriterFields(t *testing.T) {
+	lw := &levelWriter{}
+	w := &DedupWriter{Window: time.Hour, Fields: []string{"host"}, Capacity: 2, Writer: lw}
+
+	for _, host := range []string{"a", "b", "a", "b", "c"} {
+		w.Write([]byte(`{"level":"error","host":"` + host + `","message":"timeout"}` + "\n"))
+	}
+
+	// "a" is evicted by "c", so its summary is written before "c".
+	want := []string{
+		`{"level":"error","host":"a","message":"timeout"}` + "\n",
+		`{"level":"error","host":"b","message":"timeout"}` + "\n",
+		`{"level":"error","host":"a","message":"timeout","suppressed":1}` + "\n",
+		`{"level":"error","host":"c","message":"timeout"}` + "\n",
+	}
+	if strings.Join(lw.lines, "") != strings.Join(want, "") {
+		t.Errorf("dedup writer fields output %v, want %v", lw.lines, want)
+	}
+
+	if got, want := w.Describe(), "DedupWriter{Window:1h0m0s Fields:[host] Capacity:2} -> *log.levelWriter"; got != want {
+		t.Errorf("dedup writer describe %q, want %q", got, want)
+	}
+}
+
+func TestFieldOf(t *testing.T) {
+	p := []byte(`{"time":"2019-07-10T05:35:54.277Z", "obj":{"host":"x"},"host":"a\"b","n":42,"arr":[1,2]}`)
+	cases := []struct {
+		Key   string
+		Value string
+	}{
+		{"host", `"a\"b"`},
+		{"n", `42`},
+		{"obj", `{"host":"x"}`},
+		{"arr", `[1,2]`},
+		{"none", ``},
+	}
+	for _, c := range cases {
+		if got := string(fieldOf(p, c.Key)); got != c.Value {
+			t.Errorf("fieldOf(%q) got %q, want %q", c.Key, got, c.Value)
+		}
+	}
+}
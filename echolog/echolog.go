@@ -0,0 +1,85 @@
+// Package echolog provides an Echo middleware which logs the requests by github.com/phuslu/log.
+//
+//	e.Use(echolog.Middleware(&log.DefaultLogger))
+package echolog
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phuslu/log"
+)
+
+// Middleware returns an echo.MiddlewareFunc which logs one event per request with the
+// method, path, query, route, status, duration, remote_ip, user_agent, request_id
+// and errors fields. The level is error for 5xx, warn for 4xx and info otherwise.
+// A panic in the handlers is recovered and logged with the stack, and it is passed
+// to the HTTP error handler of Echo as an error.
+func Middleware(l *log.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			start := time.Now()
+
+			var panicked interface{}
+			var stack []byte
+			func() {
+				defer func() {
+					if panicked = recover(); panicked != nil {
+						stack = debug.Stack()
+						if e, ok := panicked.(error); ok {
+							err = e
+						} else {
+							err = fmt.Errorf("%v", panicked)
+						}
+					}
+				}()
+				err = next(c)
+			}()
+			if err != nil {
+				// let the error handler write the response, so the status is known.
+				c.Error(err)
+			}
+
+			req, res := c.Request(), c.Response()
+
+			var e *log.Event
+			switch {
+			case res.Status >= 500 || panicked != nil:
+				e = l.Error()
+			case res.Status >= 400:
+				e = l.Warn()
+			default:
+				e = l.Info()
+			}
+			if e == nil {
+				return nil
+			}
+
+			requestID := req.Header.Get(echo.HeaderXRequestID)
+			if requestID == "" {
+				requestID = res.Header().Get(echo.HeaderXRequestID)
+			}
+
+			e = e.Str("method", req.Method).
+				Str("path", req.URL.Path).
+				Str("query", req.URL.RawQuery).
+				Str("route", c.Path()).
+				Int("status", res.Status).
+				Int64("size", res.Size).
+				Dur("duration", time.Since(start)).
+				Str("remote_ip", c.RealIP()).
+				Str("user_agent", req.UserAgent()).
+				Str("request_id", requestID)
+			if err != nil {
+				e = e.Strs("errors", []string{err.Error()})
+			}
+			if panicked != nil {
+				e = e.Interface("panic", panicked).Str("stack", string(stack))
+			}
+			e.Msg("")
+			return nil
+		}
+	}
+}
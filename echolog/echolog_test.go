@@ -0,0 +1,59 @@
+package echolog
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phuslu/log"
+)
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	e := echo.New()
+	e.Use(Middleware(&log.Logger{Writer: &buf}))
+	e.GET("/users/:id", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+	e.GET("/error", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad input")
+	})
+	e.GET("/fail", func(c echo.Context) error {
+		return errors.New("db is down")
+	})
+	e.GET("/panic", func(c echo.Context) error {
+		panic("boom")
+	})
+
+	cases := []struct {
+		Path   string
+		Status int
+		JSON   []string
+	}{
+		{"/users/42?a=1", 200, []string{`"level":"info","method":"GET","path":"/users/42","query":"a=1","route":"/users/:id","status":200,"size":2,"duration":`, `"remote_ip":"192.0.2.1","user_agent":"test","request_id":"abc"}`}},
+		{"/error", 400, []string{`"level":"warn"`, `"route":"/error","status":400`, `"errors":["code=400, message=bad input"]`}},
+		{"/fail", 500, []string{`"level":"error"`, `"status":500`, `"errors":["db is down"]`}},
+		{"/panic", 500, []string{`"level":"error"`, `"status":500`, `"panic":`, `"stack":"goroutine `}},
+	}
+
+	for _, c := range cases {
+		buf.Reset()
+		req := httptest.NewRequest("GET", c.Path, nil)
+		req.Header.Set("User-Agent", "test")
+		req.Header.Set("X-Request-Id", "abc")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != c.Status {
+			t.Errorf("%s got status %d, want %d", c.Path, rec.Code, c.Status)
+		}
+		for _, s := range c.JSON {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("%s output %s does not contain %s", c.Path, buf.String(), s)
+			}
+		}
+	}
+}
@@ -1,12 +1,18 @@
 package log
 
 import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 // FileWriter is an io.WriteCloser that writes to the specified filename.
@@ -29,16 +35,19 @@ import (
 // time.Time format of `2006-01-02T15-04-05` and the extension is the
 // original extension.  For example, if your FileWriter.Filename is
 // `/var/log/foo/server.log`, a backup created at 6:30pm on Nov 11 2016 would
-// use the filename `/var/log/foo/server.2016-11-04T18-30-00.log`
+// use the filename `/var/log/foo/server.2016-11-04T18-30-00.log`. If the timestamp
+// is the same as the one of the previous log file, a sequence number is added after
+// it, e.g. `/var/log/foo/server.2016-11-04T18-30-00.1.log`.
 //
 // Cleaning Up Old Log Files
 //
 // Whenever a new logfile gets created, old log files may be deleted.  The most
 // recent files according to the encoded timestamp will be retained, up to a
 // number equal to MaxBackups (or all of them if MaxBackups is 0).  Any files
-// with an encoded timestamp older than MaxAge days are deleted, regardless of
-// MaxBackups.  Note that the time encoded in the timestamp is the rotation
-// time, which may differ from the last time that file was written to.
+// last modified longer than MaxAge ago are deleted, regardless of MaxBackups.
+// Only the files named in the pattern of FileWriter, optionally with the `.gz`
+// extension of Compress, are deleted.  The errors of compressing and deleting
+// are reported to ErrorHandler.
 type FileWriter struct {
 	// Filename is the file to write logs to.  Backup log files will be retained
 	// in the same directory.
@@ -52,10 +61,20 @@ type FileWriter struct {
 	// is to retain all old log files
 	MaxBackups int
 
+	// MaxTotalSize is the maximum total size in bytes of the old log files, the
+	// oldest ones are deleted after rotation until the total size is under it.
+	// The current log file is never deleted. The default is unlimited.
+	MaxTotalSize int64
+
 	// make aligncheck happy
-	mu   sync.Mutex
-	size int64
-	file *os.File
+	mu       sync.Mutex
+	size     int64
+	checked  int64
+	fallen   int64
+	next     int64
+	files    int64
+	failures int
+	file     *os.File
 
 	// FileMode represents the file's mode and permission bits.  The default
 	// mode is 0644
@@ -68,6 +87,43 @@ type FileWriter struct {
 
 	// HostName determines if the hostname used for formatting in backup files.
 	HostName bool
+
+	// TimeFormat specifies the time format of the timestamp in the log file names,
+	// e.g. "2006-01-02" for the daily files like "app.2024-05-01.log". The old log
+	// files are matched by it, and the files rotated by MaxSize within a timestamp
+	// are numbered, e.g. "app.2024-05-01.1.log". It uses "2006-01-02T15-04-05" in if empty.
+	TimeFormat string
+
+	// RotateInterval specifies the period of a log file, e.g. 24 * time.Hour for the
+	// daily files. The log file is rotated when the period changes, the periods are
+	// aligned to the clock selected by LocalTime. The default is to rotate by MaxSize only.
+	RotateInterval time.Duration
+
+	// Compress determines if the previous log file is compressed by gzip in the
+	// background after it is rotated, the original file is removed on success.
+	Compress bool
+
+	// MaxAge is the maximum time to retain the old log files since they were last
+	// modified. The default is to not remove old log files based on age.
+	MaxAge time.Duration
+
+	// OnRotate is called asynchronously with the path of the previous log file after
+	// it is rotated and compressed, e.g. to upload or checksum it. A panic in OnRotate
+	// is recovered and reported to ErrorHandler.
+	OnRotate func(oldPath string)
+
+	// Fallback specifies the writer used after FallbackAfter consecutive write
+	// failures of the log file, the log file is retried periodically and used
+	// again once it recovers. It uses os.Stderr in if empty.
+	Fallback io.Writer
+
+	// FallbackAfter is the number of consecutive write failures before switching
+	// to Fallback. The default is to never fall back.
+	FallbackAfter int
+
+	// ErrorHandler is called with the errors of FileWriter, e.g. those of compressing
+	// and deleting the old log files in the background. It uses the package ErrorHandler in if nil.
+	ErrorHandler func(err error)
 }
 
 // Write implements io.FileWriter.  If a write would cause the log file to be larger
@@ -75,24 +131,107 @@ type FileWriter struct {
 // current time, and a new log file is created using the original log file name.
 // If the length of the write is greater than MaxSize, an error is returned.
 func (w *FileWriter) Write(p []byte) (n int, err error) {
+	return w.writeFunc(p, nil)
+}
+
+// writeFunc writes p like Write. If encode is not nil, it is called with the number
+// of the log files opened so far and p after the log file is switched by check or
+// RotateInterval, and its result is written to the log file instead of p, e.g. by
+// HMACWriter to restart its chain in a new log file.
+func (w *FileWriter) writeFunc(p []byte, encode func(files int64, p []byte) []byte) (n int, err error) {
 	w.mu.Lock()
 
+	if w.FallbackAfter <= 0 {
+		n, err = w.write(p, encode)
+		w.mu.Unlock()
+		return
+	}
+
+	sec, nsec := walltime()
+	now := sec*int64(time.Second) + int64(nsec)
+	if w.fallen != 0 && now-w.fallen < fileRetryInterval {
+		n, err = w.fallback().Write(p)
+		w.mu.Unlock()
+		return
+	}
+
+	n, err = w.write(p, encode)
+	if err == nil {
+		if w.fallen != 0 {
+			w.fallen = 0
+			logger := Logger{Writer: w.fallback()}
+			logger.Info().Str("filename", w.Filename).Msg("log file writer recovers")
+			w.reportError(fmt.Errorf("log: FileWriter %s recovers", w.Filename))
+		}
+		w.failures = 0
+		w.mu.Unlock()
+		return
+	}
+
+	w.failures++
+	if w.fallen == 0 && w.failures < w.FallbackAfter {
+		w.mu.Unlock()
+		return
+	}
+
+	if w.fallen == 0 {
+		logger := Logger{Writer: w.fallback()}
+		logger.Error().Err(err).Str("filename", w.Filename).Int("failures", w.failures).Msg("log file writer falls back")
+		w.reportError(fmt.Errorf("log: FileWriter %s falls back after %d failures: %v", w.Filename, w.failures, err))
+	}
+	w.fallen = now
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+
+	n, err = w.fallback().Write(p)
+	w.mu.Unlock()
+	return
+}
+
+// fileRetryInterval is the interval to retry the log file after falling back.
+var fileRetryInterval = int64(10 * time.Second)
+
+func (w *FileWriter) fallback() io.Writer {
+	if w.Fallback != nil {
+		return w.Fallback
+	}
+	return os.Stderr
+}
+
+func (w *FileWriter) write(p []byte, encode func(files int64, p []byte) []byte) (n int, err error) {
 	if w.file == nil {
 		if w.Filename == "" {
 			n, err = os.Stderr.Write(p)
-			w.mu.Unlock()
 			return
 		}
 		err = w.create()
 		if err != nil {
-			w.mu.Unlock()
 			return
 		}
+	} else if w.Filename != "" {
+		err = w.check()
+		if err != nil {
+			return
+		}
+		if w.RotateInterval > 0 {
+			sec, nsec := walltime()
+			if sec*int64(time.Second)+int64(nsec) >= w.next {
+				err = w.rotate()
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	if encode != nil {
+		p = encode(w.files, p)
 	}
 
 	n, err = w.file.Write(p)
 	if err != nil {
-		w.mu.Unlock()
 		return
 	}
 
@@ -101,10 +240,36 @@ func (w *FileWriter) Write(p []byte) (n int, err error) {
 		err = w.rotate()
 	}
 
-	w.mu.Unlock()
 	return
 }
 
+// fileCheckInterval is the minimum interval to check whether the log file
+// was moved or removed by others.
+var fileCheckInterval = int64(time.Second)
+
+// check reopens the log file if it was moved or removed by others, e.g. logrotate.
+// It stats the file at most once per fileCheckInterval to keep the cost amortized.
+func (w *FileWriter) check() (err error) {
+	sec, nsec := walltime()
+	now := sec*int64(time.Second) + int64(nsec)
+	if now-w.checked < fileCheckInterval {
+		return
+	}
+	w.checked = now
+
+	fi1, err1 := os.Stat(w.Filename)
+	fi2, err2 := w.file.Stat()
+	if err1 == nil && err2 == nil && os.SameFile(fi1, fi2) {
+		return
+	}
+
+	w.file.Close()
+	w.file = nil
+	w.size = 0
+
+	return w.create()
+}
+
 // Close implements io.Closer, and closes the current logfile.
 func (w *FileWriter) Close() (err error) {
 	w.mu.Lock()
@@ -117,11 +282,35 @@ func (w *FileWriter) Close() (err error) {
 	return
 }
 
+// Describe implements WriterDescriber.
+func (w *FileWriter) Describe() string {
+	s := fmt.Sprintf("FileWriter{Filename:%q MaxSize:%d MaxBackups:%d", w.Filename, w.MaxSize, w.MaxBackups)
+	if w.MaxTotalSize != 0 {
+		s += fmt.Sprintf(" MaxTotalSize:%d", w.MaxTotalSize)
+	}
+	if w.RotateInterval != 0 {
+		s += fmt.Sprintf(" RotateInterval:%s", w.RotateInterval)
+	}
+	if w.Compress {
+		s += " Compress:true"
+	}
+	if w.MaxAge != 0 {
+		s += fmt.Sprintf(" MaxAge:%s", w.MaxAge)
+	}
+	if w.FallbackAfter != 0 {
+		s += fmt.Sprintf(" FallbackAfter:%d Fallback:%s", w.FallbackAfter, describeWriter(w.Fallback))
+	}
+	return s + "}"
+}
+
 // Rotate causes Logger to close the existing log file and immediately create a
 // new one.  This is a helper function for applications that want to initiate
 // rotations outside of the normal rotation rules, such as in response to
 // SIGHUP.  After rotating, this initiates compression and removal of old log
-// files according to the configuration.
+// files according to the configuration. It is safe to call concurrently with Write.
+//
+// It is not needed after the log file is moved or removed by others, e.g. the
+// `mv` of logrotate, since Write reopens the file in that case.
 func (w *FileWriter) Rotate() (err error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -131,25 +320,26 @@ func (w *FileWriter) Rotate() (err error) {
 }
 
 func (w *FileWriter) rotate() (err error) {
+	var oldname string
 	if w.file != nil {
+		oldname = w.file.Name()
 		err = w.file.Close()
 		if err != nil {
 			return
 		}
 	}
 
-	now := timeNow()
-	if !w.LocalTime {
-		now = now.UTC()
-	}
-
+	now := w.now()
 	ext := filepath.Ext(w.Filename)
 	prefix := w.Filename[0 : len(w.Filename)-len(ext)]
-	filename := prefix + now.Format(".2006-01-02T15-04-05")
-	if w.HostName {
-		filename += "." + hostname + ext
-	} else {
-		filename += ext
+	filename := w.filename(now, 0)
+	// the timestamp of TimeFormat may not change between two rotations, e.g. the
+	// daily files rotated by MaxSize, so the files of a timestamp are numbered.
+	for seq := 1; ; seq++ {
+		if _, err := os.Lstat(filename); filename != oldname && os.IsNotExist(err) {
+			break
+		}
+		filename = w.filename(now, seq)
 	}
 
 	var perm = w.FileMode
@@ -159,11 +349,13 @@ func (w *FileWriter) rotate() (err error) {
 
 	w.file, err = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
 	w.size = 0
+	w.next = w.period(now)
+	w.files++
 
-	go func(filename string) {
-		os.Remove(w.Filename)
-		os.Symlink(filename, w.Filename)
+	os.Remove(w.Filename)
+	os.Symlink(filename, w.Filename)
 
+	go func(filename, oldname string) {
 		switch runtime.GOOS {
 		case "linux":
 			uid, _ := strconv.Atoi(os.Getenv("SUDO_UID"))
@@ -174,44 +366,218 @@ func (w *FileWriter) rotate() (err error) {
 			}
 		}
 
-		var matches []string
-		matches, err = filepath.Glob(prefix + ".20*" + ext)
+		if oldname != "" && oldname != filename {
+			if w.Compress {
+				if err := compressFile(oldname); err != nil {
+					w.reportError(fmt.Errorf("log: FileWriter compress %s error: %v", oldname, err))
+				} else {
+					oldname += ".gz"
+				}
+			}
+			if w.OnRotate != nil {
+				w.onRotate(oldname)
+			}
+		}
+
+		matches, err := w.backups(prefix, ext)
 		if err != nil {
+			w.reportError(fmt.Errorf("log: FileWriter list backups error: %v", err))
 			return
 		}
 
-		sort.Strings(matches)
 		for i := 0; i < len(matches)-w.MaxBackups-1; i++ {
-			os.Remove(matches[i])
+			if err = os.Remove(matches[i]); err != nil && !os.IsNotExist(err) {
+				w.reportError(fmt.Errorf("log: FileWriter remove %s error: %v", matches[i], err))
+			}
+		}
+
+		if w.MaxAge > 0 {
+			expired := timeNow().Add(-w.MaxAge)
+			for _, name := range matches {
+				if fi, err := os.Stat(name); err == nil && name != filename && fi.ModTime().Before(expired) {
+					if err = os.Remove(name); err != nil && !os.IsNotExist(err) {
+						w.reportError(fmt.Errorf("log: FileWriter remove expired %s error: %v", name, err))
+					}
+				}
+			}
+		}
+
+		if w.MaxTotalSize > 0 {
+			if matches, err = w.backups(prefix, ext); err == nil {
+				removeOverTotalSize(matches, filename, w.MaxTotalSize)
+			}
 		}
-	}(filename)
+	}(filename, oldname)
 
 	return
 }
 
+// backups returns the log files of the writer from the oldest to the newest, i.e.
+// the files named by the timestamp of TimeFormat between prefix and ext, and their
+// compressed files.
+func (w *FileWriter) backups(prefix, ext string) ([]string, error) {
+	names, err := filepath.Glob(prefix + ".*" + ext)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := filepath.Glob(prefix + ".*" + ext + ".gz")
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		name string
+		time time.Time
+		seq  int
+	}
+
+	var backups []backup
+	for _, name := range append(names, compressed...) {
+		if t, seq, ok := w.parseFilename(name, prefix, ext); ok {
+			backups = append(backups, backup{name, t, seq})
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].time.Equal(backups[j].time) {
+			return backups[i].time.Before(backups[j].time)
+		}
+		return backups[i].seq < backups[j].seq
+	})
+
+	matches := make([]string, len(backups))
+	for i, b := range backups {
+		matches[i] = b.name
+	}
+	return matches, nil
+}
+
+// parseFilename returns the timestamp and the sequence number of the log file name
+// made by filename, ok is false if name is not such a file.
+func (w *FileWriter) parseFilename(name, prefix, ext string) (t time.Time, seq int, ok bool) {
+	name = strings.TrimSuffix(name, ".gz")
+	if !strings.HasPrefix(name, prefix+".") || !strings.HasSuffix(name, ext) {
+		return
+	}
+	s := name[len(prefix)+1 : len(name)-len(ext)]
+
+	if i := strings.LastIndexByte(s, '.'); i > 0 {
+		if n, err := strconv.Atoi(s[i+1:]); err == nil && n > 0 && s[i+1] != '0' {
+			if t, _, ok = w.parseFilename(prefix+"."+s[:i]+ext, prefix, ext); ok {
+				return t, n, true
+			}
+		}
+	}
+
+	if w.HostName {
+		if !strings.HasSuffix(s, "."+hostname) {
+			return
+		}
+		s = s[:len(s)-len(hostname)-1]
+	}
+
+	loc := time.UTC
+	if w.LocalTime {
+		loc = time.Local
+	}
+	t, err := time.ParseInLocation(w.timeFormat(), s, loc)
+	return t, 0, err == nil
+}
+
+// compressFile compresses the file name to name.gz, and removes it on success.
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode())
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if err1 := dst.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+
+	return os.Remove(name)
+}
+
+// removeOverTotalSize removes the oldest files of matches, which are sorted from the
+// oldest by backups, until their total size is not greater than max, the active file is never removed. The files which disappear
+// concurrently, e.g. removed by another process, are skipped.
+func removeOverTotalSize(matches []string, active string, max int64) {
+	var total int64
+	sizes := make([]int64, len(matches))
+	for i, name := range matches {
+		if name == active {
+			continue
+		}
+		if fi, err := os.Stat(name); err == nil {
+			sizes[i] = fi.Size()
+			total += sizes[i]
+		}
+	}
+
+	for i := 0; i < len(matches) && total > max; i++ {
+		if matches[i] == active || sizes[i] == 0 {
+			continue
+		}
+		if err := os.Remove(matches[i]); err == nil || os.IsNotExist(err) {
+			total -= sizes[i]
+		}
+	}
+}
+
+func (w *FileWriter) onRotate(filename string) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.reportError(fmt.Errorf("log: FileWriter OnRotate(%s) panics: %v", filename, r))
+		}
+	}()
+	w.OnRotate(filename)
+}
+
+// reportError reports err to the ErrorHandler of w, or to the package ErrorHandler if it is nil.
+func (w *FileWriter) reportError(err error) {
+	h := w.ErrorHandler
+	if h == nil {
+		h = ErrorHandler
+	}
+	if h != nil {
+		h(err)
+	}
+}
+
 func (w *FileWriter) create() (err error) {
 	var filename string
 
+	now := w.now()
+	w.next = w.period(now)
+
 	if link, err := os.Readlink(w.Filename); err == nil {
-		if fi, err := os.Stat(link); err == nil {
+		// the file of a previous period is not reused.
+		if fi, err := os.Stat(link); err == nil && (w.RotateInterval <= 0 || fi.ModTime().UnixNano() >= w.next-int64(w.RotateInterval)) {
 			filename = link
 			w.size = fi.Size()
 		}
 	}
 
 	if filename == "" {
-		now := timeNow()
-		if !w.LocalTime {
-			now = now.UTC()
-		}
-		ext := filepath.Ext(w.Filename)
-		filename = w.Filename[0 : len(w.Filename)-len(ext)]
-		filename += now.Format(".2006-01-02T15-04-05")
-		if w.HostName {
-			filename += "." + hostname + ext
-		} else {
-			filename += ext
-		}
+		filename = w.filename(now, 0)
 		w.size = 0
 	}
 
@@ -224,6 +590,7 @@ func (w *FileWriter) create() (err error) {
 	if err != nil {
 		return err
 	}
+	w.files++
 
 	os.Remove(w.Filename)
 	os.Symlink(filename, w.Filename)
@@ -231,5 +598,153 @@ func (w *FileWriter) create() (err error) {
 	return
 }
 
+func (w *FileWriter) now() time.Time {
+	now := timeNow()
+	if !w.LocalTime {
+		now = now.UTC()
+	}
+	return now
+}
+
+func (w *FileWriter) timeFormat() string {
+	if w.TimeFormat != "" {
+		return w.TimeFormat
+	}
+	return "2006-01-02T15-04-05"
+}
+
+// filename returns the log file name of the time now, the sequence number seq is
+// appended if it is not zero.
+func (w *FileWriter) filename(now time.Time, seq int) string {
+	ext := filepath.Ext(w.Filename)
+	filename := w.Filename[0:len(w.Filename)-len(ext)] + "." + now.Format(w.timeFormat())
+	if w.HostName {
+		filename += "." + hostname
+	}
+	if seq != 0 {
+		filename += "." + strconv.Itoa(seq)
+	}
+	return filename + ext
+}
+
+// period returns the end of the period of now in unix nanoseconds, the periods
+// of RotateInterval are aligned to the zone of now.
+func (w *FileWriter) period(now time.Time) int64 {
+	if w.RotateInterval <= 0 {
+		return 0
+	}
+	_, offset := now.Zone()
+	interval := int64(w.RotateInterval)
+	t := now.UnixNano() + int64(offset)*int64(time.Second)
+	return (t/interval+1)*interval - int64(offset)*int64(time.Second)
+}
+
+// LevelFileWriter is an io.WriteCloser and LevelWriter that writes the events of
+// each level to a separate FileWriter, e.g. to retain the error logs longer than
+// the debug logs.
+//
+// The FileWriter of a level is created on its first event, it inherits the config
+// of FileWriter and its Filename is made by replacing the "{level}" token of the
+// Filename with the level name, e.g. "app.{level}.log" to "app.error.log".
+// The writes of different levels do not serialize on a common lock.
+type LevelFileWriter struct {
+	// FileWriter specifies the config inherited by the FileWriter of each level,
+	// its Filename should contain the "{level}" token.
+	FileWriter FileWriter
+
+	// Configure is called with the FileWriter of a level after it inherits the
+	// config, e.g. to set a different MaxBackups of the level.
+	Configure func(level Level, w *FileWriter)
+
+	mu      sync.Mutex
+	writers sync.Map // map[Level]*FileWriter
+}
+
+// Write implements io.Writer, the level is parsed from the level field of p.
+func (w *LevelFileWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(levelOf(p), p)
+}
+
+// WriteLevel implements LevelWriter.
+func (w *LevelFileWriter) WriteLevel(level Level, p []byte) (n int, err error) {
+	return w.writer(level).Write(p)
+}
+
+// Close implements io.Closer, and closes the log files of all levels.
+func (w *LevelFileWriter) Close() (err error) {
+	w.writers.Range(func(_, v interface{}) bool {
+		if err1 := v.(*FileWriter).Close(); err1 != nil && err == nil {
+			err = err1
+		}
+		return true
+	})
+	return
+}
+
+// Rotate rotates the log files of all levels.
+func (w *LevelFileWriter) Rotate() (err error) {
+	w.writers.Range(func(_, v interface{}) bool {
+		if err1 := v.(*FileWriter).Rotate(); err1 != nil && err == nil {
+			err = err1
+		}
+		return true
+	})
+	return
+}
+
+// Describe implements WriterDescriber.
+func (w *LevelFileWriter) Describe() string {
+	return "LevelFileWriter{" + w.FileWriter.Describe() + "}"
+}
+
+func (w *LevelFileWriter) writer(level Level) *FileWriter {
+	if v, ok := w.writers.Load(level); ok {
+		return v.(*FileWriter)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if v, ok := w.writers.Load(level); ok {
+		return v.(*FileWriter)
+	}
+
+	fw := &FileWriter{
+		Filename:       strings.Replace(w.FileWriter.Filename, "{level}", level.String(), -1),
+		MaxSize:        w.FileWriter.MaxSize,
+		MaxBackups:     w.FileWriter.MaxBackups,
+		MaxTotalSize:   w.FileWriter.MaxTotalSize,
+		FileMode:       w.FileWriter.FileMode,
+		LocalTime:      w.FileWriter.LocalTime,
+		HostName:       w.FileWriter.HostName,
+		TimeFormat:     w.FileWriter.TimeFormat,
+		RotateInterval: w.FileWriter.RotateInterval,
+		Compress:       w.FileWriter.Compress,
+		MaxAge:         w.FileWriter.MaxAge,
+		OnRotate:       w.FileWriter.OnRotate,
+		Fallback:       w.FileWriter.Fallback,
+		FallbackAfter:  w.FileWriter.FallbackAfter,
+	}
+	if w.Configure != nil {
+		w.Configure(level, fw)
+	}
+	w.writers.Store(level, fw)
+
+	return fw
+}
+
+// levelOf returns the level of the level field of the JSON event p.
+func levelOf(p []byte) Level {
+	i := bytes.Index(p, []byte("\"level\":\""))
+	if i < 0 {
+		return NoLevel
+	}
+	p = p[i+len("\"level\":\""):]
+	if j := bytes.IndexByte(p, '"'); j >= 0 {
+		return parseLevel(string(p[:j]))
+	}
+	return NoLevel
+}
+
 // Writer is an alias for FileWriter
 type Writer = FileWriter
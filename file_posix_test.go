@@ -0,0 +1,87 @@
+// +build !windows
+
+package log
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFileWriterRotateSIGHUP(t *testing.T) {
+	filename := "file-sighup.log"
+
+	w := &FileWriter{
+		Filename:   filename,
+		MaxBackups: 10,
+		TimeFormat: "2006-01-02T15-04-05.000000000",
+	}
+
+	// rotate the log file on SIGHUP, e.g. sent by the postrotate script of logrotate.
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+	rotated := make(chan error)
+	go func() {
+		for range sighup {
+			rotated <- w.Rotate()
+		}
+	}()
+
+	fmt.Fprintf(w, "line %d\n", 0)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i < 1000; i++ {
+			fmt.Fprintf(w, "line %d\n", i)
+		}
+	}()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("send SIGHUP error: %+v", err)
+	}
+	select {
+	case err := <-rotated:
+		if err != nil {
+			t.Fatalf("file writer rotate error: %+v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("file writer is not rotated on SIGHUP")
+	}
+
+	wg.Wait()
+	w.Close()
+
+	matches, _ := filepath.Glob("file-sighup.20*.log")
+	if len(matches) != 2 {
+		t.Errorf("file writer should write 2 files, got %v", matches)
+	}
+
+	lines := 0
+	for _, name := range matches {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("os open error: %+v", err)
+		}
+		for s := bufio.NewScanner(f); s.Scan(); {
+			lines++
+		}
+		f.Close()
+	}
+	if lines != 1000 {
+		t.Errorf("file writer should not lose lines on rotation, got %d lines", lines)
+	}
+
+	for i := range matches {
+		os.Remove(matches[i])
+	}
+	os.Remove(filename)
+}
@@ -1,10 +1,14 @@
 package log
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -165,6 +169,61 @@ func TestFileWriterRotate(t *testing.T) {
 	os.Remove(filename)
 }
 
+func TestFileWriterRotateByTime(t *testing.T) {
+	filename := "file-time.log"
+
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return time.Date(2020, 1, 1, 23, 59, 59, 0, time.UTC) }
+
+	w := &FileWriter{
+		Filename:       filename,
+		MaxBackups:     10,
+		TimeFormat:     "2006-01-02",
+		RotateInterval: 24 * time.Hour,
+	}
+
+	if _, err := fmt.Fprint(w, "day 1\n"); err != nil {
+		t.Fatalf("file writer error: %+v", err)
+	}
+	if want := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).UnixNano(); w.next != want {
+		t.Errorf("file writer period ends at %v, want %v", time.Unix(0, w.next).UTC(), time.Unix(0, want).UTC())
+	}
+
+	// the wall clock is past the period, so the next write rolls to the file of the new day.
+	timeNow = func() time.Time { return time.Date(2020, 1, 2, 0, 0, 1, 0, time.UTC) }
+	if _, err := fmt.Fprint(w, "day 2\n"); err != nil {
+		t.Fatalf("file writer error: %+v", err)
+	}
+	w.Close()
+
+	for name, text := range map[string]string{
+		"file-time.2020-01-01.log": "day 1\n",
+		"file-time.2020-01-02.log": "day 2\n",
+	} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ioutil read file error: %+v", err)
+		}
+		if string(data) != text {
+			t.Errorf("file %s content got %q, want %q", name, data, text)
+		}
+	}
+	if link, err := os.Readlink(filename); err != nil || link != "file-time.2020-01-02.log" {
+		t.Errorf("file writer link got %s, want file-time.2020-01-02.log: %+v", link, err)
+	}
+
+	zone := time.FixedZone("UTC+8", 8*3600)
+	w = &FileWriter{RotateInterval: 24 * time.Hour, LocalTime: true}
+	if got, want := w.period(time.Date(2020, 1, 1, 23, 0, 0, 0, zone)), time.Date(2020, 1, 2, 0, 0, 0, 0, zone).UnixNano(); got != want {
+		t.Errorf("local period ends at %v, want %v", time.Unix(0, got).In(zone), time.Unix(0, want).In(zone))
+	}
+
+	matches, _ := filepath.Glob("file-time.*")
+	for i := range matches {
+		os.Remove(matches[i])
+	}
+}
+
 func TestFileWriterRotateBySize(t *testing.T) {
 	filename := "file-rotate-by-size.log"
 	text := "hello file writer!\n"
@@ -205,7 +264,7 @@ func TestFileWriterRotateBySize(t *testing.T) {
 		t.Fatalf("filepath glob return %+v number mismath", matches)
 	}
 
-	// text 3 & 4 & 5
+	// text 3 & 4 & 5, the file rotated in the same second is numbered.
 	for i := 3; i <= 5; i++ {
 		_, err = fmt.Fprintf(w, text)
 		if err != nil {
@@ -217,7 +276,7 @@ func TestFileWriterRotateBySize(t *testing.T) {
 	if err != nil {
 		t.Fatalf("filepath glob error: %+v", err)
 	}
-	if len(matches) != w.MaxBackups {
+	if len(matches) != w.MaxBackups+1 {
 		t.Fatalf("filepath glob return %+v number mismath", matches)
 	}
 
@@ -233,6 +292,72 @@ func TestFileWriterRotateBySize(t *testing.T) {
 	os.Remove(filename)
 }
 
+func TestFileWriterTimeFormatMaxSize(t *testing.T) {
+	filename := "file-daily.log"
+	text := "hello file writer!\n"
+
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC) }
+
+	w := &FileWriter{
+		Filename:   filename,
+		MaxSize:    int64(len(text)) - 1,
+		MaxBackups: 10,
+		TimeFormat: "2006-01-02",
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := fmt.Fprint(w, text); err != nil {
+			t.Fatalf("file writer error: %+v", err)
+		}
+	}
+	w.Close()
+
+	for _, name := range []string{"file-daily.2020-01-01.log", "file-daily.2020-01-01.1.log", "file-daily.2020-01-01.2.log"} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ioutil read file error: %+v", err)
+		}
+		if string(data) != text {
+			t.Errorf("file %s content got %q, want %q", name, data, text)
+		}
+	}
+
+	matches, _ := filepath.Glob("file-daily.*")
+	for i := range matches {
+		os.Remove(matches[i])
+	}
+	os.Remove(filename)
+}
+
+func TestFileWriterBackupsTimeFormat(t *testing.T) {
+	w := &FileWriter{
+		Filename:   "file-backups.log",
+		TimeFormat: "01-02-2006",
+	}
+
+	names := []string{
+		"file-backups.12-31-2019.log.gz",
+		"file-backups.01-01-2020.log",
+		"file-backups.01-01-2020.2.log",
+		"file-backups.01-01-2020.10.log",
+		"file-backups.01-02-2020.log",
+	}
+	for _, name := range append([]string{"file-backups.error.01-01-2020.log", "file-backups.01-01-2020.x.log"}, names...) {
+		if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+			t.Fatalf("write file %s error: %+v", name, err)
+		}
+		defer os.Remove(name)
+	}
+
+	matches, err := w.backups("file-backups", ".log")
+	if err != nil {
+		t.Fatalf("file writer backups error: %+v", err)
+	}
+	if fmt.Sprint(matches) != fmt.Sprint(names) {
+		t.Errorf("file writer backups got %v, want %v", matches, names)
+	}
+}
+
 func TestFileWriterBackups(t *testing.T) {
 	filename := "file-backup.log"
 
@@ -266,3 +391,457 @@ func TestFileWriterBackups(t *testing.T) {
 
 	os.Remove(filename)
 }
+
+func TestFileWriterReopen(t *testing.T) {
+	filename := "file-reopen.log"
+	text1 := "hello file writer!\n"
+	text2 := "hello reopened file writer!\n"
+
+	interval := fileCheckInterval
+	fileCheckInterval = 0
+	defer func() { fileCheckInterval = interval }()
+
+	w := &FileWriter{
+		Filename: filename,
+	}
+
+	for _, remove := range []bool{false, true} {
+		_, err := fmt.Fprint(w, text1)
+		if err != nil {
+			t.Fatalf("file writer error: %+v", err)
+		}
+
+		link, err := os.Readlink(filename)
+		if err != nil {
+			t.Fatalf("os readlink error: %+v", err)
+		}
+
+		moved := "file-reopen.moved.log"
+		if remove {
+			err = os.Remove(link)
+		} else {
+			err = os.Rename(link, moved)
+		}
+		if err != nil {
+			t.Fatalf("os rename/remove error: %+v", err)
+		}
+
+		_, err = fmt.Fprint(w, text2)
+		if err != nil {
+			t.Fatalf("file writer error: %+v", err)
+		}
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("ioutil read file error: %+v", err)
+		}
+		if string(data) != text2 {
+			t.Fatalf("reopened file content mismatch: data=[%s], text2=[%s]", data, text2)
+		}
+
+		if !remove {
+			data, err = ioutil.ReadFile(moved)
+			if err != nil {
+				t.Fatalf("ioutil read file error: %+v", err)
+			}
+			if string(data) != text1 {
+				t.Fatalf("moved file content mismatch: data=[%s], text1=[%s]", data, text1)
+			}
+			os.Remove(moved)
+		}
+
+		w.Close()
+		link, _ = os.Readlink(filename)
+		os.Remove(link)
+		os.Remove(filename)
+	}
+}
+
+func TestFileWriterFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-fallback-")
+	if err != nil {
+		t.Fatalf("create temp dir error: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var errs []error
+	defer func(h func(error)) { ErrorHandler = h }(ErrorHandler)
+	ErrorHandler = func(err error) { errs = append(errs, err) }
+
+	interval := fileRetryInterval
+	defer func() { fileRetryInterval = interval }()
+
+	var fallback bytes.Buffer
+	w := &FileWriter{
+		Filename:      filepath.Join(dir, "logs", "file-fallback.log"),
+		Fallback:      &fallback,
+		FallbackAfter: 2,
+	}
+
+	_, err = fmt.Fprint(w, "line 1\n")
+	if err == nil || fallback.Len() != 0 || len(errs) != 0 {
+		t.Fatalf("file writer should fail without fallback, err=%+v fallback=%s", err, fallback.Bytes())
+	}
+
+	for _, line := range []string{"line 2\n", "line 3\n"} {
+		_, err = fmt.Fprint(w, line)
+		if err != nil {
+			t.Fatalf("file writer should fall back, err=%+v", err)
+		}
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "falls back after 2 failures") {
+		t.Fatalf("file writer error handler got %+v", errs)
+	}
+
+	err = os.Mkdir(filepath.Join(dir, "logs"), 0755)
+	if err != nil {
+		t.Fatalf("os mkdir error: %+v", err)
+	}
+	fileRetryInterval = 0
+
+	_, err = fmt.Fprint(w, "line 4\n")
+	if err != nil {
+		t.Fatalf("file writer should recover, err=%+v", err)
+	}
+	if len(errs) != 2 || !strings.Contains(errs[1].Error(), "recovers") {
+		t.Fatalf("file writer error handler got %+v", errs)
+	}
+	w.Close()
+
+	lines := strings.Split(strings.TrimSpace(fallback.String()), "\n")
+	if len(lines) != 4 ||
+		!strings.Contains(lines[0], `"message":"log file writer falls back"`) ||
+		lines[1] != "line 2" || lines[2] != "line 3" ||
+		!strings.Contains(lines[3], `"message":"log file writer recovers"`) {
+		t.Fatalf("fallback content mismatch: %s", fallback.Bytes())
+	}
+
+	data, err := ioutil.ReadFile(w.Filename)
+	if err != nil {
+		t.Fatalf("ioutil read file error: %+v", err)
+	}
+	if string(data) != "line 4\n" {
+		t.Fatalf("recovered file content mismatch: %s", data)
+	}
+}
+
+func TestFileWriterOnRotate(t *testing.T) {
+	filename := "file-onrotate.log"
+
+	errs := make(chan error, 1)
+	defer func(h func(error)) { ErrorHandler = h }(ErrorHandler)
+	ErrorHandler = func(err error) { errs <- err }
+
+	rotated := make(chan string, 2)
+	w := &FileWriter{
+		Filename:   filename,
+		MaxBackups: 2,
+		OnRotate: func(oldPath string) {
+			rotated <- oldPath
+			panic("onrotate panic")
+		},
+	}
+
+	_, err := fmt.Fprint(w, "hello file writer!\n")
+	if err != nil {
+		t.Fatalf("file writer error: %+v", err)
+	}
+	link, err := os.Readlink(filename)
+	if err != nil {
+		t.Fatalf("os readlink error: %+v", err)
+	}
+
+	time.Sleep(time.Second)
+	w.Rotate()
+
+	select {
+	case oldPath := <-rotated:
+		if oldPath != link {
+			t.Fatalf("file writer OnRotate got %s, want %s", oldPath, link)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("file writer OnRotate is not called")
+	}
+	if err := <-errs; !strings.Contains(err.Error(), "onrotate panic") {
+		t.Fatalf("file writer error handler got %+v", err)
+	}
+
+	w.Close()
+
+	matches, _ := filepath.Glob("file-onrotate.*.log")
+	for i := range matches {
+		os.Remove(matches[i])
+	}
+	os.Remove(filename)
+}
+
+func TestFileWriterErrorHandler(t *testing.T) {
+	filename := "file-errorhandler.log"
+
+	defer func(h func(error)) { ErrorHandler = h }(ErrorHandler)
+	ErrorHandler = func(err error) { t.Errorf("package error handler should not be called: %+v", err) }
+
+	errs := make(chan error, 1)
+	w := &FileWriter{
+		Filename:     filename,
+		MaxBackups:   2,
+		OnRotate:     func(string) { panic("onrotate panic") },
+		ErrorHandler: func(err error) { errs <- err },
+	}
+
+	fmt.Fprint(w, "hello file writer!\n")
+	time.Sleep(time.Second)
+	w.Rotate()
+
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "onrotate panic") {
+			t.Errorf("file writer error handler got %+v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Errorf("file writer error handler is not called")
+	}
+
+	w.Close()
+
+	matches, _ := filepath.Glob("file-errorhandler.*.log")
+	for i := range matches {
+		os.Remove(matches[i])
+	}
+	os.Remove(filename)
+}
+
+func TestFileWriterCompress(t *testing.T) {
+	filename := "file-compress.log"
+
+	// an expired log file of the writer, and the unrelated files which must be kept.
+	expired := "file-compress.2020-01-01T00-00-00.000000000.log.gz"
+	unrelated := []string{"file-compress.other.log", "file-compress.2020-01-01.txt"}
+	for _, name := range append(unrelated, expired) {
+		if err := ioutil.WriteFile(name, []byte("old"), 0644); err != nil {
+			t.Fatalf("write file %s error: %+v", name, err)
+		}
+		old := timeNow().Add(-48 * time.Hour)
+		if err := os.Chtimes(name, old, old); err != nil {
+			t.Fatalf("chtimes %s error: %+v", name, err)
+		}
+	}
+
+	rotated := make(chan string, 1)
+	w := &FileWriter{
+		Filename:   filename,
+		MaxBackups: 10,
+		TimeFormat: "2006-01-02T15-04-05.000000000",
+		Compress:   true,
+		MaxAge:     24 * time.Hour,
+		OnRotate:   func(oldPath string) { rotated <- oldPath },
+	}
+
+	if _, err := fmt.Fprint(w, "hello compressed file writer!\n"); err != nil {
+		t.Fatalf("file writer error: %+v", err)
+	}
+	link, err := os.Readlink(filename)
+	if err != nil {
+		t.Fatalf("os readlink error: %+v", err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatalf("file writer rotate error: %+v", err)
+	}
+
+	select {
+	case oldPath := <-rotated:
+		if oldPath != link+".gz" {
+			t.Fatalf("file writer OnRotate got %s, want %s.gz", oldPath, link)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("file writer OnRotate is not called")
+	}
+
+	if _, err := os.Stat(link); !os.IsNotExist(err) {
+		t.Errorf("the compressed file %s should be removed: %+v", link, err)
+	}
+	f, err := os.Open(link + ".gz")
+	if err != nil {
+		t.Fatalf("os open error: %+v", err)
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip reader error: %+v", err)
+	}
+	if data, err := ioutil.ReadAll(zr); err != nil || string(data) != "hello compressed file writer!\n" {
+		t.Errorf("compressed file content got %q: %+v", data, err)
+	}
+	f.Close()
+
+	for i := 0; i < 30; i++ {
+		if _, err := os.Stat(expired); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Errorf("the expired file %s should be removed: %+v", expired, err)
+	}
+	for _, name := range unrelated {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("the unrelated file %s should be kept: %+v", name, err)
+		}
+	}
+
+	w.Close()
+
+	matches, _ := filepath.Glob("file-compress.*")
+	for i := range matches {
+		os.Remove(matches[i])
+	}
+	os.Remove(filename)
+}
+
+func TestFileWriterMaxTotalSize(t *testing.T) {
+	filename := "file-totalsize.log"
+
+	// the rotated files of previous runs, the last one is compressed.
+	olds := []string{
+		"file-totalsize.2020-01-01T00-00-00.log",
+		"file-totalsize.2020-01-02T00-00-00.log",
+		"file-totalsize.2020-01-03T00-00-00.log",
+		"file-totalsize.2020-01-04T00-00-00.log",
+		"file-totalsize.2020-01-05T00-00-00.log.gz",
+	}
+	for _, name := range olds {
+		if err := ioutil.WriteFile(name, make([]byte, 100), 0644); err != nil {
+			t.Fatalf("write file %s error: %+v", name, err)
+		}
+	}
+
+	w := &FileWriter{
+		Filename:     filename,
+		MaxBackups:   10,
+		MaxTotalSize: 250,
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatalf("file writer rotate error: %+v", err)
+	}
+	link, err := os.Readlink(filename)
+	if err != nil {
+		t.Fatalf("os readlink error: %+v", err)
+	}
+
+	for i := 0; i < 30; i++ {
+		if _, err := os.Stat(olds[2]); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	for i, name := range olds {
+		_, err := os.Stat(name)
+		if exists := err == nil; exists != (i >= 3) {
+			t.Errorf("rotated file %s exists=%v, want %v", name, exists, i >= 3)
+		}
+	}
+	if _, err := os.Stat(link); err != nil {
+		t.Errorf("the active file %s should not be removed: %+v", link, err)
+	}
+
+	w.Close()
+
+	matches, _ := filepath.Glob("file-totalsize.*")
+	for i := range matches {
+		os.Remove(matches[i])
+	}
+}
+
+func TestRemoveOverTotalSize(t *testing.T) {
+	names := []string{
+		"file-removetotal.2020-01-03T00-00-00.log",
+		"file-removetotal.2020-01-01T00-00-00.log",
+		"file-removetotal.2020-01-02T00-00-00.log",
+	}
+	for i, name := range names {
+		if err := ioutil.WriteFile(name, make([]byte, 10*(i+1)), 0644); err != nil {
+			t.Fatalf("write file %s error: %+v", name, err)
+		}
+	}
+
+	// a file removed concurrently and the active file are skipped.
+	matches := []string{"file-removetotal.2019-12-31T00-00-00.log", names[1], names[2], names[0]}
+	removeOverTotalSize(matches, names[1], 15)
+
+	for i, name := range names {
+		_, err := os.Stat(name)
+		if exists := err == nil; exists != (i != 2) {
+			t.Errorf("file %s exists=%v, want %v", name, exists, i != 2)
+		}
+		os.Remove(name)
+	}
+}
+
+func TestLevelFileWriter(t *testing.T) {
+	if nodebug {
+		t.Skip("debug events are eliminated by log_nodebug")
+	}
+
+	w := &LevelFileWriter{
+		FileWriter: FileWriter{
+			Filename:   "file-level.{level}.log",
+			MaxBackups: 1,
+		},
+		Configure: func(level Level, w *FileWriter) {
+			if level >= ErrorLevel {
+				w.MaxBackups = 90
+			}
+		},
+	}
+
+	logger := Logger{
+		Level:  DebugLevel,
+		Writer: w,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger.Debug().Int("i", i).Msg("debug")
+			logger.Error().Int("i", i).Msg("error")
+		}(i)
+	}
+	wg.Wait()
+	fmt.Fprint(w, `{"level":"warn","message":"warn"}`+"\n")
+	w.Close()
+
+	for _, c := range []struct {
+		Level      Level
+		Name       string
+		Lines      int
+		MaxBackups int
+	}{
+		{DebugLevel, "debug", 10, 1},
+		{ErrorLevel, "error", 10, 90},
+		{WarnLevel, "warn", 1, 1},
+	} {
+		filename := "file-level." + c.Name + ".log"
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("read level file %s error: %+v", filename, err)
+		}
+		if n := strings.Count(string(data), `"level":"`+c.Name+`"`); n != c.Lines {
+			t.Errorf("level file %s got %d lines, want %d: %s", filename, n, c.Lines, data)
+		}
+		if fw := w.writer(c.Level); fw.MaxBackups != c.MaxBackups {
+			t.Errorf("level file %s got MaxBackups %d, want %d", filename, fw.MaxBackups, c.MaxBackups)
+		}
+
+		matches, _ := filepath.Glob("file-level." + c.Name + ".*.log")
+		for i := range matches {
+			os.Remove(matches[i])
+		}
+		os.Remove(filename)
+	}
+
+	if _, ok := w.writers.Load(InfoLevel); ok {
+		t.Errorf("level file of info should not be opened")
+	}
+}
@@ -0,0 +1,244 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// GELFCompression defines the compression of GELF messages sent over UDP.
+type GELFCompression int
+
+const (
+	// GELFNoCompression sends the messages uncompressed.
+	GELFNoCompression GELFCompression = iota
+	// GELFGzip compresses the messages by gzip.
+	GELFGzip
+	// GELFZlib compresses the messages by zlib.
+	GELFZlib
+)
+
+// GELFWriter is an io.WriteCloser and LevelWriter that sends the events to Graylog
+// in the GELF 1.1 format. The "message" field of an event is sent as short_message,
+// the "time" field as timestamp, the level as the syslog severity like SyslogWriter,
+// and the other fields are sent as the additional fields prefixed by "_".
+//
+// Over UDP, a message larger than ChunkSize is split into GELF chunks. Over TCP, the
+// messages are delimited by a null byte and are not compressed.
+type GELFWriter struct {
+	// Network specifies the network of the Graylog input, "udp" or "tcp". It uses "udp" in if empty.
+	Network string
+
+	// Address specifies the address of the Graylog input.
+	Address string
+
+	// Hostname specifies the host field. It uses os.Hostname() in if empty.
+	Hostname string
+
+	// Compression specifies the compression of the messages sent over UDP.
+	Compression GELFCompression
+
+	// ChunkSize specifies the maximum size of a UDP datagram. It uses 1420 in if empty.
+	ChunkSize int
+
+	mu    sync.Mutex
+	conn  net.Conn
+	buf   bytes.Buffer
+	chunk []byte
+}
+
+// gelfMessageID is the sequence of the chunked message ids.
+var gelfMessageID = uint64(time.Now().UnixNano())
+
+// Write implements io.Writer, the level is parsed from the level field of p.
+func (w *GELFWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(levelOf(p), p)
+}
+
+// WriteLevel implements LevelWriter.
+func (w *GELFWriter) WriteLevel(level Level, p []byte) (n int, err error) {
+	msg, err := w.encode(level, p)
+	if err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		network, address := w.network()
+		if w.conn, err = net.Dial(network, address); err != nil {
+			w.conn = nil
+			return 0, fmt.Errorf("log: GELFWriter dial error: %v", err)
+		}
+	}
+
+	if network, _ := w.network(); network == "udp" {
+		err = w.sendUDP(msg)
+	} else {
+		_, err = w.conn.Write(append(msg, 0))
+	}
+	if err != nil {
+		w.conn.Close()
+		w.conn = nil
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close implements io.Closer, and closes the connection.
+func (w *GELFWriter) Close() (err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		err = w.conn.Close()
+		w.conn = nil
+	}
+	return
+}
+
+// Describe implements WriterDescriber.
+func (w *GELFWriter) Describe() string {
+	network, address := w.network()
+	return fmt.Sprintf("GELFWriter{Network:%s Address:%s Compression:%d}", network, address, w.Compression)
+}
+
+func (w *GELFWriter) network() (network, address string) {
+	network = w.Network
+	if network == "" {
+		network = "udp"
+	}
+	return network, w.Address
+}
+
+// encode returns the GELF message of the event p.
+func (w *GELFWriter) encode(level Level, p []byte) ([]byte, error) {
+	var m map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	if decoder.Decode(&m) != nil {
+		m = map[string]interface{}{"message": string(bytes.TrimSuffix(p, []byte{'\n'}))}
+	}
+
+	host := w.Hostname
+	if host == "" {
+		host = hostname
+	}
+
+	now := timeNow()
+	if s, ok := m["time"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			now = t
+		}
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10) + "." + strconv.FormatInt(int64(now.Nanosecond()/1000)+1000000, 10)[1:]
+
+	msg := map[string]interface{}{
+		"version":       "1.1",
+		"host":          host,
+		"short_message": "",
+		"timestamp":     json.Number(timestamp),
+		"level":         syslogSeverity(level),
+	}
+	for k, v := range m {
+		switch k {
+		case "time", "level":
+			continue
+		case "message":
+			msg["short_message"] = fmt.Sprint(v)
+			continue
+		}
+		switch v.(type) {
+		case string, json.Number:
+		default:
+			b, _ := json.Marshal(v)
+			v = string(b)
+		}
+		msg[gelfFieldName(k)] = v
+	}
+	if msg["short_message"] == "" {
+		msg["short_message"] = "-"
+	}
+
+	return json.Marshal(msg)
+}
+
+// sendUDP compresses msg and sends it in one datagram or in GELF chunks.
+func (w *GELFWriter) sendUDP(msg []byte) (err error) {
+	switch w.Compression {
+	case GELFGzip, GELFZlib:
+		w.buf.Reset()
+		var zw io.WriteCloser
+		if w.Compression == GELFGzip {
+			zw = gzip.NewWriter(&w.buf)
+		} else {
+			zw = zlib.NewWriter(&w.buf)
+		}
+		zw.Write(msg)
+		zw.Close()
+		msg = w.buf.Bytes()
+	}
+
+	size := w.ChunkSize
+	if size <= 12 {
+		size = 1420
+	}
+	if len(msg) <= size {
+		_, err = w.conn.Write(msg)
+		return
+	}
+
+	// a chunk is prefixed by the magic bytes, the message id, the sequence number
+	// and the sequence count.
+	size -= 12
+	count := (len(msg) + size - 1) / size
+	if count > 128 {
+		return errors.New("log: GELFWriter message exceeds 128 chunks")
+	}
+	id := atomic.AddUint64(&gelfMessageID, 1)
+	for i := 0; i < count; i++ {
+		w.chunk = append(w.chunk[:0], 0x1e, 0x0f, 0, 0, 0, 0, 0, 0, 0, 0, byte(i), byte(count))
+		binary.BigEndian.PutUint64(w.chunk[2:10], id)
+		end := (i + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+		w.chunk = append(w.chunk, msg[i*size:end]...)
+		if _, err = w.conn.Write(w.chunk); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// gelfFieldName returns the additional field name of key, which is prefixed by "_"
+// and consists of the word characters, dots and dashes. The reserved "_id" is
+// renamed to "_id_".
+func gelfFieldName(key string) string {
+	b := make([]byte, 0, len(key)+1)
+	b = append(b, '_')
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.', c == '-':
+			b = append(b, c)
+		default:
+			b = append(b, '_')
+		}
+	}
+	if string(b) == "_id" {
+		b = append(b, '_')
+	}
+	return string(b)
+}
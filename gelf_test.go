@@ -0,0 +1,125 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGELFWriterEncode(t *testing.T) {
+	w := &GELFWriter{Hostname: "myhost"}
+
+	b, err := w.encode(ErrorLevel, []byte(`{"time":"2019-07-10T05:35:54.277Z","level":"error","id":1,"user id":"bob","ok":true,"message":"hello gelf"}`+"\n"))
+	if err != nil {
+		t.Fatalf("gelf writer encode error: %+v", err)
+	}
+	want := `{"_id_":1,"_ok":"true","_user_id":"bob","host":"myhost","level":3,"short_message":"hello gelf","timestamp":1562736954.277000,"version":"1.1"}`
+	if string(b) != want {
+		t.Errorf("gelf writer encode got %s, want %s", b, want)
+	}
+}
+
+func TestGELFWriterUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen udp error: %+v", err)
+	}
+	defer conn.Close()
+
+	w := &GELFWriter{
+		Address:     conn.LocalAddr().String(),
+		Compression: GELFGzip,
+		ChunkSize:   100,
+	}
+	defer w.Close()
+
+	large := strings.Repeat("x", 1000)
+	logger := Logger{Writer: w}
+	logger.Info().Str("large", large).Msg("hello chunks")
+
+	// the chunks of an incompressible payload are sent in order over loopback.
+	var payload []byte
+	buf := make([]byte, 2048)
+	for count := 1; ; {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read udp error: %+v", err)
+		}
+		if n > 100 {
+			t.Fatalf("gelf writer sent a datagram of %d bytes", n)
+		}
+		if buf[0] != 0x1e || buf[1] != 0x0f {
+			payload = append(payload, buf[:n]...)
+			break
+		}
+		count = int(buf[11])
+		payload = append(payload, buf[12:n]...)
+		if int(buf[10]) == count-1 {
+			break
+		}
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("gelf writer sent an invalid gzip payload: %+v", err)
+	}
+	b, _ := ioutil.ReadAll(r)
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("gelf writer sent an invalid json payload: %+v", err)
+	}
+	if m["short_message"] != "hello chunks" || m["_large"] != large || m["level"] != float64(6) {
+		t.Errorf("gelf writer sent unexpected payload: %s", b)
+	}
+}
+
+func TestGELFWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen tcp error: %+v", err)
+	}
+	defer ln.Close()
+
+	msgs := make(chan string, 2)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		for {
+			msg, err := r.ReadString(0)
+			if err != nil {
+				return
+			}
+			msgs <- msg
+		}
+	}()
+
+	w := &GELFWriter{Network: "tcp", Address: ln.Addr().String()}
+	defer w.Close()
+
+	logger := Logger{Writer: w}
+	logger.Warn().Msg("hello tcp")
+	logger.Warn().Msg("")
+
+	for _, want := range []string{`"short_message":"hello tcp"`, `"short_message":"-"`} {
+		select {
+		case msg := <-msgs:
+			if !strings.Contains(msg, want) || !strings.HasSuffix(msg, "}\x00") {
+				t.Errorf("gelf writer sent unexpected message: %q", msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("gelf writer timed out")
+		}
+	}
+}
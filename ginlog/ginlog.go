@@ -0,0 +1,74 @@
+// Package ginlog provides a Gin middleware which logs the requests by github.com/phuslu/log.
+//
+//	router.Use(ginlog.Middleware(&log.DefaultLogger))
+package ginlog
+
+import (
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phuslu/log"
+)
+
+// Middleware returns a gin.HandlerFunc which logs one event per request with the
+// method, path, query, route, status, duration, remote_ip, user_agent, request_id
+// and errors fields. The level is error for 5xx, warn for 4xx and info otherwise.
+// A panic in the handlers is recovered and logged with the stack, and the request
+// is aborted with 500.
+func Middleware(l *log.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		var panicked interface{}
+		var stack []byte
+		func() {
+			defer func() {
+				if panicked = recover(); panicked != nil {
+					stack = debug.Stack()
+					c.AbortWithStatus(http.StatusInternalServerError)
+				}
+			}()
+			c.Next()
+		}()
+
+		status := c.Writer.Status()
+
+		var e *log.Event
+		switch {
+		case status >= 500 || panicked != nil:
+			e = l.Error()
+		case status >= 400:
+			e = l.Warn()
+		default:
+			e = l.Info()
+		}
+		if e == nil {
+			return
+		}
+
+		requestID := c.GetHeader("X-Request-Id")
+		if requestID == "" {
+			requestID = c.Writer.Header().Get("X-Request-Id")
+		}
+
+		e = e.Str("method", c.Request.Method).
+			Str("path", c.Request.URL.Path).
+			Str("query", c.Request.URL.RawQuery).
+			Str("route", c.FullPath()).
+			Int("status", status).
+			Int("size", c.Writer.Size()).
+			Dur("duration", time.Since(start)).
+			Str("remote_ip", c.ClientIP()).
+			Str("user_agent", c.Request.UserAgent()).
+			Str("request_id", requestID)
+		if len(c.Errors) != 0 {
+			e = e.Strs("errors", c.Errors.Errors())
+		}
+		if panicked != nil {
+			e = e.Interface("panic", panicked).Str("stack", string(stack))
+		}
+		e.Msg("")
+	}
+}
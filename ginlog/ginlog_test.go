@@ -0,0 +1,58 @@
+package ginlog
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phuslu/log"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+
+	var buf bytes.Buffer
+	router := gin.New()
+	router.Use(Middleware(&log.Logger{Writer: &buf}))
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/error", func(c *gin.Context) {
+		c.Error(errors.New("bad input"))
+		c.String(http.StatusBadRequest, "bad")
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	cases := []struct {
+		Path   string
+		Status int
+		JSON   []string
+	}{
+		{"/users/42?a=1", 200, []string{`"level":"info","method":"GET","path":"/users/42","query":"a=1","route":"/users/:id","status":200,"size":2,"duration":`, `"remote_ip":"192.0.2.1","user_agent":"test","request_id":"abc"}`}},
+		{"/error", 400, []string{`"level":"warn"`, `"route":"/error","status":400,"size":3`, `"errors":["bad input"]`}},
+		{"/panic", 500, []string{`"level":"error"`, `"status":500`, `"panic":`, `"stack":"goroutine `}},
+	}
+
+	for _, c := range cases {
+		buf.Reset()
+		req := httptest.NewRequest("GET", c.Path, nil)
+		req.Header.Set("User-Agent", "test")
+		req.Header.Set("X-Request-Id", "abc")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != c.Status {
+			t.Errorf("%s got status %d, want %d", c.Path, rec.Code, c.Status)
+		}
+		for _, s := range c.JSON {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("%s output %s does not contain %s", c.Path, buf.String(), s)
+			}
+		}
+	}
+}
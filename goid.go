@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"unsafe"
+)
+
+// goidOffset is the offset of the goid field in the runtime g struct. It is found
+// on the first call of goid by scanning the g structs of a few goroutines, and is
+// 0 if not found.
+var (
+	goidOffset uintptr
+	goidOnce   sync.Once
+)
+
+// goidScanSize is the size of the g struct prefix scanned for the goid field.
+const goidScanSize = 256
+
+// goid returns the id of the current goroutine. It reads the goid field of the
+// runtime g struct if its offset is found, otherwise it parses runtime.Stack.
+func goid() int64 {
+	goidOnce.Do(func() { goidOffset = findGoidOffset() })
+	if goidOffset != 0 {
+		return *(*int64)(unsafe.Pointer(uintptr(getg()) + goidOffset))
+	}
+	return slowGoid()
+}
+
+// slowGoid parses the goroutine id from the first line of runtime.Stack, e.g.
+// "goroutine 18 [running]:".
+func slowGoid() int64 {
+	var buf [64]byte
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	n, _ := strconv.ParseInt(string(b), 10, 64)
+	return n
+}
+
+// findGoidOffset returns the only offset of the g struct holding the goroutine id
+// in several goroutines, the ids of which are distinct.
+func findGoidOffset() uintptr {
+	if getg() == nil {
+		return 0
+	}
+	var offsets []uintptr
+	for off := uintptr(8); off < goidScanSize; off += 8 {
+		offsets = append(offsets, off)
+	}
+	ch := make(chan []uintptr)
+	for i := 0; i < 3 && len(offsets) != 0; i++ {
+		go func(offsets []uintptr) {
+			g, id := getg(), slowGoid()
+			var found []uintptr
+			for _, off := range offsets {
+				if *(*int64)(unsafe.Pointer(uintptr(g) + off)) == id {
+					found = append(found, off)
+				}
+			}
+			ch <- found
+		}(offsets)
+		offsets = <-ch
+	}
+	if len(offsets) != 1 {
+		return 0
+	}
+	return offsets[0]
+}
@@ -0,0 +1,9 @@
+// +build !gccgo
+
+#include "textflag.h"
+
+// func getg() unsafe.Pointer
+TEXT ·getg(SB), NOSPLIT, $0-8
+	MOVQ (TLS), R13
+	MOVQ R13, ret+0(FP)
+	RET
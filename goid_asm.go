@@ -0,0 +1,10 @@
+// +build amd64,!gccgo arm64,!gccgo
+
+package log
+
+import (
+	"unsafe"
+)
+
+// getg returns the pointer of the runtime g struct of the current goroutine.
+func getg() unsafe.Pointer
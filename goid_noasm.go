@@ -0,0 +1,12 @@
+// +build !amd64,!arm64 gccgo
+
+package log
+
+import (
+	"unsafe"
+)
+
+// getg returns nil on the architectures without assembly, goid falls back to runtime.Stack.
+func getg() unsafe.Pointer {
+	return nil
+}
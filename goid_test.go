@@ -0,0 +1,68 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestGoid(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, want := goid(), slowGoid(); got != want {
+				t.Errorf("goid got %d, want %d", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+	t.Logf("goid offset is %d", goidOffset)
+
+	if got, want := goid(), slowGoid(); got != want || want == 0 {
+		t.Errorf("goid got %d, want %d", got, want)
+	}
+}
+
+func TestLoggerGoroutineField(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{
+		GoroutineField: "goid",
+		Writer:         &buf,
+	}
+
+	logger.Info().Msg("hello goroutine")
+	logger.GoroutineField = ""
+	logger.Info().Goid().Msg("hello event goroutine")
+	logger.Info().Msg("hello no goroutine")
+
+	want := slowGoid()
+	for i, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var m struct {
+			Goid *int64
+		}
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Fatalf("unmarshal %s error: %+v", line, err)
+		}
+		switch {
+		case i < 2 && (m.Goid == nil || *m.Goid != want):
+			t.Errorf("goroutine field of %s, want %d", line, want)
+		case i == 2 && m.Goid != nil:
+			t.Errorf("goroutine field of %s, want none", line)
+		}
+	}
+}
+
+func BenchmarkGoid(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		goid()
+	}
+}
+
+func BenchmarkSlowGoid(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		slowGoid()
+	}
+}
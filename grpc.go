@@ -74,5 +74,5 @@ func (l *GrpcLogger) Fatalf(format string, args ...interface{}) {
 
 // V reports whether verbosity level l is at least the requested verbose level.
 func (l *GrpcLogger) V(level int) bool {
-	return level >= int(l.Logger.Level)
+	return level >= 0 && Level(level)*10+DebugLevel >= l.Logger.level()
 }
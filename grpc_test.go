@@ -22,7 +22,7 @@ type grpcLoggerV2 interface {
 
 func TestGrpcLogger(t *testing.T) {
 	logger := Logger{
-		Level:  ParseLevel("debug"),
+		Level:  DebugLevel,
 		Caller: 2,
 	}
 
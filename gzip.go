@@ -0,0 +1,120 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// gzipFlushInterval is the maximum interval to finish the current gzip member.
+var gzipFlushInterval = time.Second
+
+// gzipFlushSize is the maximum uncompressed size in bytes of a gzip member.
+var gzipFlushSize = 1024 * 1024
+
+// GzipWriter returns an io.WriteCloser which compresses the writes into w in the gzip
+// format with the compression level, e.g. gzip.BestSpeed. It uses gzip.DefaultCompression
+// in if level is invalid.
+//
+// The output is a stream of gzip members, a member is finished once it holds 1MB of
+// input or is 1 second old, so a crash loses at most one member and a reader can
+// decompress the stream incrementally. A member is buffered and written to w in a
+// single write, so it is not split by a FileWriter which rotates between the writes.
+// Close finishes the last member and closes w if it is an io.Closer.
+func GzipWriter(w io.Writer, level int) io.WriteCloser {
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		level = gzip.DefaultCompression
+	}
+	return &gzipWriter{w: w, level: level}
+}
+
+type gzipWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level int
+	gz    *gzip.Writer
+	buf   bytes.Buffer
+	size  int
+	open  bool
+	timer *time.Timer
+}
+
+func (w *gzipWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.open {
+		if w.gz == nil {
+			w.gz, _ = gzip.NewWriterLevel(&w.buf, w.level)
+		} else {
+			w.gz.Reset(&w.buf)
+		}
+		w.open = true
+		if w.timer == nil {
+			w.timer = time.AfterFunc(gzipFlushInterval, w.flush)
+		} else {
+			w.timer.Reset(gzipFlushInterval)
+		}
+	}
+
+	n, err = w.gz.Write(p)
+	if err != nil {
+		return
+	}
+
+	if w.size += n; w.size >= gzipFlushSize {
+		err = w.finish()
+	}
+
+	return
+}
+
+func (w *gzipWriter) Close() (err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if w.open {
+		err = w.finish()
+	}
+	if closer, ok := w.w.(io.Closer); ok && closer != io.Closer(os.Stderr) {
+		if err1 := closer.Close(); err == nil {
+			err = err1
+		}
+	}
+	return
+}
+
+// Describe implements WriterDescriber.
+func (w *gzipWriter) Describe() string {
+	return fmt.Sprintf("GzipWriter{Level:%d} -> %s", w.level, describeWriter(w.w))
+}
+
+func (w *gzipWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.open {
+		return
+	}
+	if err := w.finish(); err != nil && ErrorHandler != nil {
+		ErrorHandler(fmt.Errorf("log: GzipWriter flush error: %v", err))
+	}
+}
+
+// finish writes the footer of the current gzip member, and writes the member to w.
+func (w *gzipWriter) finish() (err error) {
+	w.open = false
+	w.size = 0
+	if err = w.gz.Close(); err == nil {
+		_, err = w.w.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return
+}
@@ -0,0 +1,148 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGzipWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := GzipWriter(&buf, gzip.BestSpeed)
+
+	logger := Logger{
+		Writer: w,
+	}
+	for i := 0; i < 100; i++ {
+		logger.Info().Int("i", i).Msg("hello gzip writer")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("gzip writer close error: %+v", err)
+	}
+
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip reader error: %+v", err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("gzip read error: %+v", err)
+	}
+	if n := strings.Count(string(data), "hello gzip writer"); n != 100 {
+		t.Errorf("gzip writer got %d lines, want 100", n)
+	}
+}
+
+func TestGzipWriterMembers(t *testing.T) {
+	defer func(n int) { gzipFlushSize = n }(gzipFlushSize)
+	gzipFlushSize = 20
+
+	var buf bytes.Buffer
+	w := GzipWriter(&buf, 100)
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(w, "line %d of gzip member\n", i)
+	}
+	w.Close()
+
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip reader error: %+v", err)
+	}
+	r.Multistream(false)
+
+	members := 0
+	for {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("gzip read error: %+v", err)
+		}
+		if want := fmt.Sprintf("line %d of gzip member\n", members); string(data) != want {
+			t.Errorf("gzip member %d got %q, want %q", members, data, want)
+		}
+		members++
+		if err = r.Reset(&buf); err != nil {
+			break
+		}
+		r.Multistream(false)
+	}
+	if members != 10 {
+		t.Errorf("gzip writer got %d members, want 10", members)
+	}
+}
+
+func TestGzipWriterFlushInterval(t *testing.T) {
+	defer func(d time.Duration) { gzipFlushInterval = d }(gzipFlushInterval)
+	gzipFlushInterval = 10 * time.Millisecond
+
+	var buf lockedBuffer
+	w := GzipWriter(&buf, gzip.DefaultCompression)
+	defer w.Close()
+
+	fmt.Fprint(w, "hello gzip flush\n")
+	time.Sleep(100 * time.Millisecond)
+
+	buf.mu.Lock()
+	p := append([]byte(nil), buf.buf.Bytes()...)
+	buf.mu.Unlock()
+
+	r, err := gzip.NewReader(bytes.NewReader(p))
+	if err != nil {
+		t.Fatalf("gzip reader error: %+v", err)
+	}
+	if data, err := ioutil.ReadAll(r); err != nil || string(data) != "hello gzip flush\n" {
+		t.Errorf("gzip writer should finish the member after the interval, got %q, %+v", data, err)
+	}
+}
+
+func TestGzipWriterFileWriter(t *testing.T) {
+	defer func(n int) { gzipFlushSize = n }(gzipFlushSize)
+	gzipFlushSize = 100
+
+	filename := "file-gzip.log"
+	w := GzipWriter(&FileWriter{Filename: filename, MaxSize: 200, MaxBackups: 100}, gzip.BestSpeed)
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(w, "line %d of gzip file writer\n", i)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("gzip writer close error: %+v", err)
+	}
+
+	matches, _ := filepath.Glob("file-gzip.*.log")
+	defer func() {
+		for _, name := range matches {
+			os.Remove(name)
+		}
+		os.Remove(filename)
+	}()
+	if len(matches) < 2 {
+		t.Fatalf("file writer should rotate, got %v", matches)
+	}
+
+	lines := 0
+	for _, name := range matches {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ioutil read file error: %+v", err)
+		}
+		if len(data) == 0 {
+			continue
+		}
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("gzip reader of %s error: %+v", name, err)
+		}
+		if data, err = ioutil.ReadAll(r); err != nil {
+			t.Fatalf("gzip read of %s error: %+v", name, err)
+		}
+		lines += strings.Count(string(data), "\n")
+	}
+	if lines != 100 {
+		t.Errorf("gzip writer got %d lines, want 100", lines)
+	}
+}
@@ -0,0 +1,174 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// HMACWriter is an io.Writer wrapper which makes the JSON lines tamper-evident. It
+// adds the "hmac" field to each line, which is the HMAC-SHA256 of the line and the
+// "hmac" of the previous line, so a modified or deleted line breaks the chain and
+// is reported by Verify.
+//
+// The chain starts with a header line of the "hmac_start" field. If Writer is a
+// FileWriter, the chain restarts with a new header line in every file it opens, e.g.
+// by Rotate, MaxSize, RotateInterval or reopening a moved file, so each log file can
+// be verified alone.
+type HMACWriter struct {
+	// Key specifies the key of HMAC.
+	Key []byte
+
+	// Writer specifies the writer of output. It uses os.Stderr in if empty.
+	Writer io.Writer
+
+	mu    sync.Mutex
+	hash  hash.Hash
+	mac   []byte
+	files int64
+	buf   []byte
+}
+
+// Write implements io.Writer, p must be a JSON object line.
+func (w *HMACWriter) Write(p []byte) (n int, err error) {
+	line := bytes.TrimSuffix(p, []byte{'\n'})
+	if len(line) < 2 || line[0] != '{' || line[len(line)-1] != '}' {
+		return 0, errors.New("log: HMACWriter requires a JSON object line")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if fw, ok := w.Writer.(*FileWriter); ok {
+		// the file is switched inside the write, so the line is signed by FileWriter
+		// after the switch.
+		_, err = fw.writeFunc(line, w.signFile)
+	} else {
+		_, err = w.writer().Write(w.signLine(line))
+	}
+	if err != nil {
+		// the line is not written, so the chain restarts with a header line.
+		w.mac = nil
+		return
+	}
+
+	return len(p), nil
+}
+
+// signFile restarts the chain if the FileWriter opened a new file, and signs the line.
+func (w *HMACWriter) signFile(files int64, line []byte) []byte {
+	if files != w.files {
+		w.files = files
+		w.mac = nil
+	}
+	return w.signLine(line)
+}
+
+// signLine returns the line signed by sign, preceded by a header line if the chain
+// starts. They are returned together so a rotation can not separate them.
+func (w *HMACWriter) signLine(line []byte) []byte {
+	w.buf = w.buf[:0]
+	if w.mac == nil {
+		w.buf = append(w.buf, "{\"hmac_start\":\""...)
+		w.buf = timeNow().UTC().AppendFormat(w.buf, time.RFC3339Nano)
+		w.buf = append(w.buf, '"', '}')
+		w.buf = w.sign(w.buf[:0], w.buf)
+	}
+	return w.sign(w.buf, line)
+}
+
+// sign appends the line with the "hmac" field chained to the previous line to dst.
+// The dst may overlap the line.
+func (w *HMACWriter) sign(dst, line []byte) []byte {
+	if w.hash == nil {
+		w.hash = hmac.New(sha256.New, w.Key)
+	}
+	w.hash.Reset()
+	w.hash.Write(w.mac)
+	w.hash.Write(line)
+	w.mac = w.hash.Sum(w.mac[:0])
+
+	dst = append(dst, line[:len(line)-1]...)
+	if len(line) > 2 {
+		dst = append(dst, ',')
+	}
+	dst = append(dst, "\"hmac\":\""...)
+	dst = appendHex(dst, w.mac)
+	return append(dst, '"', '}', '\n')
+}
+
+func appendHex(dst, b []byte) []byte {
+	for _, c := range b {
+		dst = append(dst, hex[c>>4], hex[c&0x0f])
+	}
+	return dst
+}
+
+// Describe implements WriterDescriber.
+func (w *HMACWriter) Describe() string {
+	return "HMACWriter -> " + describeWriter(w.Writer)
+}
+
+func (w *HMACWriter) writer() io.Writer {
+	if w.Writer != nil {
+		return w.Writer
+	}
+	return os.Stderr
+}
+
+// Verify reads the lines written by HMACWriter from r and verifies their chain, the
+// error reports the line number of the first broken link.
+func (w *HMACWriter) Verify(r io.Reader) error {
+	h := hmac.New(sha256.New, w.Key)
+	br := bufio.NewReader(r)
+
+	var prev, sum []byte
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(line) == 0 && err == io.EOF {
+			return nil
+		}
+		line = bytes.TrimSuffix(line, []byte{'\n'})
+
+		i := bytes.LastIndex(line, []byte("\"hmac\":\""))
+		if i < 1 || len(line)-i != len("\"hmac\":\"")+2*sha256.Size+2 {
+			return fmt.Errorf("log: hmac chain is broken at line %d: missing hmac field", n)
+		}
+		mac := line[i+len("\"hmac\":\"") : len(line)-2]
+
+		j := i
+		if line[j-1] == ',' {
+			j--
+		}
+		orig := append(append(make([]byte, 0, j+1), line[:j]...), '}')
+
+		if bytes.HasPrefix(orig, []byte("{\"hmac_start\":")) {
+			prev = prev[:0]
+		} else if len(prev) == 0 {
+			return fmt.Errorf("log: hmac chain is broken at line %d: missing hmac_start header", n)
+		}
+
+		h.Reset()
+		h.Write(prev)
+		h.Write(orig)
+		prev = h.Sum(prev[:0])
+		if sum = appendHex(sum[:0], prev); !hmac.Equal(sum, mac) {
+			return fmt.Errorf("log: hmac chain is broken at line %d", n)
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &HMACWriter{
+		Key:    []byte("secret"),
+		Writer: &buf,
+	}
+
+	logger := Logger{
+		Writer: w,
+	}
+	for i := 0; i < 5; i++ {
+		logger.Info().Int("i", i).Msg("hello hmac")
+	}
+
+	if err := w.Verify(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("hmac verify error: %+v", err)
+	}
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	if len(lines) != 7 || !strings.HasPrefix(lines[0], `{"hmac_start":"`) {
+		t.Fatalf("hmac writer should start with a header line, got %s", buf.Bytes())
+	}
+
+	cases := []struct {
+		Name  string
+		Lines []string
+		Error string
+	}{
+		{"modify", []string{lines[0], lines[1], strings.Replace(lines[2], `"i":1`, `"i":9`, 1), lines[3]}, "line 3"},
+		{"delete", []string{lines[0], lines[1], lines[3], lines[4]}, "line 3"},
+		{"reorder", []string{lines[0], lines[2], lines[1]}, "line 2"},
+		{"truncate head", []string{lines[2], lines[3]}, "line 1: missing hmac_start header"},
+		{"strip", []string{lines[0], `{"i":1}` + "\n"}, "line 2: missing hmac field"},
+	}
+	for _, c := range cases {
+		err := w.Verify(strings.NewReader(strings.Join(c.Lines, "")))
+		if err == nil || !strings.Contains(err.Error(), c.Error) {
+			t.Errorf("hmac verify %s got %v, want %s", c.Name, err, c.Error)
+		}
+	}
+
+	other := &HMACWriter{Key: []byte("other")}
+	if err := other.Verify(bytes.NewReader(buf.Bytes())); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("hmac verify with another key should fail at line 1, got %v", err)
+	}
+
+	if _, err := w.Write([]byte("not json\n")); err == nil {
+		t.Errorf("hmac writer should reject a non JSON line")
+	}
+}
+
+// flakyWriter writes to buf, or fails without writing if fail is set.
+type flakyWriter struct {
+	buf  bytes.Buffer
+	fail bool
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, errors.New("flaky write error")
+	}
+	return w.buf.Write(p)
+}
+
+func TestHMACWriterWriteError(t *testing.T) {
+	fw := &flakyWriter{}
+	w := &HMACWriter{
+		Key:    []byte("secret"),
+		Writer: fw,
+	}
+
+	logger := Logger{Writer: w}
+	logger.Info().Int("i", 0).Msg("hello hmac")
+	fw.fail = true
+	if err := logger.Info().Int("i", 1).MsgErr("hello hmac"); err == nil {
+		t.Fatalf("hmac writer should return the write error")
+	}
+	fw.fail = false
+	logger.Info().Int("i", 2).Msg("hello hmac")
+
+	if err := w.Verify(bytes.NewReader(fw.buf.Bytes())); err != nil {
+		t.Errorf("hmac verify after a write error: %+v, got %s", err, fw.buf.Bytes())
+	}
+	if n := strings.Count(fw.buf.String(), `"hmac_start"`); n != 2 {
+		t.Errorf("hmac writer should restart the chain after a write error, got %d headers", n)
+	}
+}
+
+func TestHMACWriterRotate(t *testing.T) {
+	filename := "file-hmac.log"
+	fw := &FileWriter{
+		Filename:   filename,
+		MaxBackups: 10,
+	}
+	w := &HMACWriter{
+		Key:    []byte("secret"),
+		Writer: fw,
+	}
+
+	logger := Logger{
+		Writer: w,
+	}
+	logger.Info().Msg("hello before rotate")
+	logger.Info().Msg("hello before rotate")
+
+	time.Sleep(time.Second)
+	fw.Rotate()
+	logger.Info().Msg("hello after rotate")
+	fw.Close()
+
+	matches, _ := filepath.Glob("file-hmac.*.log")
+	defer func() {
+		for i := range matches {
+			os.Remove(matches[i])
+		}
+		os.Remove(filename)
+	}()
+	if len(matches) != 2 {
+		t.Fatalf("hmac writer should write 2 files, got %v", matches)
+	}
+
+	for i, name := range matches {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatalf("read file %s error: %+v", name, err)
+		}
+		if err = w.Verify(bytes.NewReader(data)); err != nil {
+			t.Errorf("hmac verify file %s error: %+v", name, err)
+		}
+		if n := strings.Count(string(data), "\n"); n != 3-i {
+			t.Errorf("hmac file %s got %d lines, want %d: %s", name, n, 3-i, data)
+		}
+	}
+}
+
+func TestHMACWriterRotateInterval(t *testing.T) {
+	filename := "file-hmac-interval.log"
+
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return time.Date(2020, 1, 1, 23, 59, 59, 0, time.UTC) }
+
+	fw := &FileWriter{
+		Filename:       filename,
+		MaxBackups:     10,
+		TimeFormat:     "2006-01-02",
+		RotateInterval: 24 * time.Hour,
+	}
+	w := &HMACWriter{
+		Key:    []byte("secret"),
+		Writer: fw,
+	}
+
+	logger := Logger{Writer: w}
+	logger.Info().Msg("hello day 1")
+	// the period is over, so FileWriter switches the file inside the next write.
+	timeNow = func() time.Time { return time.Date(2020, 1, 2, 0, 0, 1, 0, time.UTC) }
+	logger.Info().Msg("hello day 2")
+	fw.Close()
+
+	defer func() {
+		matches, _ := filepath.Glob("file-hmac-interval.*")
+		for i := range matches {
+			os.Remove(matches[i])
+		}
+	}()
+	for name, lines := range map[string]int{
+		"file-hmac-interval.2020-01-01.log": 2,
+		"file-hmac-interval.2020-01-02.log": 2,
+	} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatalf("read file %s error: %+v", name, err)
+		}
+		if err = w.Verify(bytes.NewReader(data)); err != nil {
+			t.Errorf("hmac verify file %s error: %+v", name, err)
+		}
+		if n := strings.Count(string(data), "\n"); n != lines {
+			t.Errorf("hmac file %s got %d lines, want %d: %s", name, n, lines, data)
+		}
+	}
+}
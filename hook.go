@@ -0,0 +1,40 @@
+package log
+
+// Hook is the interface of the hooks which run before an event is written, e.g. to
+// count the events by level or to add fields like a trace id to every event.
+type Hook interface {
+	// Run is called with the final message before the event is written, it can add
+	// fields to e by the Event methods but must not send or discard e.
+	Run(e *Event, level Level, message string)
+}
+
+// HookFunc is an adapter to allow the use of ordinary functions as Hook.
+type HookFunc func(e *Event, level Level, message string)
+
+// Run implements Hook, it calls f(e, level, message).
+func (f HookFunc) Run(e *Event, level Level, message string) {
+	f(e, level, message)
+}
+
+// Hook adds h to the hooks of the event, which run after the hooks of the logger.
+func (e *Event) Hook(h Hook) *Event {
+	if e == nil {
+		return nil
+	}
+	e.hooks = append(e.hooks, h)
+	return e
+}
+
+// runHooks runs the hooks of the logger and the event with msg.
+func (e *Event) runHooks(msg string) {
+	if e.parent != nil {
+		for _, h := range e.parent.Hooks {
+			h.Run(e, e.level, msg)
+		}
+	}
+	for i, h := range e.hooks {
+		h.Run(e, e.level, msg)
+		e.hooks[i] = nil
+	}
+	e.hooks = e.hooks[:0]
+}
@@ -0,0 +1,115 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type levelHook struct {
+	levels []Level
+}
+
+func (h *levelHook) Run(e *Event, level Level, message string) {
+	h.levels = append(h.levels, level)
+}
+
+type fieldHook struct {
+	key, value string
+}
+
+func (h *fieldHook) Run(e *Event, level Level, message string) {
+	e.Str(h.key, h.value)
+}
+
+func TestLoggerHooks(t *testing.T) {
+	var buf bytes.Buffer
+	hook := &levelHook{}
+	logger := Logger{
+		Writer: &buf,
+		Hooks: []Hook{
+			hook,
+			HookFunc(func(e *Event, level Level, message string) {
+				e.Str("trace_id", "abc").Int("len", len(message))
+			}),
+		},
+	}
+
+	logger.Info().Str("foo", "bar").Msg("hello hook")
+	logger.Error().Msgf("hello %s", "world")
+	logger.Info().Send()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("logger hooks output %d lines, want 3: %s", len(lines), buf.String())
+	}
+	for i, want := range []string{
+		`"foo":"bar","trace_id":"abc","len":10,"message":"hello hook"}`,
+		`"level":"error","trace_id":"abc","len":11,"message":"hello world"}`,
+		`"trace_id":"abc","len":0}`,
+	} {
+		if !strings.HasSuffix(lines[i], want) {
+			t.Errorf("logger hooks output %s, want suffix %s", lines[i], want)
+		}
+	}
+	if !reflect.DeepEqual(hook.levels, []Level{InfoLevel, ErrorLevel, InfoLevel}) {
+		t.Errorf("logger hooks levels %v, want [info error info]", hook.levels)
+	}
+
+	if s := logger.String(); !strings.Contains(s, " Hooks:2 ") {
+		t.Errorf("logger hooks string %s, want Hooks:2", s)
+	}
+	if c := logger.Clone(); len(c.Hooks) != 2 {
+		t.Errorf("logger hooks clone got %d hooks, want 2", len(c.Hooks))
+	}
+}
+
+func TestEventHook(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{
+		Writer: &buf,
+		Hooks: []Hook{
+			&fieldHook{"logger", "hook"},
+		},
+	}
+
+	logger.Info().Hook(HookFunc(func(e *Event, level Level, message string) {
+		e.Str("event", message)
+	})).Msg("hello")
+	logger.Info().Msg("world")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("event hook output %d lines, want 2: %s", len(lines), buf.String())
+	}
+	if want := `"logger":"hook","event":"hello","message":"hello"}`; !strings.HasSuffix(lines[0], want) {
+		t.Errorf("event hook output %s, want suffix %s", lines[0], want)
+	}
+	if strings.Contains(lines[1], `"event"`) {
+		t.Errorf("event hook leaks to the next event: %s", lines[1])
+	}
+
+	var e *Event
+	if e.Hook(HookFunc(func(*Event, Level, string) {})) != nil {
+		t.Errorf("nil event Hook should return nil")
+	}
+}
+
+func BenchmarkLoggerHooks(b *testing.B) {
+	logger := Logger{
+		Writer: ioutil.Discard,
+		Hooks: []Hook{
+			HookFunc(func(e *Event, level Level, message string) {
+				e.Str("trace_id", "abc")
+			}),
+		},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info().Str("foo", "bar").Msg("hello world")
+	}
+}
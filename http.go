@@ -0,0 +1,721 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// DebugHandler returns a handler that stores DebugLevel by ContextWithMinLevel in the
+// context of the requests whose header has the secret value, the other requests and
+// the global loggers are not affected.
+func DebugHandler(next http.Handler, header, secret string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get(header); secret != "" && subtle.ConstantTimeCompare([]byte(v), []byte(secret)) == 1 {
+			r = r.WithContext(ContextWithMinLevel(r.Context(), DebugLevel))
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// AccessLogConfig specifies how AccessLogConfig.Handler logs the requests. The field
+// names use the defaults of AccessLogHandler in if empty.
+type AccessLogConfig struct {
+	// ForwardedFor determines if the remote ip is read from the first address of the
+	// X-Forwarded-For header, it should be set only behind a trusted proxy.
+	ForwardedFor bool
+
+	// RecoverPanic determines if a panic of the handler is converted to a 500 response
+	// after it is logged, otherwise the panic is re-panicked.
+	RecoverPanic bool
+
+	// AnonymizeIP determines if the remote ip is logged by IPAddrAnon, i.e. with the
+	// trailing bits of IPv4AnonBits and IPv6AnonBits zeroed.
+	AnonymizeIP bool
+
+	// HeaderPolicy specifies how the request headers are logged by HTTPHeader, the
+	// headers are not logged if it is nil.
+	HeaderPolicy *HeaderPolicy
+
+	MethodField    string // "method"
+	PathField      string // "path"
+	QueryField     string // "query"
+	RemoteIPField  string // "remote_ip"
+	StatusField    string // "status"
+	SizeField      string // "size"
+	DurationField  string // "duration"
+	UserAgentField string // "user_agent"
+	RequestIDField string // "request_id"
+	HeaderField    string // "header"
+}
+
+// AccessLogHandler returns a handler which logs one event per request by l with the
+// method, path, query, remote_ip, status, size, duration, user_agent and request_id
+// fields. The level is error for 5xx, warn for 4xx and info otherwise. A panic of next
+// is logged with the stack and re-panicked.
+func AccessLogHandler(l *Logger, next http.Handler) http.Handler {
+	return AccessLogConfig{}.Handler(l, next)
+}
+
+// Handler returns a handler which logs the requests of next by l as AccessLogHandler.
+func (c AccessLogConfig) Handler(l *Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		w := &accessLogWriter{ResponseWriter: rw}
+
+		defer func() {
+			panicked := recover()
+			if panicked != nil && c.RecoverPanic && w.status == 0 {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			c.log(l, w, r, start, panicked)
+			if panicked != nil && !c.RecoverPanic {
+				panic(panicked)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *AccessLogConfig) log(l *Logger, w *accessLogWriter, r *http.Request, start time.Time, panicked interface{}) {
+	status := w.status
+	switch {
+	case status != 0:
+	case panicked != nil:
+		status = http.StatusInternalServerError
+	default:
+		status = http.StatusOK
+	}
+
+	var e *Event
+	switch {
+	case status >= 500 || panicked != nil:
+		e = l.Error()
+	case status >= 400:
+		e = l.Warn()
+	default:
+		e = l.Info()
+	}
+	if e == nil {
+		return
+	}
+
+	requestID := r.Header.Get("X-Request-Id")
+	if requestID == "" {
+		requestID = w.Header().Get("X-Request-Id")
+	}
+
+	e = e.Str(orDefault(c.MethodField, "method"), r.Method).
+		Str(orDefault(c.PathField, "path"), r.URL.Path).
+		Str(orDefault(c.QueryField, "query"), r.URL.RawQuery)
+	remoteIP := c.remoteIP(r)
+	if ip := net.ParseIP(remoteIP); c.AnonymizeIP && ip != nil {
+		e = e.IPAddrAnon(orDefault(c.RemoteIPField, "remote_ip"), ip)
+	} else {
+		e = e.Str(orDefault(c.RemoteIPField, "remote_ip"), remoteIP)
+	}
+	e = e.Int(orDefault(c.StatusField, "status"), status).
+		Int64(orDefault(c.SizeField, "size"), w.size).
+		TimeDiff(orDefault(c.DurationField, "duration"), time.Now(), start).
+		Str(orDefault(c.UserAgentField, "user_agent"), r.UserAgent()).
+		Str(orDefault(c.RequestIDField, "request_id"), requestID)
+	if c.HeaderPolicy != nil {
+		e = e.HTTPHeader(orDefault(c.HeaderField, "header"), r.Header, c.HeaderPolicy)
+	}
+	if panicked != nil {
+		e = e.Interface("panic", panicked).Str("stack", string(debug.Stack()))
+	}
+	e.Msg("")
+}
+
+func (c *AccessLogConfig) remoteIP(r *http.Request) string {
+	if c.ForwardedFor {
+		if s := r.Header.Get("X-Forwarded-For"); s != "" {
+			if i := strings.IndexByte(s, ','); i >= 0 {
+				s = s[:i]
+			}
+			return strings.TrimSpace(s)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// accessLogWriter records the status and the size of the response.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(p []byte) (n int, err error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err = w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return
+}
+
+// Flush implements http.Flusher if the underlying ResponseWriter does.
+func (w *accessLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying ResponseWriter does, e.g. for the
+// websocket upgrades, the status is logged as 101 if it is not written before.
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("log: ResponseWriter does not implement http.Hijacker")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// ReadFrom implements io.ReaderFrom, it uses the io.ReaderFrom of the underlying
+// ResponseWriter if any, e.g. for sendfile.
+func (w *accessLogWriter) ReadFrom(r io.Reader) (n int64, err error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, r)
+	}
+	w.size += n
+	return
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// LoggingTransport is an http.RoundTripper which logs one event per outbound request
+// with the method, url, status, duration and error fields. The level is debug for 2xx
+// and 3xx, warn for 4xx and error for 5xx and the transport errors.
+type LoggingTransport struct {
+	// Logger specifies the logger of the requests. It uses &DefaultLogger in if nil.
+	Logger *Logger
+
+	// Base specifies the underlying transport. It uses http.DefaultTransport in if nil.
+	Base http.RoundTripper
+
+	// RedactQuery determines if the values of the query parameters are redacted.
+	RedactQuery bool
+
+	// MaxBodySize specifies the number of leading bytes of the request and response
+	// bodies logged by the request_body and response_body fields. The bodies are not
+	// logged in if zero. The leading bytes are read before the request is sent and
+	// before the response is returned, the caller still reads the full bodies.
+	MaxBodySize int
+}
+
+// NewLoggingTransport returns a LoggingTransport which logs the requests of base by l.
+func NewLoggingTransport(l *Logger, base http.RoundTripper) *LoggingTransport {
+	return &LoggingTransport{Logger: l, Base: base}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *LoggingTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	l := t.Logger
+	if l == nil {
+		l = &DefaultLogger
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	var reqBody []byte
+	if t.MaxBodySize > 0 && req.Body != nil && req.Body != http.NoBody {
+		r := *req
+		reqBody, r.Body, err = peekBody(req.Body, t.MaxBodySize)
+		if err != nil {
+			req.Body.Close()
+			return nil, err
+		}
+		req = &r
+	}
+
+	start := time.Now()
+	resp, err = base.RoundTrip(req)
+	end := time.Now()
+
+	var respBody []byte
+	if err == nil && t.MaxBodySize > 0 && resp.Body != nil && resp.Body != http.NoBody {
+		respBody, resp.Body, _ = peekBody(resp.Body, t.MaxBodySize)
+	}
+
+	var e *Event
+	switch {
+	case err != nil || resp.StatusCode >= 500:
+		e = l.Error()
+	case resp.StatusCode >= 400:
+		e = l.Warn()
+	default:
+		e = l.Debug()
+	}
+	if e == nil {
+		return
+	}
+
+	e = e.Str("method", req.Method).Str("url", t.url(req))
+	if err == nil {
+		e = e.Int("status", resp.StatusCode)
+	}
+	e = e.TimeDiff("duration", end, start)
+	if err != nil {
+		e = e.Err(err)
+	}
+	if reqBody != nil {
+		e = e.Bytes("request_body", reqBody)
+	}
+	if respBody != nil {
+		e = e.Bytes("response_body", respBody)
+	}
+	e.Msg("")
+	return
+}
+
+func (t *LoggingTransport) url(req *http.Request) string {
+	if req.URL == nil {
+		return ""
+	}
+	u := *req.URL
+	if t.RedactQuery && u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, p := range params {
+			if j := strings.IndexByte(p, '='); j >= 0 {
+				params[i] = p[:j+1] + "[redacted]"
+			}
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+// peekBody reads at most n leading bytes of body and returns them with a body which
+// reads the leading bytes followed by the rest of body.
+func peekBody(body io.ReadCloser, n int) ([]byte, io.ReadCloser, error) {
+	head := make([]byte, n)
+	m, err := io.ReadFull(body, head)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	head = head[:m]
+	return head, struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), body), body}, err
+}
+
+// HeaderPolicy specifies how the HTTP headers are logged. The header names are matched
+// case-insensitively.
+type HeaderPolicy struct {
+	// Allow specifies the headers whose values are logged, the values of the other headers
+	// are redacted. All values are logged in if empty.
+	Allow []string
+
+	// Deny specifies the headers whose values are always redacted. It uses
+	// DefaultDeniedHeaders in if nil.
+	Deny []string
+
+	// MaxValueSize truncates the header values longer than it in bytes. It is unlimited in if zero.
+	MaxValueSize int
+}
+
+// DefaultDeniedHeaders is the default denylist of HeaderPolicy.
+var DefaultDeniedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+	"X-Csrf-Token",
+}
+
+// HTTPHeaderPolicy specifies how HTTPRequest logs the request headers,
+// the headers are not logged if it is nil.
+var HTTPHeaderPolicy *HeaderPolicy
+
+func (p *HeaderPolicy) redacted(name string) bool {
+	deny := p.Deny
+	if deny == nil {
+		deny = DefaultDeniedHeaders
+	}
+	for _, s := range deny {
+		if strings.EqualFold(s, name) {
+			return true
+		}
+	}
+	if len(p.Allow) == 0 {
+		return false
+	}
+	for _, s := range p.Allow {
+		if strings.EqualFold(s, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// HTTPHeader adds the header h as a JSON object to the event, the values are redacted or
+// truncated according to policy. A multi-valued header is added as an array.
+func (e *Event) HTTPHeader(key string, h http.Header, policy *HeaderPolicy) *Event {
+	if e == nil {
+		return nil
+	}
+	if policy == nil {
+		policy = &HeaderPolicy{}
+	}
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	e.key(key)
+	e.buf = append(e.buf, '{')
+	for i, name := range names {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.string(name)
+		e.buf = append(e.buf, ':')
+		values := h[name]
+		switch {
+		case policy.redacted(name):
+			e.buf = append(e.buf, "\"[redacted]\""...)
+		case len(values) == 1:
+			e.headerValue(values[0], policy.MaxValueSize)
+		default:
+			e.buf = append(e.buf, '[')
+			for j, v := range values {
+				if j != 0 {
+					e.buf = append(e.buf, ',')
+				}
+				e.headerValue(v, policy.MaxValueSize)
+			}
+			e.buf = append(e.buf, ']')
+		}
+	}
+	e.buf = append(e.buf, '}')
+	return e
+}
+
+func (e *Event) headerValue(v string, max int) {
+	if max <= 0 || len(v) <= max {
+		e.string(v)
+		return
+	}
+	n := max
+	for n > 0 && !utf8.RuneStart(v[n]) {
+		n--
+	}
+	e.string(v[:n] + "... " + strconv.Itoa(len(v)-n) + " bytes truncated")
+}
+
+// HTTPRequest adds the method, uri, proto, host and remote_addr of the request r as a
+// JSON object to the event. The headers are also added if HTTPHeaderPolicy is not nil.
+func (e *Event) HTTPRequest(key string, r *http.Request) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	e.buf = append(e.buf, "{\"method\":"...)
+	e.string(r.Method)
+	if r.RequestURI != "" {
+		e.Str("uri", r.RequestURI)
+	} else if r.URL != nil {
+		e.Str("uri", r.URL.RequestURI())
+	}
+	e.Str("proto", r.Proto)
+	e.Str("host", r.Host)
+	e.Str("remote_addr", r.RemoteAddr)
+	if HTTPHeaderPolicy != nil {
+		e.HTTPHeader("header", r.Header, HTTPHeaderPolicy)
+	}
+	e.buf = append(e.buf, '}')
+	return e
+}
+
+// HTTPWriter is an io.WriteCloser that posts the JSON lines in batches to an HTTP
+// endpoint as a newline delimited JSON body. A batch is posted once it holds
+// BatchSize bytes or is FlushInterval old, a failed post of a 5xx status or a
+// transport error is retried with an exponential backoff.
+//
+// The batches are posted in order by a background goroutine, so Write never waits
+// for the endpoint. A full batch is dropped if QueueSize batches are waiting to be
+// posted. The errors of the posts are reported to ErrorHandler, except the one of
+// the last batch, which is returned by Close.
+type HTTPWriter struct {
+	// make aligncheck happy
+	dropped uint64
+	retried uint64
+
+	// URL specifies the URL of the endpoint.
+	URL string
+
+	// Header specifies the extra headers of the requests, e.g. Authorization.
+	Header http.Header
+
+	// Client specifies the HTTP client. It uses http.DefaultClient in if empty.
+	Client *http.Client
+
+	// Gzip specifies whether to compress the request body by gzip.
+	Gzip bool
+
+	// BatchSize specifies the size in bytes to post a batch. It uses 1MB in if zero.
+	BatchSize int
+
+	// FlushInterval specifies the maximum interval to post a batch. It uses 1s in if zero.
+	FlushInterval time.Duration
+
+	// MaxRetries specifies the maximum number of retries of a batch. It uses 3 in if zero.
+	MaxRetries int
+
+	// QueueSize specifies the maximum number of batches waiting to be posted. It uses 8 in if zero.
+	QueueSize int
+
+	mu     sync.Mutex
+	buf    []byte
+	timer  *time.Timer
+	closed bool
+	queue  chan []byte
+	done   chan struct{}
+}
+
+// httpWriterBackoff is the backoff of the first retry of HTTPWriter.
+var httpWriterBackoff = 100 * time.Millisecond
+
+// Write implements io.Writer.
+func (w *HTTPWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errors.New("log: HTTPWriter is closed")
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(p) > 0 && p[len(p)-1] != '\n' {
+		w.buf = append(w.buf, '\n')
+	}
+
+	size := w.BatchSize
+	if size == 0 {
+		size = 1024 * 1024
+	}
+	if len(w.buf) >= size {
+		w.enqueue(w.take())
+	} else if w.timer == nil {
+		interval := w.FlushInterval
+		if interval == 0 {
+			interval = time.Second
+		}
+		w.timer = time.AfterFunc(interval, w.flush)
+	}
+
+	return len(p), nil
+}
+
+// Dropped returns the number of lines dropped after the retries or by a full queue.
+func (w *HTTPWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Retried returns the number of retried requests.
+func (w *HTTPWriter) Retried() uint64 {
+	return atomic.LoadUint64(&w.retried)
+}
+
+// Close implements io.Closer, it waits for the queued batches to be posted and
+// posts the last batch.
+func (w *HTTPWriter) Close() (err error) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	batch := w.take()
+	queue, done := w.queue, w.done
+	w.mu.Unlock()
+
+	if queue != nil {
+		close(queue)
+		<-done
+	}
+
+	return w.send(batch)
+}
+
+// Describe implements WriterDescriber.
+func (w *HTTPWriter) Describe() string {
+	return fmt.Sprintf("HTTPWriter{URL:%s Gzip:%t}", w.URL, w.Gzip)
+}
+
+// take returns the current batch and stops its timer, it must be called with mu held.
+func (w *HTTPWriter) take() []byte {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	batch := w.buf
+	w.buf = nil
+	return batch
+}
+
+// enqueue queues the batch for the background goroutine started on demand, the batch
+// is dropped if the queue is full. It must be called with mu held.
+func (w *HTTPWriter) enqueue(batch []byte) {
+	if len(batch) == 0 {
+		return
+	}
+	if w.queue == nil {
+		size := w.QueueSize
+		if size <= 0 {
+			size = 8
+		}
+		w.queue = make(chan []byte, size)
+		w.done = make(chan struct{})
+		go w.run(w.queue, w.done)
+	}
+	select {
+	case w.queue <- batch:
+	default:
+		atomic.AddUint64(&w.dropped, uint64(bytes.Count(batch, []byte{'\n'})))
+	}
+}
+
+// run posts the queued batches one by one in order.
+func (w *HTTPWriter) run(queue chan []byte, done chan struct{}) {
+	defer close(done)
+	for batch := range queue {
+		if err := w.send(batch); err != nil && ErrorHandler != nil {
+			ErrorHandler(err)
+		}
+	}
+}
+
+func (w *HTTPWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.closed {
+		w.enqueue(w.take())
+	}
+}
+
+// send posts the batch with retries.
+func (w *HTTPWriter) send(batch []byte) (err error) {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	body := batch
+	if w.Gzip {
+		var b bytes.Buffer
+		gz := gzip.NewWriter(&b)
+		gz.Write(batch)
+		gz.Close()
+		body = b.Bytes()
+	}
+
+	retries := w.MaxRetries
+	if retries == 0 {
+		retries = 3
+	}
+	backoff := httpWriterBackoff
+	for i := 0; ; i++ {
+		var retry bool
+		if retry, err = w.do(body); err == nil {
+			return nil
+		}
+		if !retry || i >= retries {
+			break
+		}
+		atomic.AddUint64(&w.retried, 1)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	atomic.AddUint64(&w.dropped, uint64(bytes.Count(batch, []byte{'\n'})))
+	return fmt.Errorf("log: HTTPWriter post error: %v", err)
+}
+
+// do posts the body once, and returns whether the failure should be retried.
+func (w *HTTPWriter) do(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range w.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if w.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500, errors.New("unexpected status " + resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,534 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDebugHandler(t *testing.T) {
+	var forced bool
+	handler := DebugHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		level, ok := contextMinLevel(r.Context())
+		forced = ok && level == DebugLevel
+	}), "X-Debug-Log", "token")
+
+	cases := []struct {
+		Header string
+		Forced bool
+	}{
+		{"token", true},
+		{"wrong", false},
+		{"", false},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/foo", nil)
+		if c.Header != "" {
+			req.Header.Set("X-Debug-Log", c.Header)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if forced != c.Forced {
+			t.Errorf("debug header %q forced=%v, want %v", c.Header, forced, c.Forced)
+		}
+	}
+
+	handler = DebugHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, forced = contextMinLevel(r.Context())
+	}), "X-Debug-Log", "")
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/foo", nil))
+	if forced {
+		t.Errorf("empty secret should never match")
+	}
+}
+
+func TestHTTPHeader(t *testing.T) {
+	header := http.Header{
+		"Accept":        {"text/html", "application/json"},
+		"Authorization": {"Bearer secret"},
+		"Cookie":        {"a=1", "b=2"},
+		"User-Agent":    {strings.Repeat("x", 20)},
+		"x-api-key":     {"secret"},
+	}
+
+	cases := []struct {
+		Policy *HeaderPolicy
+		JSON   string
+	}{
+		{
+			nil,
+			`"header":{"Accept":["text/html","application/json"],"Authorization":"[redacted]","Cookie":"[redacted]","User-Agent":"xxxxxxxxxxxxxxxxxxxx","x-api-key":"[redacted]"}`,
+		},
+		{
+			&HeaderPolicy{MaxValueSize: 8},
+			`"header":{"Accept":["text/htm... 1 bytes truncated","applicat... 8 bytes truncated"],"Authorization":"[redacted]","Cookie":"[redacted]","User-Agent":"xxxxxxxx... 12 bytes truncated","x-api-key":"[redacted]"}`,
+		},
+		{
+			&HeaderPolicy{Allow: []string{"user-agent", "authorization"}},
+			`"header":{"Accept":"[redacted]","Authorization":"[redacted]","Cookie":"[redacted]","User-Agent":"xxxxxxxxxxxxxxxxxxxx","x-api-key":"[redacted]"}`,
+		},
+		{
+			&HeaderPolicy{Deny: []string{"ACCEPT"}},
+			`"header":{"Accept":"[redacted]","Authorization":"Bearer secret","Cookie":["a=1","b=2"],"User-Agent":"xxxxxxxxxxxxxxxxxxxx","x-api-key":"secret"}`,
+		},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{Writer: &buf}
+		logger.Info().HTTPHeader("header", header, c.Policy).Msg("")
+		if !strings.Contains(buf.String(), c.JSON) {
+			t.Errorf("HTTPHeader(%+v) got %s, want %s", c.Policy, buf.String(), c.JSON)
+		}
+	}
+}
+
+func TestHTTPRequest(t *testing.T) {
+	req := httptest.NewRequest("POST", "/foo?bar=1", nil)
+	req.Header.Set("Authorization", "Basic secret")
+	req.Header.Set("X-Request-Id", "abc")
+
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+
+	logger.Info().HTTPRequest("req", req).Msg("")
+	if s := buf.String(); !strings.Contains(s, `"req":{"method":"POST","uri":"/foo?bar=1","proto":"HTTP/1.1","host":"example.com","remote_addr":"192.0.2.1:1234"}`) {
+		t.Errorf("unexpected HTTPRequest output %s", s)
+	}
+
+	defer func(p *HeaderPolicy) { HTTPHeaderPolicy = p }(HTTPHeaderPolicy)
+	HTTPHeaderPolicy = &HeaderPolicy{}
+
+	buf.Reset()
+	logger.Info().HTTPRequest("req", req).Msg("")
+	if s := buf.String(); !strings.Contains(s, `"remote_addr":"192.0.2.1:1234","header":{"Authorization":"[redacted]","X-Request-Id":"abc"}}`) {
+		t.Errorf("unexpected HTTPRequest output %s", s)
+	}
+}
+
+func TestAccessLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &Logger{Writer: &buf}
+
+	handler := AccessLogHandler(logger, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(rw, r)
+		case "/copy":
+			io.Copy(rw, strings.NewReader("hello"))
+		default:
+			rw.Write([]byte("hello"))
+		}
+	}))
+
+	cases := []struct {
+		Path string
+		JSON string
+	}{
+		{"/foo?bar=1", `"level":"info","method":"GET","path":"/foo","query":"bar=1","remote_ip":"192.0.2.1","status":200,"size":5,"duration":"`},
+		{"/missing", `"level":"warn","method":"GET","path":"/missing","query":"","remote_ip":"192.0.2.1","status":404,"size":19,"duration":"`},
+		{"/copy", `"level":"info","method":"GET","path":"/copy","query":"","remote_ip":"192.0.2.1","status":200,"size":5,"duration":"`},
+	}
+
+	for _, c := range cases {
+		buf.Reset()
+		req := httptest.NewRequest("GET", c.Path, nil)
+		req.Header.Set("User-Agent", "test")
+		req.Header.Set("X-Request-Id", "abc")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if s := buf.String(); !strings.Contains(s, c.JSON) || !strings.HasSuffix(s, `"user_agent":"test","request_id":"abc"}`+"\n") {
+			t.Errorf("unexpected AccessLogHandler output %s", s)
+		}
+	}
+}
+
+func TestAccessLogHandlerHijack(t *testing.T) {
+	var buf lockedBuffer
+	logger := &Logger{Writer: &buf}
+
+	server := httptest.NewServer(AccessLogHandler(logger, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if _, ok := rw.(io.ReaderFrom); !ok {
+			t.Errorf("AccessLogHandler should keep io.ReaderFrom")
+		}
+		conn, brw, err := rw.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("AccessLogHandler hijack error: %+v", err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+	})))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("http client error: %+v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("hijacked response got status %d, want 101", resp.StatusCode)
+	}
+
+	logged := func() string {
+		buf.mu.Lock()
+		defer buf.mu.Unlock()
+		return buf.buf.String()
+	}
+	for i := 0; i < 100 && !strings.Contains(logged(), `"path":"/ws"`); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s := logged(); !strings.Contains(s, `"path":"/ws","query":"","remote_ip":"127.0.0.1","status":101,"size":0,`) {
+		t.Errorf("unexpected AccessLogHandler hijack output %s", s)
+	}
+}
+
+func TestAccessLogConfig(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &Logger{Writer: &buf}
+
+	handler := AccessLogConfig{
+		ForwardedFor:  true,
+		RecoverPanic:  true,
+		RemoteIPField: "client_ip",
+		StatusField:   "code",
+	}.Handler(logger, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("AccessLogConfig.RecoverPanic status %d, want 500", rec.Code)
+	}
+	if s := buf.String(); !strings.Contains(s, `"level":"error"`) || !strings.Contains(s, `"client_ip":"203.0.113.7","code":500`) || !strings.Contains(s, `"panic":"boom","stack":"`) {
+		t.Errorf("unexpected AccessLogConfig output %s", s)
+	}
+
+	handler = AccessLogHandler(logger, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("AccessLogHandler should re-panic, got %v", r)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestAccessLogConfigAnonymizeIP(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &Logger{Writer: &buf}
+
+	handler := AccessLogConfig{
+		ForwardedFor: true,
+		AnonymizeIP:  true,
+	}.Handler(logger, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	for _, c := range []struct {
+		ForwardedFor string
+		RemoteIP     string
+	}{
+		{"203.0.113.7", "203.0.113.0"},
+		{"2001:db8:1:2:3:4:5:6", "2001:db8:1::"},
+		{"unknown", "unknown"},
+	} {
+		buf.Reset()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-For", c.ForwardedFor)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if s := buf.String(); !strings.Contains(s, `"remote_ip":"`+c.RemoteIP+`"`) {
+			t.Errorf("AccessLogConfig.AnonymizeIP of %s got %s, want %s", c.ForwardedFor, s, c.RemoteIP)
+		}
+	}
+}
+
+func TestAccessLogConfigHeader(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &Logger{Writer: &buf}
+
+	handler := AccessLogConfig{
+		HeaderPolicy: &HeaderPolicy{Allow: []string{"Accept", "Authorization"}},
+	}.Handler(logger, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Trace", "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if s := buf.String(); !strings.Contains(s, `"header":{"Accept":"text/html","Authorization":"[redacted]","X-Trace":"[redacted]"}`) {
+		t.Errorf("unexpected AccessLogConfig.HeaderPolicy output %s", s)
+	}
+}
+
+func TestLoggingTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/missing":
+			rw.WriteHeader(http.StatusNotFound)
+		case "/fail":
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+		rw.Write(append([]byte("echo:"), body...))
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	transport := NewLoggingTransport(&Logger{Level: DebugLevel, Writer: &buf}, nil)
+	transport.RedactQuery = true
+	transport.MaxBodySize = 8
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Post(ts.URL+"/ok?token=secret&a=1", "text/plain", strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatalf("LoggingTransport post error: %+v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "echo:hello world" {
+		t.Errorf("LoggingTransport response body %q", body)
+	}
+	switch s := buf.String(); {
+	case nodebug:
+		// the debug event of a success is eliminated, the warn and error events below are not.
+		if s != "" {
+			t.Errorf("LoggingTransport should not log the success under log_nodebug: %s", s)
+		}
+	case !strings.Contains(s, `"level":"debug","method":"POST","url":"`+ts.URL+`/ok?token=[redacted]&a=[redacted]","status":200,"duration":"`) ||
+		!strings.Contains(s, `"request_body":"hello wo","response_body":"echo:hel"}`):
+		t.Errorf("unexpected LoggingTransport output %s", s)
+	}
+
+	buf.Reset()
+	resp, err = client.Get(ts.URL + "/missing")
+	if err != nil {
+		t.Fatalf("LoggingTransport get error: %+v", err)
+	}
+	resp.Body.Close()
+	if s := buf.String(); !strings.Contains(s, `"level":"warn","method":"GET","url":"`+ts.URL+`/missing","status":404,`) {
+		t.Errorf("unexpected LoggingTransport output %s", s)
+	}
+
+	buf.Reset()
+	resp, err = client.Get(ts.URL + "/fail")
+	if err != nil {
+		t.Fatalf("LoggingTransport get error: %+v", err)
+	}
+	resp.Body.Close()
+	if s := buf.String(); !strings.Contains(s, `"level":"error","method":"GET","url":"`+ts.URL+`/fail","status":500,`) {
+		t.Errorf("unexpected LoggingTransport output %s", s)
+	}
+
+	buf.Reset()
+	_, err = client.Get("http://127.0.0.1:1/")
+	if err == nil {
+		t.Fatalf("LoggingTransport should return the transport error")
+	}
+	if s := buf.String(); !strings.Contains(s, `"level":"error","method":"GET","url":"http://127.0.0.1:1/","duration":"`) || !strings.Contains(s, `"error":"`) {
+		t.Errorf("unexpected LoggingTransport output %s", s)
+	}
+}
+
+func TestHTTPWriter(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			body, _ = gzip.NewReader(r.Body)
+		}
+		b, _ := ioutil.ReadAll(body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	for _, gz := range []bool{false, true} {
+		bodies = nil
+		w := &HTTPWriter{
+			URL:       ts.URL,
+			Header:    http.Header{"Authorization": {"Bearer token"}},
+			Gzip:      gz,
+			BatchSize: 100,
+		}
+
+		logger := Logger{Writer: w}
+		for i := 0; i < 5; i++ {
+			logger.Info().Int("seq", i).Msg("hello http")
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("http writer close error: %+v", err)
+		}
+
+		mu.Lock()
+		all := strings.Join(bodies, "")
+		if len(bodies) < 2 {
+			t.Errorf("http writer should post %d bytes in batches, got %d batches", len(all), len(bodies))
+		}
+		for i := 0; i < 5; i++ {
+			if !strings.Contains(all, `"seq":`+strconv.Itoa(i)+`,`) {
+				t.Errorf("http writer lost line %d: %s", i, all)
+			}
+		}
+		mu.Unlock()
+	}
+}
+
+func TestHTTPWriterFlushInterval(t *testing.T) {
+	posted := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		posted <- string(b)
+	}))
+	defer ts.Close()
+
+	w := &HTTPWriter{URL: ts.URL, FlushInterval: 10 * time.Millisecond}
+	defer w.Close()
+
+	w.Write([]byte(`{"message":"hello interval"}` + "\n"))
+	select {
+	case body := <-posted:
+		if body != `{"message":"hello interval"}`+"\n" {
+			t.Errorf("http writer posted unexpected body: %q", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("http writer should post after the flush interval")
+	}
+}
+
+func TestHTTPWriterCloseInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var posted int32
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		close(started)
+		<-release
+		atomic.AddInt32(&posted, 1)
+	}))
+	defer ts.Close()
+	var once sync.Once
+	defer once.Do(func() { close(release) })
+
+	w := &HTTPWriter{URL: ts.URL, FlushInterval: 10 * time.Millisecond}
+	w.Write([]byte(`{"message":"hello in flight"}` + "\n"))
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("http writer should post after the flush interval")
+	}
+
+	closed := make(chan error)
+	go func() { closed <- w.Close() }()
+	select {
+	case <-closed:
+		t.Fatalf("http writer close should wait for the batch in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	once.Do(func() { close(release) })
+	if err := <-closed; err != nil {
+		t.Errorf("http writer close error: %+v", err)
+	}
+	if n := atomic.LoadInt32(&posted); n != 1 {
+		t.Errorf("http writer posted %d batches before close returned, want 1", n)
+	}
+}
+
+func TestHTTPWriterQueue(t *testing.T) {
+	release := make(chan struct{})
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+	}))
+	defer ts.Close()
+
+	w := &HTTPWriter{URL: ts.URL, BatchSize: 1, QueueSize: 1}
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if _, err := w.Write([]byte("line " + strconv.Itoa(i) + "\n")); err != nil {
+			t.Fatalf("http writer write error: %+v", err)
+		}
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("http writer should not wait for the endpoint, took %s", d)
+	}
+	if n := w.Dropped(); n == 0 {
+		t.Errorf("http writer should drop the batches of a full queue")
+	}
+
+	close(release)
+	if err := w.Close(); err != nil {
+		t.Errorf("http writer close error: %+v", err)
+	}
+	if n := uint64(atomic.LoadInt32(&requests)) + w.Dropped(); n != 10 {
+		t.Errorf("http writer posted and dropped %d lines, want 10", n)
+	}
+}
+
+func TestHTTPWriterRetry(t *testing.T) {
+	defer func(d time.Duration) { httpWriterBackoff = d }(httpWriterBackoff)
+	httpWriterBackoff = time.Millisecond
+
+	var requests int32
+	status := http.StatusServiceUnavailable
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			rw.WriteHeader(status)
+		}
+	}))
+	defer ts.Close()
+
+	w := &HTTPWriter{URL: ts.URL, MaxRetries: 2}
+	w.Write([]byte("line 0\n"))
+	if err := w.Close(); err != nil {
+		t.Errorf("http writer should succeed after retries: %+v", err)
+	}
+	if n := w.Retried(); n != 2 {
+		t.Errorf("http writer retried %d requests, want 2", n)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	w = &HTTPWriter{URL: ts.URL, MaxRetries: 1}
+	w.Write([]byte("line 0\nline 1\n"))
+	if err := w.Close(); err == nil {
+		t.Errorf("http writer should fail after retries")
+	}
+	if n := w.Dropped(); n != 2 {
+		t.Errorf("http writer dropped %d lines, want 2", n)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	status = http.StatusBadRequest
+	w = &HTTPWriter{URL: ts.URL}
+	w.Write([]byte("line 0\n"))
+	if err := w.Close(); err == nil {
+		t.Errorf("http writer should fail on 4xx")
+	}
+	if n := w.Retried(); n != 0 {
+		t.Errorf("http writer should not retry on 4xx, retried %d", n)
+	}
+}
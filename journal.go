@@ -0,0 +1,134 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JournalWriter is an io.WriteCloser and LevelWriter that writes the events to the
+// systemd journal by its native protocol. The level of an event is mapped to the
+// PRIORITY field like SyslogWriter, the "message" field to the MESSAGE field and the
+// other fields to the upper cased journal fields, e.g. "user_id" to USER_ID.
+//
+// It is only supported on linux, it writes to Fallback on the other platforms.
+type JournalWriter struct {
+	// Tag specifies the SYSLOG_IDENTIFIER field. It uses the base name of os.Args[0] in if empty.
+	Tag string
+
+	// Fallback specifies the writer of output on the platforms without journal. It uses os.Stderr in if empty.
+	Fallback io.Writer
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+	buf  []byte
+}
+
+// journalSocket is the native protocol socket of systemd-journald.
+var journalSocket = "/run/systemd/journal/socket"
+
+// Write implements io.Writer, the level is parsed from the level field of p.
+func (w *JournalWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(levelOf(p), p)
+}
+
+// Describe implements WriterDescriber.
+func (w *JournalWriter) Describe() string {
+	return fmt.Sprintf("JournalWriter{Socket:%s}", journalSocket)
+}
+
+// encode appends the journal fields of the event p to dst.
+func (w *JournalWriter) encode(dst []byte, level Level, p []byte) []byte {
+	tag := w.Tag
+	if tag == "" {
+		tag = program
+	}
+	dst = appendJournalField(dst, "PRIORITY", strconv.Itoa(syslogSeverity(level)))
+	dst = appendJournalField(dst, "SYSLOG_IDENTIFIER", tag)
+
+	var m map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	if decoder.Decode(&m) != nil {
+		return appendJournalField(dst, "MESSAGE", string(bytes.TrimSuffix(p, []byte{'\n'})))
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if k != "level" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var s string
+		switch v := m[k].(type) {
+		case string:
+			s = v
+		case json.Number:
+			s = v.String()
+		case bool:
+			s = strconv.FormatBool(v)
+		case nil:
+			s = "null"
+		default:
+			b, _ := json.Marshal(v)
+			s = string(b)
+		}
+		if k == "message" {
+			dst = appendJournalField(dst, "MESSAGE", s)
+		} else {
+			dst = appendJournalField(dst, journalFieldName(k), s)
+		}
+	}
+
+	return dst
+}
+
+// appendJournalField appends a field of the journal native protocol to dst, a
+// value of multiple lines is prefixed by its little endian 64-bit size.
+func appendJournalField(dst []byte, name, value string) []byte {
+	dst = append(dst, name...)
+	if strings.IndexByte(value, '\n') < 0 {
+		dst = append(dst, '=')
+	} else {
+		var size [8]byte
+		binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+		dst = append(dst, '\n')
+		dst = append(dst, size[:]...)
+	}
+	dst = append(dst, value...)
+	return append(dst, '\n')
+}
+
+// journalFieldName returns the journal field name of key, which consists of the
+// upper case letters, digits and underscores, does not start with an underscore
+// or a digit and is at most 64 bytes.
+func journalFieldName(key string) string {
+	b := make([]byte, 0, len(key)+1)
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			b = append(b, c)
+		case c >= 'a' && c <= 'z':
+			b = append(b, c-'a'+'A')
+		case len(b) != 0:
+			b = append(b, '_')
+		}
+	}
+	if len(b) == 0 || b[0] >= '0' && b[0] <= '9' {
+		b = append([]byte{'X'}, b...)
+	}
+	if len(b) > 64 {
+		b = b[:64]
+	}
+	return string(b)
+}
@@ -0,0 +1,98 @@
+// +build linux
+
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"syscall"
+)
+
+// NewJournalWriter returns a JournalWriter, or an error if the journal socket does not exist.
+func NewJournalWriter() (*JournalWriter, error) {
+	if _, err := os.Stat(journalSocket); err != nil {
+		return nil, fmt.Errorf("log: systemd journal is not available: %v", err)
+	}
+	return &JournalWriter{}, nil
+}
+
+// WriteLevel implements LevelWriter.
+func (w *JournalWriter) WriteLevel(level Level, p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		w.conn, err = net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+		if err != nil {
+			w.conn = nil
+			return 0, fmt.Errorf("log: JournalWriter dial error: %v", err)
+		}
+	}
+
+	w.buf = w.encode(w.buf[:0], level, p)
+	_, err = w.conn.Write(w.buf)
+	if isMsgTooLarge(err) {
+		err = w.writeFile(w.buf)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// writeFile passes a datagram which exceeds the socket buffer to the journal by
+// the descriptor of an unlinked temporary file.
+func (w *JournalWriter) writeFile(b []byte) error {
+	f, err := ioutil.TempFile("/dev/shm", "journal")
+	if err != nil {
+		f, err = ioutil.TempFile("", "journal")
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	os.Remove(f.Name())
+
+	if _, err = f.Write(b); err != nil {
+		return err
+	}
+
+	// WriteMsgUnix refuses a connected datagram socket, so call sendmsg directly.
+	rc, err := w.conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rights := syscall.UnixRights(int(f.Fd()))
+	err1 := rc.Write(func(fd uintptr) bool {
+		err = syscall.Sendmsg(int(fd), nil, rights, nil, 0)
+		return err != syscall.EAGAIN
+	})
+	if err1 != nil {
+		return err1
+	}
+	return err
+}
+
+// Close implements io.Closer, and closes the connection to the journal.
+func (w *JournalWriter) Close() (err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		err = w.conn.Close()
+		w.conn = nil
+	}
+	return
+}
+
+func isMsgTooLarge(err error) bool {
+	if e, ok := err.(*net.OpError); ok {
+		if e, ok := e.Err.(*os.SyscallError); ok {
+			return e.Err == syscall.EMSGSIZE || e.Err == syscall.ENOBUFS
+		}
+	}
+	return false
+}
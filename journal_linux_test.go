@@ -0,0 +1,83 @@
+// +build linux
+
+package log
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestJournalWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatalf("temp dir error: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(s string) { journalSocket = s }(journalSocket)
+	journalSocket = filepath.Join(dir, "socket")
+
+	if _, err := NewJournalWriter(); err == nil {
+		t.Errorf("new journal writer should fail without the socket")
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("listen unixgram error: %+v", err)
+	}
+	defer conn.Close()
+
+	w, err := NewJournalWriter()
+	if err != nil {
+		t.Fatalf("new journal writer error: %+v", err)
+	}
+	defer w.Close()
+
+	logger := Logger{Writer: w}
+	logger.Warn().Str("foo", "bar").Msg("hello journal")
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read unixgram error: %+v", err)
+	}
+
+	s := string(buf[:n])
+	for _, field := range []string{"PRIORITY=4\n", "MESSAGE=hello journal\n", "FOO=bar\n"} {
+		if !strings.Contains(s, field) {
+			t.Errorf("journal writer output %q does not contain %q", s, field)
+		}
+	}
+
+	// a datagram which exceeds the socket buffer is passed by a file descriptor.
+	large := strings.Repeat("x", 1<<20)
+	if _, err := w.WriteLevel(InfoLevel, []byte(`{"message":"`+large+`"}`)); err != nil {
+		t.Fatalf("journal writer large write error: %+v", err)
+	}
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatalf("read unixgram error: %+v", err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("journal writer should pass a file descriptor: %+v", err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("journal writer should pass a file descriptor: %+v", err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "journal")
+	defer f.Close()
+	f.Seek(0, 0)
+	data, _ := ioutil.ReadAll(f)
+	if !strings.Contains(string(data), "MESSAGE="+large+"\n") {
+		t.Errorf("journal writer passed an unexpected file of %d bytes", len(data))
+	}
+}
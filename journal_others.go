@@ -0,0 +1,26 @@
+// +build !linux
+
+package log
+
+import (
+	"errors"
+	"os"
+)
+
+// NewJournalWriter returns an error because the systemd journal is only supported on linux.
+func NewJournalWriter() (*JournalWriter, error) {
+	return nil, errors.New("log: systemd journal is only supported on linux")
+}
+
+// WriteLevel implements LevelWriter, it writes p to Fallback.
+func (w *JournalWriter) WriteLevel(level Level, p []byte) (n int, err error) {
+	if w.Fallback != nil {
+		return w.Fallback.Write(p)
+	}
+	return os.Stderr.Write(p)
+}
+
+// Close implements io.Closer.
+func (w *JournalWriter) Close() (err error) {
+	return nil
+}
@@ -0,0 +1,45 @@
+package log
+
+import (
+	"testing"
+)
+
+func TestJournalWriterEncode(t *testing.T) {
+	w := &JournalWriter{Tag: "myapp"}
+
+	b := w.encode(nil, ErrorLevel, []byte(`{"time":"2019-07-10T05:35:54.277Z","level":"error","user_id":42,"ok":true,"obj":{"a":1},"message":"hello\njournal"}`+"\n"))
+	want := "PRIORITY=3\n" +
+		"SYSLOG_IDENTIFIER=myapp\n" +
+		"MESSAGE\n\x0d\x00\x00\x00\x00\x00\x00\x00hello\njournal\n" +
+		"OBJ={\"a\":1}\n" +
+		"OK=true\n" +
+		"TIME=2019-07-10T05:35:54.277Z\n" +
+		"USER_ID=42\n"
+	if string(b) != want {
+		t.Errorf("journal writer encode got %q, want %q", b, want)
+	}
+
+	b = w.encode(nil, NoLevel, []byte("not a json line\n"))
+	if want := "PRIORITY=6\nSYSLOG_IDENTIFIER=myapp\nMESSAGE=not a json line\n"; string(b) != want {
+		t.Errorf("journal writer encode got %q, want %q", b, want)
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	cases := []struct {
+		Key  string
+		Name string
+	}{
+		{"user_id", "USER_ID"},
+		{"http.method", "HTTP_METHOD"},
+		{"_hidden", "HIDDEN"},
+		{"1st", "X1ST"},
+		{"", "X"},
+	}
+
+	for _, c := range cases {
+		if name := journalFieldName(c.Key); name != c.Name {
+			t.Errorf("journal field name of %q got %q, want %q", c.Key, name, c.Name)
+		}
+	}
+}
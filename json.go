@@ -1,18 +1,24 @@
 package log
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 	"unsafe"
 )
 
@@ -27,6 +33,20 @@ var DefaultLogger = Logger{
 	Writer:     os.Stderr,
 }
 
+// NewDefault returns a logger which writes to os.Stderr by AutoWriter, i.e. a
+// colorized ConsoleWriter on a terminal and JSON otherwise.
+func NewDefault() *Logger {
+	return &Logger{
+		Level:  DebugLevel,
+		Writer: AutoWriter(os.Stderr),
+	}
+}
+
+// ErrorHandler is called whenever the writers of this package fail to write, and
+// whenever a Logger without its own ErrorHandler fails to write an event.
+// It is ignored in if nil.
+var ErrorHandler func(err error)
+
 // A Logger represents an active logging object that generates lines of JSON output to an io.Writer.
 type Logger struct {
 	// Level defines log levels.
@@ -36,44 +56,200 @@ type Logger struct {
 	// If set, the value of TimeField and TimeFormat will be ignored.
 	Timestamp bool
 
+	// TimestampMode specifies the unit of Timestamp. It uses milliseconds in if empty.
+	TimestampMode TimestampMode
+
 	// Caller determines if adds the file:line of the "caller" key.
 	Caller int
 
+	// CallerSkipFrames specifies the number of additional stack frames skipped by Caller,
+	// e.g. 1 for the callers of a package wrapping the logger.
+	CallerSkipFrames int
+
+	// CallerFullPath determines if the caller file keeps its full path, e.g.
+	// "/src/github.com/phuslu/log/json.go:42".
+	CallerFullPath bool
+
+	// CallerPackagePath determines if the caller file keeps its last two path segments,
+	// e.g. "log/json.go:42". It is ignored if CallerFullPath is set.
+	CallerPackagePath bool
+
+	// CallerWithFunc determines if the function name is appended to the caller,
+	// e.g. "json.go:42 log.(*Logger).Info".
+	CallerWithFunc bool
+
 	// TimeField defines the time filed name in output.  It uses "time" in if empty.
 	TimeField string
 
+	// LevelField defines the level field name in output. It uses "level" in if empty.
+	// The writers which parse the level of an event from its "level" field, e.g.
+	// ConsoleWriter and FileWriter called by Write, do not recognize the other names.
+	LevelField string
+
+	// MessageField defines the message field name in output. It uses "message" in if empty.
+	MessageField string
+
+	// CallerField defines the caller field name in output. It uses "caller" in if empty.
+	CallerField string
+
+	// ErrorField defines the field name of Err in output. It uses "error" in if empty.
+	ErrorField string
+
+	// ErrorTypeField specifies the key for the concrete type name of the error of Err in output if not empty
+	ErrorTypeField string
+
+	// TraceIDField defines the trace id field name of Event.Ctx in output. It uses "trace_id" in if empty.
+	TraceIDField string
+
+	// SpanIDField defines the span id field name of Event.Ctx in output. It uses "span_id" in if empty.
+	SpanIDField string
+
 	// TimeFormat specifies the time format in output. It uses time.RFC3389 in if empty.
 	TimeFormat string
 
 	// HostField specifies the key for hostname in output if not empty
 	HostField string
 
+	// GoroutineField specifies the key for the goroutine id in output if not empty
+	GoroutineField string
+
+	// PIDField specifies the key for the process id in output if not empty
+	PIDField string
+
+	// ProgramField specifies the key for the base name of os.Args[0] in output if not empty
+	ProgramField string
+
 	// Writer specifies the writer of output. It uses os.Stderr in if empty.
 	Writer io.Writer
+
+	// Context specifies the contextual fields added to every event, it is built by NewContext.
+	Context Context
+
+	// ValidateJSON determines if every output line is checked by json.Valid before writing.
+	// An invalid line is followed by a diagnostic event and reported to ErrorHandler.
+	// It is intended for debugging and testing.
+	ValidateJSON bool
+
+	// RawJSONMode specifies how RawJSON handles its input. It uses RawJSONValidate in if empty.
+	RawJSONMode RawJSONMode
+
+	// MaxInterfaceSize limits the encoded size of Interface fields in bytes, a larger field
+	// is truncated with a marker. It is unlimited in if zero.
+	MaxInterfaceSize int
+
+	// EscapeHTML determines if the HTML sensitive characters <, >, & and ' are escaped
+	// in strings like json.Encoder.SetEscapeHTML, e.g. < is written as \u003c.
+	EscapeHTML bool
+
+	// ErrorHandler is called whenever the writer fails to write an event or writes it
+	// short. It uses the package ErrorHandler in if nil.
+	ErrorHandler func(err error)
+
+	// LevelEncoder specifies the encoding of the level field, e.g. LevelEncoderGCP for
+	// Google Cloud Logging. It uses LevelEncoderLowercase in if empty.
+	// The writers which parse the level field like LevelField do not recognize
+	// the GCP severities above "ERROR" and the numeric severities.
+	LevelEncoder LevelEncoder
+
+	// Schema specifies the names of the time, level, caller, message, error and host
+	// fields, e.g. ECS for Elasticsearch. It overrides the field names above and the
+	// name of HostField. It uses DefaultSchema in if empty.
+	Schema Schema
+
+	// Hooks specifies the hooks which run in order before every event is written.
+	Hooks []Hook
+
+	// Sampler specifies the sampler consulted after the level check, the events it
+	// rejects are never started. The fatal and panic events are never sampled.
+	Sampler Sampler
+
+	// w is the *io.Writer set by SetWriter, it takes precedence over Writer.
+	w unsafe.Pointer
+
+	// levelfns is the *levelNotifier of the callbacks registered by OnLevelChange.
+	levelfns unsafe.Pointer
+
+	// names is the *fieldNames of the field names, it is rebuilt if they are changed.
+	names unsafe.Pointer
 }
 
-// Event represents a log event. It is instanced by one of the level method of Logger and finalized by the Msg or Msgf method.
-type Event struct {
-	buf   []byte
-	w     io.Writer
-	stack bool
-	exit  bool
+// TimestampMode defines the unit of the UNIX timestamp used by Logger.Timestamp.
+type TimestampMode int
+
+const (
+	// TimestampMilli formats timestamp as milliseconds integer, e.g. 1585211234567
+	TimestampMilli TimestampMode = iota
+	// TimestampSecond formats timestamp as seconds integer, e.g. 1585211234
+	TimestampSecond
+	// TimestampFloat formats timestamp as seconds with fractional milliseconds, e.g. 1585211234.567
+	TimestampFloat
+	// TimestampMicro formats timestamp as microseconds integer, e.g. 1585211234567890
+	TimestampMicro
+	// TimestampNano formats timestamp as nanoseconds integer, e.g. 1585211234567890123
+	TimestampNano
+)
+
+// Schema defines the names of the fields added by Logger.
+type Schema int
+
+const (
+	// DefaultSchema names the fields "time", "level", "caller", "message" and "error".
+	DefaultSchema Schema = iota
+	// ECS names the fields by the Elastic Common Schema, i.e. "@timestamp", "log.level",
+	// "log.origin.file.name" and "log.origin.file.line", "message", "error.message" and
+	// "host.name", and adds the "ecs.version" field.
+	ECS
+)
+
+// ecsVersion is the version of the Elastic Common Schema used by ECS.
+const ecsVersion = "1.6.0"
+
+// RawJSONMode defines how Event.RawJSON handles the caller-provided bytes.
+type RawJSONMode int
+
+const (
+	// RawJSONValidate checks the input by json.Valid, an invalid input is embedded as
+	// an escaped string with an adjacent "_raw_json_error" field.
+	RawJSONValidate RawJSONMode = iota
+	// RawJSONCompact validates the input like RawJSONValidate and also removes the insignificant spaces.
+	RawJSONCompact
+	// RawJSONStrict appends the input verbatim without any check, the caller must ensure it is valid.
+	RawJSONStrict
+)
+
+// LevelWriter is the interface implemented by writers that want to receive
+// the level of the event alongside its encoded bytes.
+// Msg calls WriteLevel instead of Write when the Logger's writer implements it.
+// The p is only valid for the duration of the call and must not be retained.
+type LevelWriter interface {
+	WriteLevel(level Level, p []byte) (n int, err error)
 }
 
-// Debug starts a new message with debug level.
-func Debug() (e *Event) {
-	e = DefaultLogger.header(DebugLevel)
-	if e != nil && DefaultLogger.Caller > 0 {
-		e.caller(runtime.Caller(DefaultLogger.Caller))
-	}
-	return
+// Event represents a log event. It is instanced by one of the level method of Logger and finalized by the Msg or Msgf method.
+type Event struct {
+	buf      []byte
+	w        io.Writer
+	escapes  *[256]bool
+	leak     *eventLeak
+	parent   *Logger
+	level    Level
+	maxiface int
+	rawjson  RawJSONMode
+	stack    bool
+	exit     bool
+	panic    bool
+	validate bool
+	done     bool
+	ecs      bool
+	hooks    []Hook
+	names    *fieldNames
 }
 
 // Info starts a new message with info level.
 func Info() (e *Event) {
 	e = DefaultLogger.header(InfoLevel)
 	if e != nil && DefaultLogger.Caller > 0 {
-		e.caller(runtime.Caller(DefaultLogger.Caller))
+		e.caller(runtime.Caller(DefaultLogger.Caller + DefaultLogger.CallerSkipFrames))
 	}
 	return
 }
@@ -82,7 +258,7 @@ func Info() (e *Event) {
 func Warn() (e *Event) {
 	e = DefaultLogger.header(WarnLevel)
 	if e != nil && DefaultLogger.Caller > 0 {
-		e.caller(runtime.Caller(DefaultLogger.Caller))
+		e.caller(runtime.Caller(DefaultLogger.Caller + DefaultLogger.CallerSkipFrames))
 	}
 	return
 }
@@ -91,7 +267,7 @@ func Warn() (e *Event) {
 func Error() (e *Event) {
 	e = DefaultLogger.header(ErrorLevel)
 	if e != nil && DefaultLogger.Caller > 0 {
-		e.caller(runtime.Caller(DefaultLogger.Caller))
+		e.caller(runtime.Caller(DefaultLogger.Caller + DefaultLogger.CallerSkipFrames))
 	}
 	return
 }
@@ -100,43 +276,60 @@ func Error() (e *Event) {
 func Fatal() (e *Event) {
 	e = DefaultLogger.header(FatalLevel)
 	if e != nil && DefaultLogger.Caller > 0 {
-		e.caller(runtime.Caller(DefaultLogger.Caller))
+		e.caller(runtime.Caller(DefaultLogger.Caller + DefaultLogger.CallerSkipFrames))
+	}
+	return
+}
+
+// Panic starts a new message with panic level, Msg panics with the message after writing it.
+func Panic() (e *Event) {
+	e = DefaultLogger.header(PanicLevel)
+	if e == nil {
+		return
+	}
+	e.panic = true
+	if DefaultLogger.Caller > 0 {
+		e.caller(runtime.Caller(DefaultLogger.Caller + DefaultLogger.CallerSkipFrames))
 	}
 	return
 }
 
+// Err starts a new message with error level with err as a field if not nil or with info level otherwise.
+func Err(err error) (e *Event) {
+	if err != nil {
+		e = DefaultLogger.header(ErrorLevel)
+	} else {
+		e = DefaultLogger.header(InfoLevel)
+	}
+	if e != nil && DefaultLogger.Caller > 0 {
+		e.caller(runtime.Caller(DefaultLogger.Caller + DefaultLogger.CallerSkipFrames))
+	}
+	return e.Err(err)
+}
+
 // Print sends a log event using debug level and no extra field. Arguments are handled in the manner of fmt.Print.
 func Print(v ...interface{}) {
-	e := DefaultLogger.header(DefaultLogger.Level)
+	e := DefaultLogger.header(DefaultLogger.level())
 	if e != nil && DefaultLogger.Caller > 0 {
-		e.caller(runtime.Caller(DefaultLogger.Caller))
+		e.caller(runtime.Caller(DefaultLogger.Caller + DefaultLogger.CallerSkipFrames))
 	}
 	e.print(v...)
 }
 
 // Printf sends a log event using debug level and no extra field. Arguments are handled in the manner of fmt.Printf.
 func Printf(format string, v ...interface{}) {
-	e := DefaultLogger.header(DefaultLogger.Level)
+	e := DefaultLogger.header(DefaultLogger.level())
 	if e != nil && DefaultLogger.Caller > 0 {
-		e.caller(runtime.Caller(DefaultLogger.Caller))
+		e.caller(runtime.Caller(DefaultLogger.Caller + DefaultLogger.CallerSkipFrames))
 	}
 	e.Msgf(format, v...)
 }
 
-// Debug starts a new message with debug level.
-func (l *Logger) Debug() (e *Event) {
-	e = l.header(DebugLevel)
-	if e != nil && l.Caller > 0 {
-		e.caller(runtime.Caller(l.Caller))
-	}
-	return
-}
-
 // Info starts a new message with info level.
 func (l *Logger) Info() (e *Event) {
 	e = l.header(InfoLevel)
 	if e != nil && l.Caller > 0 {
-		e.caller(runtime.Caller(l.Caller))
+		e.caller(runtime.Caller(l.Caller + l.CallerSkipFrames))
 	}
 	return
 }
@@ -145,7 +338,7 @@ func (l *Logger) Info() (e *Event) {
 func (l *Logger) Warn() (e *Event) {
 	e = l.header(WarnLevel)
 	if e != nil && l.Caller > 0 {
-		e.caller(runtime.Caller(l.Caller))
+		e.caller(runtime.Caller(l.Caller + l.CallerSkipFrames))
 	}
 	return
 }
@@ -154,7 +347,7 @@ func (l *Logger) Warn() (e *Event) {
 func (l *Logger) Error() (e *Event) {
 	e = l.header(ErrorLevel)
 	if e != nil && l.Caller > 0 {
-		e.caller(runtime.Caller(l.Caller))
+		e.caller(runtime.Caller(l.Caller + l.CallerSkipFrames))
 	}
 	return
 }
@@ -163,40 +356,210 @@ func (l *Logger) Error() (e *Event) {
 func (l *Logger) Fatal() (e *Event) {
 	e = l.header(FatalLevel)
 	if e != nil && l.Caller > 0 {
-		e.caller(runtime.Caller(l.Caller))
+		e.caller(runtime.Caller(l.Caller + l.CallerSkipFrames))
+	}
+	return
+}
+
+// Panic starts a new message with panic level, Msg panics with the message after writing it.
+// Unlike Panic, WithLevel(PanicLevel) writes the event without panicking.
+func (l *Logger) Panic() (e *Event) {
+	e = l.header(PanicLevel)
+	if e == nil {
+		return
+	}
+	e.panic = true
+	if l.Caller > 0 {
+		e.caller(runtime.Caller(l.Caller + l.CallerSkipFrames))
 	}
 	return
 }
 
+// Err starts a new message with error level with err as a field if not nil or with info level otherwise.
+func (l *Logger) Err(err error) (e *Event) {
+	if err != nil {
+		e = l.header(ErrorLevel)
+	} else {
+		e = l.header(InfoLevel)
+	}
+	if e != nil && l.Caller > 0 {
+		e.caller(runtime.Caller(l.Caller + l.CallerSkipFrames))
+	}
+	return e.Err(err)
+}
+
 // WithLevel starts a new message with level.
 func (l *Logger) WithLevel(level Level) (e *Event) {
 	e = l.header(level)
 	if e != nil && l.Caller > 0 {
-		e.caller(runtime.Caller(l.Caller))
+		e.caller(runtime.Caller(l.Caller + l.CallerSkipFrames))
 	}
 	return
 }
 
 // SetLevel changes logger default level.
+// The callbacks registered by OnLevelChange are called if the level is changed.
 func (l *Logger) SetLevel(level Level) {
+	if p := atomic.LoadPointer(&l.levelfns); p != nil {
+		(*levelNotifier)(p).notify(&l.Level, level)
+		return
+	}
 	atomic.StoreUint32((*uint32)(&l.Level), uint32(level))
-	return
+}
+
+// GetLevel returns the level of the logger, it is safe against concurrent SetLevel.
+func (l *Logger) GetLevel() Level {
+	return l.level()
+}
+
+// level returns the level of the logger, it is safe against concurrent SetLevel.
+func (l *Logger) level() Level {
+	return Level(atomic.LoadUint32((*uint32)(&l.Level)))
+}
+
+type levelNotifier struct {
+	mu  sync.Mutex
+	id  int
+	fns []levelCallback
+
+	// notifyMu serializes the notifications, it is not mu so that the callbacks
+	// can register and unregister callbacks.
+	notifyMu sync.Mutex
+}
+
+type levelCallback struct {
+	id int
+	fn func(old, new Level)
+}
+
+// notify changes level to new and calls the callbacks if it is changed. It holds
+// notifyMu across both, so the callbacks see the changes of concurrent SetLevel
+// calls in order.
+func (n *levelNotifier) notify(level *Level, new Level) {
+	n.notifyMu.Lock()
+	defer n.notifyMu.Unlock()
+
+	old := Level(atomic.SwapUint32((*uint32)(level), uint32(new)))
+	if old == new {
+		return
+	}
+
+	n.mu.Lock()
+	fns := n.fns
+	n.mu.Unlock()
+	for _, c := range fns {
+		c.fn(old, new)
+	}
+}
+
+// OnLevelChange registers fn to be called synchronously by SetLevel when the level of
+// logger is changed, it returns a function to unregister fn. A callback unregistered
+// during a notification may still receive that notification. The callbacks are
+// called one notification at a time, so fn must not call SetLevel of the logger.
+func (l *Logger) OnLevelChange(fn func(old, new Level)) (unsubscribe func()) {
+	p := atomic.LoadPointer(&l.levelfns)
+	if p == nil {
+		atomic.CompareAndSwapPointer(&l.levelfns, nil, unsafe.Pointer(&levelNotifier{}))
+		p = atomic.LoadPointer(&l.levelfns)
+	}
+	n := (*levelNotifier)(p)
+
+	n.mu.Lock()
+	n.id++
+	id := n.id
+	// copy on write, so notify can iterate the callbacks without the lock.
+	n.fns = append(n.fns[:len(n.fns):len(n.fns)], levelCallback{id, fn})
+	n.mu.Unlock()
+
+	return func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		for i, c := range n.fns {
+			if c.id == id {
+				fns := make([]levelCallback, 0, len(n.fns)-1)
+				n.fns = append(append(fns, n.fns[:i]...), n.fns[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// SetWriter changes the writer of logger atomically, it is safe to be called while
+// other goroutines are logging. The writer set by SetWriter takes precedence over
+// the Writer field, a nil w means os.Stderr.
+func (l *Logger) SetWriter(w io.Writer) {
+	atomic.StorePointer(&l.w, unsafe.Pointer(&w))
+}
+
+func (l *Logger) writer() io.Writer {
+	if p := atomic.LoadPointer(&l.w); p != nil {
+		return *(*io.Writer)(p)
+	}
+	return l.Writer
+}
+
+// Option overrides a setting of the Logger returned by Clone.
+type Option func(*Logger)
+
+// Clone returns a copy of the logger with opts applied.
+// The level and the hooks of the copy are independent of the original logger.
+func (l *Logger) Clone(opts ...Option) *Logger {
+	// the fields are listed by hand to skip the internal state, a new field of Logger
+	// must be added here, TestLoggerClone fails if it is missed.
+	c := &Logger{
+		Level:             l.level(),
+		Timestamp:         l.Timestamp,
+		TimestampMode:     l.TimestampMode,
+		Caller:            l.Caller,
+		CallerSkipFrames:  l.CallerSkipFrames,
+		CallerFullPath:    l.CallerFullPath,
+		CallerPackagePath: l.CallerPackagePath,
+		CallerWithFunc:    l.CallerWithFunc,
+		TimeField:         l.TimeField,
+		LevelField:        l.LevelField,
+		MessageField:      l.MessageField,
+		CallerField:       l.CallerField,
+		ErrorField:        l.ErrorField,
+		ErrorTypeField:    l.ErrorTypeField,
+		TraceIDField:      l.TraceIDField,
+		SpanIDField:       l.SpanIDField,
+		TimeFormat:        l.TimeFormat,
+		HostField:         l.HostField,
+		GoroutineField:    l.GoroutineField,
+		PIDField:          l.PIDField,
+		ProgramField:      l.ProgramField,
+		Writer:            l.writer(),
+		Context:           l.Context,
+		ValidateJSON:      l.ValidateJSON,
+		RawJSONMode:       l.RawJSONMode,
+		MaxInterfaceSize:  l.MaxInterfaceSize,
+		EscapeHTML:        l.EscapeHTML,
+		ErrorHandler:      l.ErrorHandler,
+		LevelEncoder:      l.LevelEncoder,
+		Schema:            l.Schema,
+		Hooks:             append([]Hook(nil), l.Hooks...),
+		Sampler:           l.Sampler,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Print sends a log event using debug level and no extra field. Arguments are handled in the manner of fmt.Print.
 func (l *Logger) Print(v ...interface{}) {
-	e := l.header(l.Level)
+	e := l.header(l.level())
 	if e != nil && l.Caller > 0 {
-		e.caller(runtime.Caller(l.Caller))
+		e.caller(runtime.Caller(l.Caller + l.CallerSkipFrames))
 	}
 	e.print(v...)
 }
 
 // Printf sends a log event using debug level and no extra field. Arguments are handled in the manner of fmt.Printf.
 func (l *Logger) Printf(format string, v ...interface{}) {
-	e := l.header(l.Level)
+	e := l.header(l.level())
 	if e != nil && l.Caller > 0 {
-		e.caller(runtime.Caller(l.Caller))
+		e.caller(runtime.Caller(l.Caller + l.CallerSkipFrames))
 	}
 	e.Msgf(format, v...)
 }
@@ -224,87 +587,253 @@ var timeNow = time.Now
 
 var hostname, _ = os.Hostname()
 
+var pid = os.Getpid()
+
+var program = filepath.Base(os.Args[0])
+
 func (l *Logger) header(level Level) *Event {
-	if uint32(level) < atomic.LoadUint32((*uint32)(&l.Level)) {
+	if level < l.level() {
+		return nil
+	}
+	if l.Sampler != nil && level != FatalLevel && level != PanicLevel && !l.Sampler.Sample(level) {
 		return nil
 	}
 	e := epool.Get().(*Event)
+	leakTrack(e)
 	e.buf = e.buf[:0]
+	e.level = level
 	e.stack = level == FatalLevel
 	e.exit = level == FatalLevel
-	if l.Writer != nil {
-		e.w = l.Writer
-	} else {
+	e.panic = false
+	e.parent = l
+	e.hooks = e.hooks[:0]
+	e.validate = l.ValidateJSON || validateJSON
+	e.escapes = l.escapes()
+	e.rawjson = l.RawJSONMode
+	e.maxiface = l.MaxInterfaceSize
+	if e.w = l.writer(); e.w == nil {
 		e.w = os.Stderr
 	}
+	if e.ecs = l.Schema == ECS; e.ecs {
+		e.names = &defaultFieldNames
+	} else {
+		e.names = l.fieldNames()
+	}
 	// time
-	if l.Timestamp {
-		e.buf = append(e.buf, "{\"time\":0465408000000"...)
-		sec, nsec := walltime()
-		// milli seconds
-		a := int64(nsec) / 1000000
-		is := a % 100 * 2
-		e.buf[20] = smallsString[is+1]
-		e.buf[19] = smallsString[is]
-		e.buf[18] = byte('0' + a/100)
-		// seconds
-		is = sec % 100 * 2
+	switch {
+	case e.ecs:
+		e.buf = append(e.buf, "{\"@timestamp\":"...)
+	case l.Timestamp:
+		e.buf = append(e.buf, "{\"time\":"...)
+	default:
+		e.buf = append(e.buf, e.names.time...)
+	}
+	switch {
+	case l.Timestamp:
+		e.timestamp(l.TimestampMode)
+	case l.TimeFormat == "":
+		e.time(walltime())
+	default:
+		e.buf = append(e.buf, '"')
+		e.buf = timeNow().AppendFormat(e.buf, l.TimeFormat)
+		e.buf = append(e.buf, '"')
+	}
+	// level
+	switch {
+	case e.ecs:
+		if level < Level(len(levelNames)) && levelNames[level] != "" {
+			e.buf = append(e.buf, ",\"log.level\":"...)
+			e.buf = append(e.buf, l.levelValue(level)...)
+		}
+		e.buf = append(e.buf, ",\"ecs.version\":\""+ecsVersion+"\""...)
+	case e.names == &defaultFieldNames && l.LevelEncoder == LevelEncoderLowercase:
+		if level < Level(len(levelFields)) {
+			e.buf = append(e.buf, levelFields[level]...)
+		}
+	case level < Level(len(levelNames)) && levelNames[level] != "":
+		e.buf = append(e.buf, e.names.level...)
+		e.buf = append(e.buf, l.levelValue(level)...)
+	}
+	// hostname
+	if l.HostField != "" {
+		if e.ecs {
+			e.buf = append(e.buf, ",\"host.name\":"...)
+		} else {
+			e.buf = append(e.buf, ',')
+			e.string(l.HostField)
+			e.buf = append(e.buf, ':')
+		}
+		e.string(hostname)
+	}
+	// process
+	if l.PIDField != "" {
+		if e.ecs {
+			e.buf = append(e.buf, ",\"process.pid\":"...)
+		} else {
+			e.buf = append(e.buf, ',')
+			e.string(l.PIDField)
+			e.buf = append(e.buf, ':')
+		}
+		e.buf = strconv.AppendInt(e.buf, int64(pid), 10)
+	}
+	if l.ProgramField != "" {
+		if e.ecs {
+			e.buf = append(e.buf, ",\"process.name\":"...)
+		} else {
+			e.buf = append(e.buf, ',')
+			e.string(l.ProgramField)
+			e.buf = append(e.buf, ':')
+		}
+		e.string(program)
+	}
+	// goroutine id
+	if l.GoroutineField != "" {
+		e.buf = append(e.buf, ',')
+		e.string(l.GoroutineField)
+		e.buf = append(e.buf, ':')
+		e.buf = strconv.AppendInt(e.buf, goid(), 10)
+	}
+	// context
+	if l.Context != nil {
+		e.buf = append(e.buf, l.Context...)
+	}
+	return e
+}
+
+// fieldNames is the encoded names of the fields added by Logger, e.g. `,"level":`.
+type fieldNames struct {
+	time    string
+	level   string
+	message string
+	caller  string
+	error   string
+
+	// config is the names configured by Logger.
+	config [5]string
+}
+
+var defaultFieldNames = newFieldNames([5]string{})
+
+// newFieldNames returns the encoded names of the time, level, message, caller and
+// error fields, an empty name is encoded as the default name.
+func newFieldNames(config [5]string) fieldNames {
+	var names [5]string
+	for i, name := range config {
+		if name == "" {
+			name = [...]string{"time", "level", "message", "caller", "error"}[i]
+		}
+		e := Event{escapes: &escapes}
+		e.string(name)
+		names[i] = string(e.buf)
+	}
+	return fieldNames{
+		time:    "{" + names[0] + ":",
+		level:   "," + names[1] + ":",
+		message: "," + names[2] + ":",
+		caller:  "," + names[3] + ":\"",
+		error:   "," + names[4] + ":",
+		config:  config,
+	}
+}
+
+// fieldNames returns the encoded field names of the logger, they are encoded once
+// and rebuilt only if the names are changed.
+func (l *Logger) fieldNames() *fieldNames {
+	config := [5]string{l.TimeField, l.LevelField, l.MessageField, l.CallerField, l.ErrorField}
+	if config == ([5]string{}) {
+		return &defaultFieldNames
+	}
+	if n := (*fieldNames)(atomic.LoadPointer(&l.names)); n != nil && n.config == config {
+		return n
+	}
+	n := newFieldNames(config)
+	atomic.StorePointer(&l.names, unsafe.Pointer(&n))
+	return &n
+}
+
+// levelValue returns the precomputed JSON value of the level field by LevelEncoder.
+func (l *Logger) levelValue(level Level) string {
+	if l.LevelEncoder < 0 || int(l.LevelEncoder) >= len(levelValues) {
+		return levelValues[LevelEncoderLowercase][level]
+	}
+	return levelValues[l.LevelEncoder][level]
+}
+
+func (l *Logger) escapes() *[256]bool {
+	if l.EscapeHTML {
+		return &htmlEscapes
+	}
+	return &escapes
+}
+
+func (e *Event) timestamp(mode TimestampMode) {
+	sec, nsec := walltime()
+	e.unixTimestamp(sec, nsec, mode)
+}
+
+func (e *Event) unixTimestamp(sec int64, nsec int32, mode TimestampMode) {
+	switch {
+	case sec >= 1000000000 && sec < 10000000000:
+		// the fast path of 10 digits seconds, from 2001-09-09 to 2286-11-20
+		n := len(e.buf)
+		e.buf = append(e.buf, "0465408000"...)
+		is := sec % 100 * 2
 		sec /= 100
-		e.buf[17] = smallsString[is+1]
-		e.buf[16] = smallsString[is]
+		e.buf[n+9] = smallsString[is+1]
+		e.buf[n+8] = smallsString[is]
 		is = sec % 100 * 2
 		sec /= 100
-		e.buf[15] = smallsString[is+1]
-		e.buf[14] = smallsString[is]
+		e.buf[n+7] = smallsString[is+1]
+		e.buf[n+6] = smallsString[is]
 		is = sec % 100 * 2
 		sec /= 100
-		e.buf[13] = smallsString[is+1]
-		e.buf[12] = smallsString[is]
+		e.buf[n+5] = smallsString[is+1]
+		e.buf[n+4] = smallsString[is]
 		is = sec % 100 * 2
 		sec /= 100
-		e.buf[11] = smallsString[is+1]
-		e.buf[10] = smallsString[is]
+		e.buf[n+3] = smallsString[is+1]
+		e.buf[n+2] = smallsString[is]
 		is = sec % 100 * 2
-		e.buf[9] = smallsString[is+1]
-		e.buf[8] = smallsString[is]
-	} else {
-		if l.TimeField == "" {
-			e.buf = append(e.buf, "{\"time\":"...)
-		} else {
-			e.buf = append(e.buf, '{', '"')
-			e.buf = append(e.buf, l.TimeField...)
-			e.buf = append(e.buf, '"', ':')
-		}
-		if l.TimeFormat == "" {
-			e.time(walltime())
-		} else {
-			e.buf = append(e.buf, '"')
-			e.buf = timeNow().AppendFormat(e.buf, l.TimeFormat)
-			e.buf = append(e.buf, '"')
+		e.buf[n+1] = smallsString[is+1]
+		e.buf[n] = smallsString[is]
+	case sec > 0:
+		e.buf = strconv.AppendInt(e.buf, sec, 10)
+	default:
+		// the seconds has no leading digit to prepend the fraction to, or is negative.
+		switch mode {
+		case TimestampSecond:
+			e.buf = strconv.AppendInt(e.buf, sec, 10)
+		case TimestampFloat:
+			e.buf = strconv.AppendFloat(e.buf, float64(sec*1000+int64(nsec)/1000000)/1000, 'f', 3, 64)
+		case TimestampMicro:
+			e.buf = strconv.AppendInt(e.buf, sec*1000000+int64(nsec)/1000, 10)
+		case TimestampNano:
+			e.buf = strconv.AppendInt(e.buf, sec*1000000000+int64(nsec), 10)
+		default:
+			e.buf = strconv.AppendInt(e.buf, sec*1000+int64(nsec)/1000000, 10)
 		}
+		return
 	}
-	// level
-	switch level {
-	case DebugLevel:
-		e.buf = append(e.buf, ",\"level\":\"debug\""...)
-	case InfoLevel:
-		e.buf = append(e.buf, ",\"level\":\"info\""...)
-	case WarnLevel:
-		e.buf = append(e.buf, ",\"level\":\"warn\""...)
-	case ErrorLevel:
-		e.buf = append(e.buf, ",\"level\":\"error\""...)
-	case FatalLevel:
-		e.buf = append(e.buf, ",\"level\":\"fatal\""...)
-	}
-	// hostname
-	if l.HostField != "" {
-		e.buf = append(e.buf, ',', '"')
-		e.buf = append(e.buf, l.HostField...)
-		e.buf = append(e.buf, '"', ':', '"')
-		e.buf = append(e.buf, hostname...)
-		e.buf = append(e.buf, '"')
+	// milli seconds
+	a := int64(nsec) / 1000000
+	is := a % 100 * 2
+	switch mode {
+	case TimestampSecond:
+	case TimestampFloat:
+		e.buf = append(e.buf, '.', byte('0'+a/100), smallsString[is], smallsString[is+1])
+	case TimestampMicro:
+		// micro seconds
+		a = int64(nsec) / 1000
+		i1, i2, i3 := a/10000*2, a/100%100*2, a%100*2
+		e.buf = append(e.buf, smallsString[i1], smallsString[i1+1], smallsString[i2], smallsString[i2+1], smallsString[i3], smallsString[i3+1])
+	case TimestampNano:
+		// nano seconds
+		a = int64(nsec)
+		i1, i2, i3, i4 := a/1000000%100*2, a/10000%100*2, a/100%100*2, a%100*2
+		e.buf = append(e.buf, byte('0'+a/100000000), smallsString[i1], smallsString[i1+1], smallsString[i2], smallsString[i2+1], smallsString[i3], smallsString[i3+1], smallsString[i4], smallsString[i4+1])
+	default:
+		e.buf = append(e.buf, byte('0'+a/100), smallsString[is], smallsString[is+1])
 	}
-	return e
 }
 
 // Time append append t formated as string using time.RFC3339Nano.
@@ -331,6 +860,30 @@ func (e *Event) TimeFormat(key string, timefmt string, t time.Time) *Event {
 	return e
 }
 
+// Times adds the field key with ts formated as a []string using time.RFC3339Nano.
+func (e *Event) Times(key string, ts []time.Time) *Event {
+	return e.TimesFormat(key, time.RFC3339Nano, ts)
+}
+
+// TimesFormat adds the field key with ts formated as a []string using timefmt.
+func (e *Event) TimesFormat(key string, timefmt string, ts []time.Time) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	e.buf = append(e.buf, '[')
+	for i, t := range ts {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = append(e.buf, '"')
+		e.buf = t.AppendFormat(e.buf, timefmt)
+		e.buf = append(e.buf, '"')
+	}
+	e.buf = append(e.buf, ']')
+	return e
+}
+
 // Bool append append the val as a bool to the event.
 func (e *Event) Bool(key string, b bool) *Event {
 	if e == nil {
@@ -389,16 +942,154 @@ func (e *Event) Durs(key string, d []time.Duration) *Event {
 	return e
 }
 
-// Err adds the field "error" with serialized err to the event.
+// Err adds the field "error" with serialized err to the event. An err implementing
+// ObjectMarshaler or json.Marshaler is added as its structured form.
 func (e *Event) Err(err error) *Event {
 	if e == nil {
 		return nil
 	}
+	switch {
+	case e.ecs:
+		e.buf = append(e.buf, ",\"error.message\":"...)
+	case e.names != nil:
+		e.buf = append(e.buf, e.names.error...)
+	default:
+		e.buf = append(e.buf, ",\"error\":"...)
+	}
 	if err == nil {
-		e.buf = append(e.buf, ",\"error\":null"...)
+		e.buf = append(e.buf, "null"...)
 	} else {
-		e.buf = append(e.buf, ",\"error\":"...)
+		e.errValue(err)
+		if e.parent != nil && e.parent.ErrorTypeField != "" {
+			e.key(e.parent.ErrorTypeField)
+			e.string(reflect.TypeOf(err).String())
+		}
+		if e.stack {
+			e.errorChain(err)
+		}
+	}
+	return e
+}
+
+// errValue adds err marshaled by MarshalObject or MarshalJSON if it implements
+// ObjectMarshaler or json.Marshaler, otherwise or if MarshalJSON fails, err.Error().
+func (e *Event) errValue(err error) {
+	switch v := err.(type) {
+	case ObjectMarshaler:
+		e.object(v)
+		return
+	case json.Marshaler:
+		if b := marshalJSON(v); b != nil {
+			e.buf = append(e.buf, b...)
+			return
+		}
+	}
+	e.string(err.Error())
+}
+
+// marshalJSON returns the compacted output of m.MarshalJSON, or nil if it fails,
+// panics or is invalid.
+func marshalJSON(m json.Marshaler) (b []byte) {
+	defer func() {
+		if recover() != nil {
+			b = nil
+		}
+	}()
+	data, err := m.MarshalJSON()
+	if err != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, data) != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// errorChain adds the "errorChain" field with the messages of the errors unwrapped
+// from err, and the "stack" field with the frames of the innermost StackTrace method
+// in the chain, e.g. of github.com/pkg/errors. The stacks of the goroutines are not
+// printed if the frames are added.
+func (e *Event) errorChain(err error) {
+	type unwrapper interface {
+		Unwrap() error
+	}
+	u, ok := err.(unwrapper)
+	pcs := errorStackTrace(err)
+	if !ok && pcs == nil {
+		return
+	}
+	if ok {
+		e.buf = append(e.buf, ",\"errorChain\":["...)
 		e.string(err.Error())
+		for err = u.Unwrap(); err != nil; err = u.Unwrap() {
+			e.buf = append(e.buf, ',')
+			e.string(err.Error())
+			if p := errorStackTrace(err); p != nil {
+				pcs = p
+			}
+			if u, ok = err.(unwrapper); !ok {
+				break
+			}
+		}
+		e.buf = append(e.buf, ']')
+	}
+	if pcs == nil {
+		return
+	}
+	e.buf = append(e.buf, ",\"stack\":["...)
+	frames := runtime.CallersFrames(pcs)
+	for i, more := 0, true; more; i++ {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = append(e.buf, "{\"file\":"...)
+		e.string(frame.File)
+		e.buf = append(e.buf, ",\"line\":"...)
+		e.buf = strconv.AppendInt(e.buf, int64(frame.Line), 10)
+		e.buf = append(e.buf, ",\"func\":"...)
+		e.string(frame.Function)
+		e.buf = append(e.buf, '}')
+	}
+	e.buf = append(e.buf, ']')
+	e.stack = false
+}
+
+// errorStackTrace returns the program counters of the StackTrace method of err, whose
+// result is a slice of uintptr kind, e.g. errors.StackTrace of github.com/pkg/errors.
+func errorStackTrace(err error) []uintptr {
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil
+	}
+	t := m.Type().Out(0)
+	if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	v := m.Call(nil)[0]
+	if v.Len() == 0 {
+		return nil
+	}
+	pcs := make([]uintptr, v.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(v.Index(i).Uint())
+	}
+	return pcs
+}
+
+// AnErr adds the field key with serialized err to the event, or null if err is nil.
+// Unlike Err, the key is not affected by ErrorField and ErrorTypeField of the logger.
+func (e *Event) AnErr(key string, err error) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	if err == nil {
+		e.buf = append(e.buf, "null"...)
+	} else {
+		e.errValue(err)
 	}
 	return e
 }
@@ -431,10 +1122,25 @@ func (e *Event) Float64(key string, f float64) *Event {
 		return nil
 	}
 	e.key(key)
-	e.buf = strconv.AppendFloat(e.buf, f, 'f', -1, 64)
+	e.float(f, 64)
 	return e
 }
 
+// float appends f as a JSON number in the shortest form of bitSize, NaN and infinities
+// are not valid numbers so they are appended as the strings "NaN", "+Inf" and "-Inf".
+func (e *Event) float(f float64, bitSize int) {
+	switch {
+	case math.IsNaN(f):
+		e.buf = append(e.buf, `"NaN"`...)
+	case math.IsInf(f, 1):
+		e.buf = append(e.buf, `"+Inf"`...)
+	case math.IsInf(f, -1):
+		e.buf = append(e.buf, `"-Inf"`...)
+	default:
+		e.buf = strconv.AppendFloat(e.buf, f, 'f', -1, bitSize)
+	}
+}
+
 // Floats64 adds the field key with f as a []float64 to the event.
 func (e *Event) Floats64(key string, f []float64) *Event {
 	if e == nil {
@@ -446,7 +1152,7 @@ func (e *Event) Floats64(key string, f []float64) *Event {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
 		}
-		e.buf = strconv.AppendFloat(e.buf, a, 'f', -1, 64)
+		e.float(a, 64)
 	}
 	e.buf = append(e.buf, ']')
 	return e
@@ -463,23 +1169,125 @@ func (e *Event) Floats32(key string, f []float32) *Event {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
 		}
-		e.buf = strconv.AppendFloat(e.buf, float64(a), 'f', -1, 64)
+		e.float(float64(a), 32)
 	}
 	e.buf = append(e.buf, ']')
 	return e
 }
 
-// Int64 adds the field key with i as a int64 to the event.
-func (e *Event) Int64(key string, i int64) *Event {
+// Ints adds the field key with a as a []int to the event.
+func (e *Event) Ints(key string, a []int) *Event {
 	if e == nil {
 		return nil
 	}
 	e.key(key)
-	e.buf = strconv.AppendInt(e.buf, i, 10)
-	return e
-}
-
-// Uint64 adds the field key with i as a uint64 to the event.
+	e.buf = append(e.buf, '[')
+	for i, v := range a {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = strconv.AppendInt(e.buf, int64(v), 10)
+	}
+	e.buf = append(e.buf, ']')
+	return e
+}
+
+// Ints64 adds the field key with a as a []int64 to the event.
+func (e *Event) Ints64(key string, a []int64) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	e.buf = append(e.buf, '[')
+	for i, v := range a {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = strconv.AppendInt(e.buf, v, 10)
+	}
+	e.buf = append(e.buf, ']')
+	return e
+}
+
+// Ints32 adds the field key with a as a []int32 to the event.
+func (e *Event) Ints32(key string, a []int32) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	e.buf = append(e.buf, '[')
+	for i, v := range a {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = strconv.AppendInt(e.buf, int64(v), 10)
+	}
+	e.buf = append(e.buf, ']')
+	return e
+}
+
+// Uints adds the field key with a as a []uint to the event.
+func (e *Event) Uints(key string, a []uint) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	e.buf = append(e.buf, '[')
+	for i, v := range a {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = strconv.AppendUint(e.buf, uint64(v), 10)
+	}
+	e.buf = append(e.buf, ']')
+	return e
+}
+
+// Uints64 adds the field key with a as a []uint64 to the event.
+func (e *Event) Uints64(key string, a []uint64) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	e.buf = append(e.buf, '[')
+	for i, v := range a {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = strconv.AppendUint(e.buf, v, 10)
+	}
+	e.buf = append(e.buf, ']')
+	return e
+}
+
+// Uints32 adds the field key with a as a []uint32 to the event.
+func (e *Event) Uints32(key string, a []uint32) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	e.buf = append(e.buf, '[')
+	for i, v := range a {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = strconv.AppendUint(e.buf, uint64(v), 10)
+	}
+	e.buf = append(e.buf, ']')
+	return e
+}
+
+// Int64 adds the field key with i as a int64 to the event.
+func (e *Event) Int64(key string, i int64) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	e.buf = strconv.AppendInt(e.buf, i, 10)
+	return e
+}
+
+// Uint64 adds the field key with i as a uint64 to the event.
 func (e *Event) Uint64(key string, i uint64) *Event {
 	if e == nil {
 		return nil
@@ -491,7 +1299,12 @@ func (e *Event) Uint64(key string, i uint64) *Event {
 
 // Float32 adds the field key with f as a float32 to the event.
 func (e *Event) Float32(key string, f float32) *Event {
-	return e.Float64(key, float64(f))
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	e.float(float64(f), 32)
+	return e
 }
 
 // Int adds the field key with i as a int to the event.
@@ -530,37 +1343,88 @@ func (e *Event) Uint8(key string, i uint8) *Event {
 }
 
 // RawJSON adds already encoded JSON to the log line under key.
+// The input is validated or compacted according to Logger.RawJSONMode.
 func (e *Event) RawJSON(key string, b []byte) *Event {
 	if e == nil {
 		return nil
 	}
 	e.key(key)
-	e.buf = append(e.buf, b...)
+	switch e.rawjson {
+	case RawJSONStrict:
+		e.buf = append(e.buf, b...)
+	case RawJSONCompact:
+		buf := bytes.NewBuffer(e.buf)
+		if err := json.Compact(buf, b); err != nil {
+			e.rawJSONError(b, err)
+		} else {
+			e.buf = buf.Bytes()
+		}
+	default:
+		if json.Valid(b) {
+			e.buf = append(e.buf, b...)
+		} else {
+			var v json.RawMessage
+			e.rawJSONError(b, json.Unmarshal(b, &v))
+		}
+	}
 	return e
 }
 
+func (e *Event) rawJSONError(b []byte, err error) {
+	e.bytes(b)
+	e.buf = append(e.buf, ",\"_raw_json_error\":"...)
+	e.string(err.Error())
+}
+
 // Str adds the field key with val as a string to the event.
 func (e *Event) Str(key string, val string) *Event {
+	return e.AppendKey(key).AppendStringValue(val)
+}
+
+// Strs adds the field key with vals as a []string to the event.
+func (e *Event) Strs(key string, vals []string) *Event {
+	e = e.AppendKey(key).AppendRaw([]byte{'['})
+	for i, val := range vals {
+		if i != 0 {
+			e = e.AppendRaw([]byte{','})
+		}
+		e = e.AppendStringValue(val)
+	}
+	return e.AppendRaw([]byte{']'})
+}
+
+// Stringer adds the field key with v.String() to the event, or null if v is nil.
+// The String method is not called if the event is disabled.
+func (e *Event) Stringer(key string, v fmt.Stringer) *Event {
 	if e == nil {
 		return nil
 	}
 	e.key(key)
-	e.string(val)
+	if v == nil {
+		e.buf = append(e.buf, "null"...)
+	} else {
+		e.string(v.String())
+	}
 	return e
 }
 
-// Strs adds the field key with vals as a []string to the event.
-func (e *Event) Strs(key string, vals []string) *Event {
+// Stringers adds the field key with the String of vals as a []string to the event,
+// a nil value is added as null.
+func (e *Event) Stringers(key string, vals []fmt.Stringer) *Event {
 	if e == nil {
 		return nil
 	}
 	e.key(key)
 	e.buf = append(e.buf, '[')
-	for i, val := range vals {
+	for i, v := range vals {
 		if i != 0 {
 			e.buf = append(e.buf, ',')
 		}
-		e.string(val)
+		if v == nil {
+			e.buf = append(e.buf, "null"...)
+		} else {
+			e.string(v.String())
+		}
 	}
 	e.buf = append(e.buf, ']')
 	return e
@@ -578,6 +1442,24 @@ func (e *Event) Bytes(key string, val []byte) *Event {
 
 var hex = "0123456789abcdef"
 
+// Base64 adds the field key with val as a base64 string encoded by enc to the event.
+// It uses base64.StdEncoding in if enc is nil.
+func (e *Event) Base64(key string, val []byte, enc *base64.Encoding) *Event {
+	if e == nil {
+		return nil
+	}
+	if enc == nil {
+		enc = base64.StdEncoding
+	}
+	e.key(key)
+	e.buf = append(e.buf, '"')
+	n := len(e.buf)
+	e.buf = append(e.buf, make([]byte, enc.EncodedLen(len(val)))...)
+	enc.Encode(e.buf[n:], val)
+	e.buf = append(e.buf, '"')
+	return e
+}
+
 // Hex adds the field key with val as a hex string to the event.
 func (e *Event) Hex(key string, val []byte) *Event {
 	if e == nil {
@@ -592,6 +1474,66 @@ func (e *Event) Hex(key string, val []byte) *Event {
 	return e
 }
 
+// Hexdump adds the field key with val as a classic hexdump string to the event,
+// 16 bytes per row with an ASCII gutter, the rows are separated by "\n".
+// The dump is truncated to max bytes with a marker if max is positive.
+func (e *Event) Hexdump(key string, val []byte, max int) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	e.buf = append(e.buf, '"')
+	n := len(val)
+	if max > 0 && n > max {
+		val = val[:max]
+	}
+	for i := 0; i < len(val); i += 16 {
+		if i != 0 {
+			e.buf = append(e.buf, '\\', 'n')
+		}
+		row := val[i:]
+		if len(row) > 16 {
+			row = row[:16]
+		}
+		for j := 28; j >= 0; j -= 4 {
+			e.buf = append(e.buf, hex[(i>>uint(j))&0x0f])
+		}
+		e.buf = append(e.buf, ' ')
+		for j := 0; j < 16; j++ {
+			if j == 8 {
+				e.buf = append(e.buf, ' ')
+			}
+			if j < len(row) {
+				e.buf = append(e.buf, ' ', hex[row[j]>>4], hex[row[j]&0x0f])
+			} else {
+				e.buf = append(e.buf, ' ', ' ', ' ')
+			}
+		}
+		e.buf = append(e.buf, ' ', ' ', '|')
+		for _, c := range row {
+			switch {
+			case c == '"' || c == '\\':
+				e.buf = append(e.buf, '\\', c)
+			case c >= 0x20 && c < 0x7f:
+				e.buf = append(e.buf, c)
+			default:
+				e.buf = append(e.buf, '.')
+			}
+		}
+		e.buf = append(e.buf, '|')
+	}
+	if len(val) < n {
+		if len(val) != 0 {
+			e.buf = append(e.buf, '\\', 'n')
+		}
+		e.buf = append(e.buf, "... "...)
+		e.buf = strconv.AppendInt(e.buf, int64(n-len(val)), 10)
+		e.buf = append(e.buf, " bytes truncated"...)
+	}
+	e.buf = append(e.buf, '"')
+	return e
+}
+
 // IPAddr adds IPv4 or IPv6 Address to the event
 func (e *Event) IPAddr(key string, ip net.IP) *Event {
 	if e == nil {
@@ -614,6 +1556,48 @@ func (e *Event) IPAddr(key string, ip net.IP) *Event {
 	return e
 }
 
+// IPv4AnonBits and IPv6AnonBits specify the number of trailing bits zeroed by IPAddrAnon.
+var (
+	IPv4AnonBits = 8
+	IPv6AnonBits = 80
+)
+
+// IPAddrAnon adds IPv4 or IPv6 Address to the event with its trailing bits zeroed,
+// i.e. the last octet of IPv4 and the last 80 bits of IPv6 by default.
+// The IPv4-mapped IPv6 Address is anonymized as IPv4.
+func (e *Event) IPAddrAnon(key string, ip net.IP) *Event {
+	if e == nil {
+		return nil
+	}
+	var a [net.IPv6len]byte
+	switch {
+	case ip == nil:
+		e.key(key)
+		e.buf = append(e.buf, "null"...)
+		return e
+	case ip.To4() != nil:
+		copy(a[:], ip.To4())
+		anonymizeIP(a[:net.IPv4len], IPv4AnonBits)
+		return e.IPAddr(key, a[:net.IPv4len])
+	case len(ip) == net.IPv6len:
+		copy(a[:], ip)
+		anonymizeIP(a[:], IPv6AnonBits)
+		return e.IPAddr(key, a[:])
+	}
+	return e.IPAddr(key, ip)
+}
+
+func anonymizeIP(b []byte, bits int) {
+	for i := len(b) - 1; i >= 0 && bits > 0; i-- {
+		if bits >= 8 {
+			b[i] = 0
+		} else {
+			b[i] &^= 1<<uint(bits) - 1
+		}
+		bits -= 8
+	}
+}
+
 // IPPrefix adds IPv4 or IPv6 Prefix (address and mask) to the event
 func (e *Event) IPPrefix(key string, pfx net.IPNet) *Event {
 	if e == nil {
@@ -662,16 +1646,71 @@ func (e *Event) TimeDiff(key string, t time.Time, start time.Time) *Event {
 	return e
 }
 
-// Caller adds the file:line of the "caller" key.
-func (e *Event) Caller() *Event {
+// Caller adds the file:line of the "caller" key, which is the caller of Caller.
+// The optional skip specifies the number of additional stack frames to ascend,
+// e.g. 1 for the caller of a function wrapping Caller.
+func (e *Event) Caller(skip ...int) *Event {
+	if e == nil {
+		return nil
+	}
+	n := 1
+	if len(skip) != 0 {
+		n += skip[0]
+	}
+	e.caller(runtime.Caller(n))
+	return e
+}
+
+// Goid adds the "goid" field with the id of the current goroutine.
+func (e *Event) Goid() *Event {
 	if e == nil {
 		return nil
 	}
-	e.caller(runtime.Caller(DefaultLogger.Caller))
+	e.key("goid")
+	e.buf = strconv.AppendInt(e.buf, goid(), 10)
 	return e
 }
 
-// Stack enables stack trace printing for the error passed to Err().
+// Callers adds the "callers" field, or "log.origin.callers" by the ECS schema, with the
+// file:line of at most depth stack frames as an array to the event, starting from the caller of Callers. The leading frames of this
+// package are skipped. The depth is capped at 32. The files are trimmed according to
+// CallerFullPath and CallerPackagePath of the logger.
+func (e *Event) Callers(depth int) *Event {
+	if e == nil {
+		return nil
+	}
+	var pcs [48]uintptr
+	if depth > 32 {
+		depth = 32
+	}
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	if e.ecs {
+		e.key("log.origin.callers")
+	} else {
+		e.key("callers")
+	}
+	e.buf = append(e.buf, '[')
+	for i, skip, more := 0, true, depth > 0; more && i < depth; {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+		if skip = skip && isPackageFrame(frame.File); skip || frame.File == "" {
+			continue
+		}
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = append(e.buf, '"')
+		e.fileLine(frame.File, frame.Line)
+		e.buf = append(e.buf, '"')
+		i++
+	}
+	e.buf = append(e.buf, ']')
+	return e
+}
+
+// Stack enables stack trace printing for the error passed to Err(). The Err of a wrapped
+// error adds the "errorChain" field of the unwrapped messages, and the "stack" field of
+// its stack frames if an error in the chain has a StackTrace method.
 func (e *Event) Stack() *Event {
 	if e == nil {
 		return nil
@@ -690,54 +1729,286 @@ func (e *Event) Discard() *Event {
 	if e == nil {
 		return e
 	}
-	if cap(e.buf) <= bbcap {
-		epool.Put(e)
-	}
+	leakDone(e)
+	e.w = nil
+	eventPut(e)
 	return nil
 }
 
+// Sample keeps the event once in every n calls randomly, the others are discarded
+// like Discard and return nil. The fatal and panic events are never sampled.
+func (e *Event) Sample(n uint32) *Event {
+	if e == nil || n <= 1 || e.exit || e.panic {
+		return e
+	}
+	if Fastrandn(n) != 0 {
+		return e.Discard()
+	}
+	return e
+}
+
+// SampleRate keeps the event randomly at the rate r in [0, 1], the others are
+// discarded like Discard and return nil. The fatal and panic events are never sampled.
+func (e *Event) SampleRate(r float64) *Event {
+	if e == nil || r >= 1 || e.exit || e.panic {
+		return e
+	}
+	if r <= 0 || Fastrandn(1<<24) >= uint32(r*(1<<24)) {
+		return e.Discard()
+	}
+	return e
+}
+
 var osExit = os.Exit
 
 // Msg sends the event with msg added as the message field if not empty.
+// The event is recycled after sending, a second Msg or Send on it is a no-op.
+// A write error is reported to the ErrorHandler of the logger.
 func (e *Event) Msg(msg string) {
-	if e == nil {
+	if e == nil || e.w == nil {
 		return
 	}
+	e.finish(msg, true)
+}
+
+// MsgErr is like Msg, but it returns the write error instead of reporting it to the
+// ErrorHandler of the logger, a short write is returned as io.ErrShortWrite.
+func (e *Event) MsgErr(msg string) error {
+	if e == nil || e.w == nil {
+		return nil
+	}
+	return e.finish(msg, false)
+}
+
+// validateJSON makes all loggers validate their output as ValidateJSON, it is set by tests.
+var validateJSON bool
+
+// finish sends the event with msg and recycles it, the write error is reported to
+// the ErrorHandler of the logger if report is true, or returned otherwise.
+func (e *Event) finish(msg string, report bool) (err error) {
+	if len(e.hooks) != 0 || e.parent != nil && len(e.parent.Hooks) != 0 {
+		e.runHooks(msg)
+	}
 	if msg != "" {
-		e.buf = append(e.buf, ",\"message\":"...)
+		e.buf = append(e.buf, e.names.message...)
 		e.string(msg)
 	}
 	e.buf = append(e.buf, '}', '\n')
-	e.w.Write(e.buf)
+	invalid := e.validate && !json.Valid(e.buf)
+	if w, ok := e.w.(messageWriter); ok {
+		var n int
+		if n, err = w.writeMessage(e.level, msg, e.buf); err == nil && n < len(e.buf) {
+			err = io.ErrShortWrite
+		}
+	} else {
+		err = e.flush(e.buf)
+	}
+	if invalid {
+		e.invalid()
+	}
 	if e.stack {
-		e.w.Write(stacks(false))
-		e.w.Write(stacks(true))
+		if werr := e.flush(stacks(false)); err == nil {
+			err = werr
+		}
+		if werr := e.flush(stacks(true)); err == nil {
+			err = werr
+		}
 	}
+	if err != nil && report {
+		e.parent.writeError(err)
+		err = nil
+	}
+	leakDone(e)
 	if e.exit {
 		osExit(255)
 	}
-	if cap(e.buf) <= bbcap {
-		epool.Put(e)
+	panicking := e.panic
+	e.w = nil
+	e.parent = nil
+	eventPut(e)
+	if panicking {
+		panic(msg)
+	}
+	return
+}
+
+// Send sends the event without the message field, it is equivalent to Msg("").
+func (e *Event) Send() {
+	e.Msg("")
+}
+
+func (e *Event) invalid() {
+	logger := Logger{Writer: e.w}
+	logger.Error().Bytes("line", e.buf).Msg("log: invalid JSON output")
+	if ErrorHandler != nil {
+		ErrorHandler(fmt.Errorf("log: invalid JSON output: %s", e.buf))
 	}
 }
 
+// flush writes p like write, a short write is returned as io.ErrShortWrite.
+func (e *Event) flush(p []byte) error {
+	n, err := e.write(p)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+// errorHandling holds the ids of the goroutines running an error handler of loggers.
+var errorHandling sync.Map
+
+// writeError reports the write error to the ErrorHandler of the logger, or to the
+// package ErrorHandler if it is nil. The errors raised by the goroutine running the
+// handler are dropped, so a handler which logs by a failing logger does not recurse,
+// while the errors of other goroutines are still reported.
+func (l *Logger) writeError(err error) {
+	h := ErrorHandler
+	if l != nil && l.ErrorHandler != nil {
+		h = l.ErrorHandler
+	}
+	if h == nil {
+		return
+	}
+	id := goid()
+	if _, busy := errorHandling.LoadOrStore(id, struct{}{}); busy {
+		return
+	}
+	defer errorHandling.Delete(id)
+	h(err)
+}
+
+// messageWriter is implemented by the writers which key the events by their messages,
+// Msg passes the message to writeMessage so they need not parse the message field of
+// the line, whose name may be changed by MessageField.
+type messageWriter interface {
+	writeMessage(level Level, msg string, p []byte) (int, error)
+}
+
+func (e *Event) write(p []byte) (int, error) {
+	if w, ok := e.w.(LevelWriter); ok {
+		return w.WriteLevel(e.level, p)
+	}
+	return e.w.Write(p)
+}
+
+// AppendKey appends the key of a field to the event, i.e. the leading comma, the
+// escaped key and the colon. It must be followed by exactly one JSON value appended
+// by AppendRaw or AppendStringValue, so the event is left after a complete member.
+func (e *Event) AppendKey(key string) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	return e
+}
+
+// AppendRaw appends the pre-encoded bytes b to the event verbatim. The caller must
+// ensure the bytes are valid JSON in the position, e.g. a complete value after
+// AppendKey, or the brackets and commas of an array value.
+func (e *Event) AppendRaw(b []byte) *Event {
+	if e == nil {
+		return nil
+	}
+	e.buf = append(e.buf, b...)
+	return e
+}
+
+// AppendStringValue appends s as a quoted JSON string to the event, with the
+// escaping rules of Str.
+func (e *Event) AppendStringValue(s string) *Event {
+	if e == nil {
+		return nil
+	}
+	e.string(s)
+	return e
+}
+
+// key appends the key of a field, it is escaped like a string value so a key with
+// quotes or control characters keeps the line valid.
 func (e *Event) key(key string) {
-	e.buf = append(e.buf, ',', '"')
-	e.buf = append(e.buf, key...)
-	e.buf = append(e.buf, '"', ':')
+	reuseCheck(e)
+	e.buf = append(e.buf, ',')
+	e.string(key)
+	e.buf = append(e.buf, ':')
 }
 
-func (e *Event) caller(_ uintptr, file string, line int, _ bool) {
-	if i := strings.LastIndex(file, "/"); i >= 0 {
-		file = file[i+1:]
+func (e *Event) caller(pc uintptr, file string, line int, _ bool) {
+	var fullpath, pkgpath, withfunc bool
+	if e.parent != nil {
+		fullpath, pkgpath, withfunc = e.parent.CallerFullPath, e.parent.CallerPackagePath, e.parent.CallerWithFunc
+	}
+	if !fullpath {
+		file = trimCallerFile(file, pkgpath)
+	}
+	var function string
+	if withfunc {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		function = frame.Function
+		if i := strings.LastIndex(function, "/"); i >= 0 && !fullpath {
+			function = function[i+1:]
+		}
+	}
+	if e.ecs {
+		e.buf = append(e.buf, ",\"log.origin.file.name\":\""...)
+		e.buf = append(e.buf, file...)
+		e.buf = append(e.buf, "\",\"log.origin.file.line\":"...)
+		e.buf = strconv.AppendInt(e.buf, int64(line), 10)
+		if function != "" {
+			e.buf = append(e.buf, ",\"log.origin.function\":"...)
+			e.string(function)
+		}
+		return
 	}
-	e.buf = append(e.buf, ",\"caller\":\""...)
+	e.buf = append(e.buf, e.names.caller...)
 	e.buf = append(e.buf, file...)
 	e.buf = append(e.buf, ':')
 	e.buf = strconv.AppendInt(e.buf, int64(line), 10)
+	if function != "" {
+		e.buf = append(e.buf, ' ')
+		e.buf = append(e.buf, function...)
+	}
 	e.buf = append(e.buf, '"')
 }
 
+// fileLine appends file:line of a frame, file is trimmed by the CallerFullPath and
+// CallerPackagePath of the logger like caller.
+func (e *Event) fileLine(file string, line int) {
+	var fullpath, pkgpath bool
+	if e.parent != nil {
+		fullpath, pkgpath = e.parent.CallerFullPath, e.parent.CallerPackagePath
+	}
+	if !fullpath {
+		file = trimCallerFile(file, pkgpath)
+	}
+	e.buf = append(e.buf, file...)
+	e.buf = append(e.buf, ':')
+	e.buf = strconv.AppendInt(e.buf, int64(line), 10)
+}
+
+// trimCallerFile returns the last path segment of file, or the last two path
+// segments if pkgpath is set.
+func trimCallerFile(file string, pkgpath bool) string {
+	i := strings.LastIndexByte(file, '/')
+	if i >= 0 && pkgpath {
+		i = strings.LastIndexByte(file[:i], '/')
+	}
+	return file[i+1:]
+}
+
+// packageDir is the source directory of this package, used to skip its frames.
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	if i := strings.LastIndex(file, "/"); i >= 0 {
+		return file[:i+1]
+	}
+	return ""
+}()
+
+func isPackageFrame(file string) bool {
+	return packageDir != "" && strings.HasPrefix(file, packageDir) &&
+		strings.IndexByte(file[len(packageDir):], '/') < 0 && !strings.HasSuffix(file, "_test.go")
+}
+
 const timebuf = "\"2006-01-02T15:04:05.999Z\""
 
 func (e *Event) time(sec int64, nsec int32) {
@@ -807,17 +2078,24 @@ func (e *Event) time(sec int64, nsec int32) {
 	e.buf[n+17] = ':'
 }
 
+// escapes is the bytes escaped in strings to keep JSON valid, i.e. the quote,
+// the backslash and the control characters.
 var escapes = func() (a [256]bool) {
+	for i := 0; i < 0x20; i++ {
+		a[i] = true
+	}
 	a['"'] = true
+	a['\\'] = true
+	return
+}()
+
+// htmlEscapes is escapes with the HTML sensitive bytes, it is used by Logger.EscapeHTML.
+var htmlEscapes = func() (a [256]bool) {
+	a = escapes
 	a['<'] = true
+	a['>'] = true
+	a['&'] = true
 	a['\''] = true
-	a['\\'] = true
-	a['\b'] = true
-	a['\f'] = true
-	a['\n'] = true
-	a['\r'] = true
-	a['\t'] = true
-	a[0] = true
 	return
 }()
 
@@ -830,64 +2108,73 @@ func (e *Event) escape(b []byte) {
 		_ = b[n-1]
 	}
 	for i := 0; i < n; i++ {
-		switch b[i] {
-		case '"':
-			e.buf = append(e.buf, b[j:i]...)
-			e.buf = append(e.buf, '\\', '"')
-			j = i + 1
-		case '\\':
-			e.buf = append(e.buf, b[j:i]...)
-			e.buf = append(e.buf, '\\', '\\')
-			j = i + 1
+		c := b[i]
+		if c >= utf8.RuneSelf {
+			// replace the invalid UTF-8 bytes by U+FFFD one by one.
+			r, size := utf8.DecodeRune(b[i:])
+			if r == utf8.RuneError && size == 1 {
+				e.buf = append(e.buf, b[j:i]...)
+				e.buf = append(e.buf, '\\', 'u', 'f', 'f', 'f', 'd')
+				j = i + 1
+			}
+			i += size - 1
+			continue
+		}
+		if !e.escapes[c] {
+			continue
+		}
+		e.buf = append(e.buf, b[j:i]...)
+		switch c {
+		case '"', '\\':
+			e.buf = append(e.buf, '\\', c)
 		case '\n':
-			e.buf = append(e.buf, b[j:i]...)
 			e.buf = append(e.buf, '\\', 'n')
-			j = i + 1
 		case '\r':
-			e.buf = append(e.buf, b[j:i]...)
 			e.buf = append(e.buf, '\\', 'r')
-			j = i + 1
 		case '\t':
-			e.buf = append(e.buf, b[j:i]...)
 			e.buf = append(e.buf, '\\', 't')
-			j = i + 1
-		case '\f':
-			e.buf = append(e.buf, b[j:i]...)
-			e.buf = append(e.buf, '\\', 'u', '0', '0', '0', 'c')
-			j = i + 1
-		case '\b':
-			e.buf = append(e.buf, b[j:i]...)
-			e.buf = append(e.buf, '\\', 'u', '0', '0', '0', '8')
-			j = i + 1
-		case '<':
-			e.buf = append(e.buf, b[j:i]...)
-			e.buf = append(e.buf, '\\', 'u', '0', '0', '3', 'c')
-			j = i + 1
-		case '\'':
-			e.buf = append(e.buf, b[j:i]...)
-			e.buf = append(e.buf, '\\', 'u', '0', '0', '2', '7')
-			j = i + 1
-		case 0:
-			e.buf = append(e.buf, b[j:i]...)
-			e.buf = append(e.buf, '\\', 'u', '0', '0', '0', '0')
-			j = i + 1
+		default:
+			e.buf = append(e.buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0x0f])
 		}
+		j = i + 1
 	}
 	e.buf = append(e.buf, b[j:]...)
 	e.buf = append(e.buf, '"')
 }
 
-func (e *Event) string(s string) {
-	for _, c := range []byte(s) {
-		if escapes[c] {
-			sh := (*reflect.StringHeader)(unsafe.Pointer(&s))
-			b := *(*[]byte)(unsafe.Pointer(&reflect.SliceHeader{
-				Data: sh.Data, Len: sh.Len, Cap: sh.Len,
-			}))
-			e.escape(b)
-			return
+type sliceHeader struct {
+	s   string
+	cap int
+}
+
+// plain reports whether s can be appended verbatim as a string, i.e. it is valid
+// UTF-8 without any byte to escape.
+func (e *Event) plain(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if e.escapes[c] {
+			return false
+		}
+		if c >= utf8.RuneSelf {
+			if !utf8.ValidString(s[i:]) {
+				return false
+			}
+			// the escaped bytes are ASCII, they are never a part of a multi-byte sequence.
+			for i++; i < len(s); i++ {
+				if e.escapes[s[i]] {
+					return false
+				}
+			}
 		}
 	}
+	return true
+}
+
+func (e *Event) string(s string) {
+	if !e.plain(s) {
+		e.escape(*(*[]byte)(unsafe.Pointer(&sliceHeader{s, len(s)})))
+		return
+	}
 
 	e.buf = append(e.buf, '"')
 	e.buf = append(e.buf, s...)
@@ -897,11 +2184,9 @@ func (e *Event) string(s string) {
 }
 
 func (e *Event) bytes(b []byte) {
-	for _, c := range b {
-		if escapes[c] {
-			e.escape(b)
-			return
-		}
+	if !e.plain(*(*string)(unsafe.Pointer(&b))) {
+		e.escape(b)
+		return
 	}
 
 	e.buf = append(e.buf, '"')
@@ -932,33 +2217,218 @@ var bbpool = sync.Pool{
 
 const bbcap = 1 << 16
 
+// Fields adds the keys and values of fields to the event in sorted order of keys.
+// The values of string, bool, int, float, time.Duration and error are encoded
+// directly, others are marshaled by Interface. A nil value is added as null.
+func (e *Event) Fields(fields map[string]interface{}) *Event {
+	if e == nil || len(fields) == 0 {
+		return e
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		e.key(key)
+		switch v := fields[key].(type) {
+		case nil:
+			e.buf = append(e.buf, "null"...)
+		case string:
+			e.string(v)
+		case []byte:
+			e.bytes(v)
+		case bool:
+			e.buf = strconv.AppendBool(e.buf, v)
+		case int:
+			e.buf = strconv.AppendInt(e.buf, int64(v), 10)
+		case int8:
+			e.buf = strconv.AppendInt(e.buf, int64(v), 10)
+		case int16:
+			e.buf = strconv.AppendInt(e.buf, int64(v), 10)
+		case int32:
+			e.buf = strconv.AppendInt(e.buf, int64(v), 10)
+		case int64:
+			e.buf = strconv.AppendInt(e.buf, v, 10)
+		case uint:
+			e.buf = strconv.AppendUint(e.buf, uint64(v), 10)
+		case uint8:
+			e.buf = strconv.AppendUint(e.buf, uint64(v), 10)
+		case uint16:
+			e.buf = strconv.AppendUint(e.buf, uint64(v), 10)
+		case uint32:
+			e.buf = strconv.AppendUint(e.buf, uint64(v), 10)
+		case uint64:
+			e.buf = strconv.AppendUint(e.buf, v, 10)
+		case float32:
+			e.float(float64(v), 32)
+		case float64:
+			e.float(v, 64)
+		case time.Duration:
+			e.buf = append(e.buf, '"')
+			e.buf = append(e.buf, v.String()...)
+			e.buf = append(e.buf, '"')
+		case error:
+			e.string(v.Error())
+		default:
+			e.iface(v)
+		}
+	}
+	return e
+}
+
 // Interface adds the field key with i marshaled using reflection.
 func (e *Event) Interface(key string, i interface{}) *Event {
 	if e == nil {
 		return nil
 	}
 	e.key(key)
+	e.iface(i)
+	return e
+}
 
+func (e *Event) iface(i interface{}) {
 	b := bbpool.Get().(*bb)
 	b.Reset()
 
+	defer func() {
+		if r := recover(); r != nil {
+			e.string(fmt.Sprintf("marshaling error: panic: %v", r))
+		}
+		if cap(b.B) <= bbcap {
+			bbpool.Put(b)
+		}
+	}()
+
 	enc := json.NewEncoder(b)
-	enc.SetEscapeHTML(false)
+	enc.SetEscapeHTML(e.escapes == &htmlEscapes)
 
 	err := enc.Encode(i)
-	if err != nil {
+	if n := len(b.B); n > 0 && b.B[n-1] == '\n' {
+		b.B = b.B[:n-1]
+	}
+	switch {
+	case err != nil:
 		e.string("marshaling error: " + err.Error())
-	} else {
+	case e.maxiface > 0 && len(b.B) > e.maxiface:
+		n := e.maxiface
+		for n > 0 && !utf8.RuneStart(b.B[n]) {
+			n--
+		}
+		size := len(b.B)
+		b.B = append(b.B[:n], "... "...)
+		b.B = strconv.AppendInt(b.B, int64(size-n), 10)
+		b.B = append(b.B, " bytes truncated"...)
 		e.bytes(b.B)
+	default:
+		e.buf = append(e.buf, b.B...)
 	}
+}
 
-	if cap(b.B) <= bbcap {
-		bbpool.Put(b)
+// ObjectMarshaler provides a strongly-typed and encoding-agnostic interface
+// to be implemented by types used with Event's Object, EmbedObject and Objects method.
+// MarshalObject adds the fields of the type to e by the typed field methods.
+type ObjectMarshaler interface {
+	MarshalObject(e *Event)
+}
+
+// Object adds the field key with o as an object marshaled by MarshalObject to the event,
+// a nil o is added as null.
+func (e *Event) Object(key string, o ObjectMarshaler) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	e.object(o)
+	return e
+}
+
+// EmbedObject adds the fields of o marshaled by MarshalObject to the event at the top level.
+func (e *Event) EmbedObject(o ObjectMarshaler) *Event {
+	if e == nil || o == nil {
+		return e
+	}
+	o.MarshalObject(e)
+	return e
+}
+
+// Objects adds the field key with items as an array of objects marshaled by MarshalObject to the event.
+func (e *Event) Objects(key string, items []ObjectMarshaler) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	e.buf = append(e.buf, '[')
+	for i, o := range items {
+		if i != 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.object(o)
+	}
+	e.buf = append(e.buf, ']')
+	return e
+}
+
+func (e *Event) object(o ObjectMarshaler) {
+	if o == nil {
+		e.buf = append(e.buf, "null"...)
+		return
+	}
+	n := len(e.buf)
+	o.MarshalObject(e)
+	if len(e.buf) > n {
+		// the first field starts with a comma, turn it into the opening brace.
+		e.buf[n] = '{'
+	} else {
+		e.buf = append(e.buf, '{')
 	}
+	e.buf = append(e.buf, '}')
+}
 
+// Dict starts a sub-object to be added to an event by Event.Dict, e.g.
+//
+//	log.Info().Dict("http", log.Dict().Str("method", "GET").Int("status", 200)).Msg("")
+//
+// The returned event only collects fields, it must not be sent by Msg.
+func Dict() (e *Event) {
+	e = epool.Get().(*Event)
+	e.buf = e.buf[:0]
+	e.escapes = &escapes
+	e.ecs = false
+	e.names = nil
+	e.rawjson = 0
+	e.maxiface = 0
+	return
+}
+
+// Dict adds the field key with the fields of dict as a JSON object to the event,
+// dict is started by Dict and is released after this call.
+func (e *Event) Dict(key string, dict *Event) *Event {
+	if e == nil {
+		if dict != nil {
+			eventPut(dict)
+		}
+		return nil
+	}
+	e.key(key)
+	e.dict(dict)
 	return e
 }
 
+func (e *Event) dict(dict *Event) {
+	if dict == nil {
+		e.buf = append(e.buf, "null"...)
+		return
+	}
+	e.buf = append(e.buf, '{')
+	if len(dict.buf) > 0 {
+		// skip the leading comma of the first field.
+		e.buf = append(e.buf, dict.buf[1:]...)
+	}
+	e.buf = append(e.buf, '}')
+	eventPut(dict)
+}
+
 // print sends the event with msgs added as the message field if not empty.
 func (e *Event) print(v ...interface{}) {
 	if e == nil {
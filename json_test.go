@@ -1,13 +1,49 @@
 package log
 
 import (
+	"bytes"
+	"encoding/base64"
+	stdhex "encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
+// TestMain validates the JSON output of all loggers in tests, an invalid line panics.
+func TestMain(m *testing.M) {
+	validateJSON = true
+	ErrorHandler = panicOnInvalidJSON
+	os.Exit(m.Run())
+}
+
+// allocsPerRun is testing.AllocsPerRun without the validation of TestMain, which
+// allocates under the race detector.
+func allocsPerRun(runs int, f func()) float64 {
+	defer func(v bool) { validateJSON = v }(validateJSON)
+	validateJSON = false
+	return testing.AllocsPerRun(runs, f)
+}
+
+func panicOnInvalidJSON(err error) {
+	if strings.HasPrefix(err.Error(), "log: invalid JSON output") {
+		panic(err)
+	}
+}
+
 func TestDefaultLogger(t *testing.T) {
 	osExit = func(int) {}
 
@@ -27,10 +63,11 @@ func TestLogger(t *testing.T) {
 	}
 
 	logger := Logger{
-		Level: ParseLevel("debug"),
+		Level: DebugLevel,
 	}
 	logger.Info().
 		Caller().
+		Callers(2).
 		Bool("bool", true).
 		Bools("bools", []bool{false}).
 		Bools("bools", []bool{true, false}).
@@ -54,6 +91,7 @@ func TestLogger(t *testing.T) {
 		Int("int", 123).
 		RawJSON("raw_json", []byte("{\"a\":1,\"b\":2}")).
 		Hex("hex", []byte("\"<>?'")).
+		Hexdump("hexdump", []byte("\"<>?'"), 0).
 		Bytes("bytes1", []byte("bytes1")).
 		Bytes("bytes2", []byte("\"<>?'")).
 		Str("foobar", "\"\\\t\r\n\f\b\x00<>?'").
@@ -69,6 +107,7 @@ func TestLogger(t *testing.T) {
 		Errs("errors", []error{errors.New("error1"), nil, errors.New("error3")}).
 		Interface("console_writer", ConsoleWriter{ANSIColor: true}).
 		Interface("time.Time", timeNow()).
+		Objects("objects", []ObjectMarshaler{&testBackend{"a", 1}, nil}).
 		Msgf("this is a \"%s\"", "test")
 }
 
@@ -79,10 +118,11 @@ func TestLoggerNil(t *testing.T) {
 	}
 
 	logger := Logger{
-		Level: ParseLevel("info"),
+		Level: InfoLevel,
 	}
 	logger.Debug().
 		Caller().
+		Callers(2).
 		Bool("bool", true).
 		Bools("bools", []bool{true, false}).
 		Dur("1_hour", time.Hour).
@@ -105,6 +145,7 @@ func TestLoggerNil(t *testing.T) {
 		Int("int", 123).
 		RawJSON("raw_json", []byte("{\"a\":1,\"b\":2}")).
 		Hex("hex", []byte("\"<>?'")).
+		Hexdump("hexdump", []byte("\"<>?'"), 0).
 		Bytes("bytes1", []byte("bytes1")).
 		Bytes("bytes2", []byte("\"<>?'")).
 		Str("foobar", "\"\\\t\r\n\f\b\x00<>?'").
@@ -120,6 +161,7 @@ func TestLoggerNil(t *testing.T) {
 		Errs("errors", []error{errors.New("error1"), nil, errors.New("error3")}).
 		Interface("console_writer", ConsoleWriter{ANSIColor: true}).
 		Interface("time.Time", timeNow()).
+		Objects("objects", []ObjectMarshaler{&testBackend{"a", 1}, nil}).
 		Msgf("this is a \"%s\"", "test")
 }
 
@@ -133,6 +175,41 @@ func TestLoggerSetLevel(t *testing.T) {
 	Debug().Msg("5. i am a debug log")
 }
 
+func TestLoggerSetLevelRace(t *testing.T) {
+	logger := Logger{Level: InfoLevel, Writer: ioutil.Discard}
+
+	defer func(w io.Writer, level Level) {
+		DefaultLogger.Writer = w
+		DefaultLogger.SetLevel(level)
+	}(DefaultLogger.Writer, DefaultLogger.level())
+	DefaultLogger.Writer = ioutil.Discard
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.SetLevel(Level(j%5*10) + TraceLevel)
+				DefaultLogger.SetLevel(Level(j%5*10) + TraceLevel)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Print("print")
+				logger.Printf("printf %d", j)
+				logger.Info().Msg("info")
+				logger.WithLevel(WarnLevel).Msg("with level")
+				_ = logger.Config()
+				Print("print")
+				Printf("printf %d", j)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func TestLoggerStack(t *testing.T) {
 	Info().Stack().Msg("this is test stack log event")
 }
@@ -151,6 +228,126 @@ func TestLoggerDiscard(t *testing.T) {
 	Debug().Stack().Str("foo", "bar").Discard()
 }
 
+func TestLoggerSend(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+
+	logger.Info().Str("foo", "bar").Send()
+	if s := buf.String(); !strings.HasSuffix(s, `"level":"info","foo":"bar"}`+"\n") {
+		t.Errorf("send output %s has message or wrong fields", s)
+	}
+
+	buf.Reset()
+	e := logger.Info().Str("foo", "bar")
+	e.Msg("first")
+	e.Msg("second")
+	e.Send()
+	if n := strings.Count(buf.String(), "\n"); n != 1 || strings.Contains(buf.String(), "second") {
+		t.Errorf("msg twice should be a no-op, got %d lines: %s", n, buf.String())
+	}
+
+	buf.Reset()
+	e = logger.Info().Str("foo", "bar")
+	e.Discard()
+	e.Send()
+	if buf.Len() != 0 {
+		t.Errorf("send after discard should be a no-op, got %s", buf.String())
+	}
+
+	var nilEvent *Event
+	nilEvent.Send()
+}
+
+type errorWriter struct {
+	n   int
+	err error
+}
+
+func (w *errorWriter) Write(p []byte) (int, error) {
+	if w.n >= 0 && w.n < len(p) {
+		return w.n, w.err
+	}
+	return len(p), w.err
+}
+
+func TestLoggerErrorHandler(t *testing.T) {
+	var errs []error
+	logger := Logger{Writer: &errorWriter{n: -1, err: errors.New("disk full")}}
+	logger.ErrorHandler = func(err error) {
+		errs = append(errs, err)
+		// the handler must not recurse when it logs by the failing logger.
+		logger.Error().Err(err).Msg("write error")
+	}
+
+	logger.Info().Str("foo", "bar").Msg("hello")
+	if len(errs) != 1 || errs[0].Error() != "disk full" {
+		t.Errorf("error handler got %v, want [disk full]", errs)
+	}
+
+	errs = nil
+	logger.Writer = &errorWriter{n: 5}
+	logger.Info().Send()
+	if len(errs) != 1 || errs[0] != io.ErrShortWrite {
+		t.Errorf("error handler got %v, want [%v]", errs, io.ErrShortWrite)
+	}
+
+	errs = nil
+	if err := logger.Info().MsgErr("inline"); err != io.ErrShortWrite {
+		t.Errorf("msg err got %v, want %v", err, io.ErrShortWrite)
+	}
+	if len(errs) != 0 {
+		t.Errorf("msg err should not call error handler, got %v", errs)
+	}
+
+	logger.Writer = ioutil.Discard
+	if err := logger.Info().MsgErr("ok"); err != nil {
+		t.Errorf("msg err got %v, want nil", err)
+	}
+	if err := logger.Debug().MsgErr("nil"); err != nil {
+		t.Errorf("nil event msg err got %v, want nil", err)
+	}
+
+	defer func(h func(error)) { ErrorHandler = h }(ErrorHandler)
+	ErrorHandler = func(err error) { errs = append(errs, err) }
+	logger = Logger{Writer: &errorWriter{n: -1, err: errors.New("broken pipe")}}
+	logger.Info().Msg("hello")
+	if len(errs) != 1 || errs[0].Error() != "broken pipe" {
+		t.Errorf("package error handler got %v, want [broken pipe]", errs)
+	}
+}
+
+func TestLoggerErrorHandlerConcurrent(t *testing.T) {
+	var count int32
+	release := make(chan struct{})
+	logger := Logger{Writer: &errorWriter{n: -1, err: errors.New("disk full")}}
+	logger.ErrorHandler = func(err error) {
+		// the errors of other goroutines must not be dropped while a handler is running.
+		if atomic.AddInt32(&count, 1) == 1 {
+			<-release
+		}
+	}
+
+	go logger.Info().Msg("first")
+	for atomic.LoadInt32(&count) == 0 {
+		runtime.Gosched()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.Info().Msg("hello")
+		}()
+	}
+	wg.Wait()
+	close(release)
+
+	if n := atomic.LoadInt32(&count); n != 9 {
+		t.Errorf("error handler got %d errors, want 9", n)
+	}
+}
+
 func TestLoggerWithLevel(t *testing.T) {
 	DefaultLogger.WithLevel(InfoLevel).Msg("this is with level log event")
 	DefaultLogger.Caller = 1
@@ -171,7 +368,7 @@ func TestLoggerCaller(t *testing.T) {
 	Printf("hello from %s", "Printf")
 
 	logger := Logger{
-		Level:  ParseLevel("debug"),
+		Level:  DebugLevel,
 		Caller: 1,
 	}
 	logger.Debug().Str("foo", "bar").Msg("hello from Debug")
@@ -183,9 +380,747 @@ func TestLoggerCaller(t *testing.T) {
 	logger.Printf("hello from %s", "Printf")
 }
 
+func TestLoggerCallerPath(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	dir := filepath.Base(filepath.Dir(file))
+
+	cases := []struct {
+		Logger Logger
+		Caller string
+	}{
+		{Logger{Caller: 1}, "json_test.go:"},
+		{Logger{Caller: 1, CallerPackagePath: true}, dir + "/json_test.go:"},
+		{Logger{Caller: 1, CallerFullPath: true}, file + ":"},
+		{Logger{Caller: 1, CallerFullPath: true, CallerPackagePath: true}, file + ":"},
+		{Logger{Caller: 1, CallerWithFunc: true}, "json_test.go:"},
+		{Logger{Caller: 1, CallerPackagePath: true, CallerWithFunc: true}, dir + "/json_test.go:"},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		c.Logger.Writer = &buf
+		c.Logger.Info().Msg("hello caller")
+		c.Logger.Info().Caller().Msg("hello event caller")
+
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var m struct {
+				Caller string
+			}
+			if err := json.Unmarshal([]byte(line), &m); err != nil {
+				t.Fatalf("unmarshal %s error: %+v", line, err)
+			}
+			if !strings.HasPrefix(m.Caller, c.Caller) {
+				t.Errorf("caller of %+v got %s, want prefix %s", c.Logger.Config(), m.Caller, c.Caller)
+			}
+			if fn := " log.TestLoggerCallerPath"; c.Logger.CallerWithFunc != strings.HasSuffix(m.Caller, fn) {
+				t.Errorf("caller of %+v got %s, want suffix %s: %v", c.Logger.Config(), m.Caller, fn, c.Logger.CallerWithFunc)
+			}
+		}
+	}
+
+	if got, want := trimCallerFile("/a/b/c.go", true), "b/c.go"; got != want {
+		t.Errorf("trimCallerFile got %s, want %s", got, want)
+	}
+	if got, want := trimCallerFile("c.go", true), "c.go"; got != want {
+		t.Errorf("trimCallerFile got %s, want %s", got, want)
+	}
+}
+
+// wrapInfo and wrapInfo2 wrap the logger in two levels like a helper package.
+func wrapInfo(logger *Logger, msg string) {
+	wrapInfo2(logger, msg)
+}
+
+func wrapInfo2(logger *Logger, msg string) {
+	logger.Info().Msg(msg)
+}
+
+func wrapEventCaller(logger *Logger, msg string) {
+	logger.Info().Caller(1).Msg(msg)
+}
+
+func TestLoggerCallerSkipFrames(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{
+		Caller:           1,
+		CallerSkipFrames: 2,
+		Writer:           &buf,
+	}
+
+	_, _, line, _ := runtime.Caller(0)
+	wrapInfo(&logger, "hello wrapper")
+	logger.Caller, logger.CallerSkipFrames = 0, 0
+	wrapEventCaller(&logger, "hello event wrapper")
+	logger.Info().Caller().Msg("hello event caller")
+
+	want := []string{
+		"json_test.go:" + strconv.Itoa(line+1),
+		"json_test.go:" + strconv.Itoa(line+3),
+		"json_test.go:" + strconv.Itoa(line+4),
+	}
+	for i, s := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m struct {
+			Caller string
+		}
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			t.Fatalf("unmarshal %s error: %+v", s, err)
+		}
+		if i >= len(want) || m.Caller != want[i] {
+			t.Errorf("caller skip frames got %s, want %v", m.Caller, want)
+		}
+	}
+}
+
+func TestLoggerCallers(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{
+		Writer: &buf,
+	}
+
+	for _, depth := range []int{0, 1, 2, 100} {
+		buf.Reset()
+		logger.Info().Callers(depth).Msg("")
+
+		var entry struct {
+			Callers []string `json:"callers"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("unmarshal callers output %s error: %+v", buf.Bytes(), err)
+		}
+		if depth <= 2 && len(entry.Callers) != depth {
+			t.Errorf("callers depth %d got %d frames: %s", depth, len(entry.Callers), buf.Bytes())
+		}
+		if len(entry.Callers) > 32 {
+			t.Errorf("callers depth should be capped at 32, got %d", len(entry.Callers))
+		}
+		if depth > 0 && !strings.HasPrefix(entry.Callers[0], "json_test.go:") {
+			t.Errorf("the first caller should be json_test.go, got %s", buf.Bytes())
+		}
+	}
+
+	_, file, _, _ := runtime.Caller(0)
+	for _, c := range []struct {
+		FullPath    bool
+		PackagePath bool
+		Prefix      string
+	}{
+		{false, true, filepath.Base(filepath.Dir(file)) + "/json_test.go:"},
+		{true, false, file + ":"},
+		{true, true, file + ":"},
+	} {
+		buf.Reset()
+		logger.CallerFullPath, logger.CallerPackagePath = c.FullPath, c.PackagePath
+		logger.Info().Callers(1).Msg("")
+		if s := buf.String(); !strings.Contains(s, `"callers":["`+c.Prefix) {
+			t.Errorf("callers with CallerFullPath=%v CallerPackagePath=%v output %s, want prefix %s", c.FullPath, c.PackagePath, s, c.Prefix)
+		}
+	}
+
+	buf.Reset()
+	logger.CallerFullPath, logger.CallerPackagePath = false, false
+	logger.Schema = ECS
+	logger.Info().Callers(1).Msg("")
+	if s := buf.String(); !strings.Contains(s, `,"log.origin.callers":["json_test.go:`) {
+		t.Errorf("callers with ECS output %s", s)
+	}
+}
+
+type testPoint struct {
+	X, Y int
+}
+
+// testPointField is a field helper of a custom type built on the extension API.
+func testPointField(e *Event, key string, p testPoint) *Event {
+	return e.AppendKey(key).
+		AppendRaw([]byte(`{"x":` + strconv.Itoa(p.X) + `,"y":` + strconv.Itoa(p.Y) + `}`))
+}
+
+func TestEventAppend(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{
+		Writer: &buf,
+	}
+
+	e := logger.Info().AppendKey(`quote"key`).AppendStringValue("a\tb\"c")
+	e = testPointField(e, "point", testPoint{1, -2})
+	e.Strs("strs", []string{"x", "y\n"}).Msg("hello")
+
+	if s := buf.String(); !strings.Contains(s, `"quote\"key":"a\tb\"c","point":{"x":1,"y":-2},"strs":["x","y\n"],"message":"hello"}`) {
+		t.Errorf("unexpected append output %s", s)
+	}
+
+	logger.Debug().AppendKey("nil").AppendRaw([]byte("null")).AppendKey("empty").AppendStringValue("").Msg("nil")
+	var nilEvent *Event
+	if testPointField(nilEvent, "point", testPoint{}) != nil {
+		t.Errorf("append to nil event should return nil")
+	}
+}
+
+func TestEventSample(t *testing.T) {
+	var w levelWriter
+	logger := Logger{
+		Writer: &w,
+	}
+
+	const total = 100000
+	for i := 0; i < total; i++ {
+		logger.Info().Sample(10).Int("i", i).Msg("sample")
+		logger.Warn().SampleRate(0.25).Int("i", i).Msg("sample rate")
+	}
+
+	var info, warn int
+	for _, level := range w.levels {
+		switch level {
+		case InfoLevel:
+			info++
+		case WarnLevel:
+			warn++
+		}
+	}
+	if rate := float64(info) / total; rate < 0.09 || rate > 0.11 {
+		t.Errorf("Sample(10) pass rate got %v, want 0.1", rate)
+	}
+	if rate := float64(warn) / total; rate < 0.24 || rate > 0.26 {
+		t.Errorf("SampleRate(0.25) pass rate got %v, want 0.25", rate)
+	}
+
+	osExit = func(int) {}
+	defer func() { osExit = os.Exit }()
+	w.lines = w.lines[:0]
+	for i := 0; i < 10; i++ {
+		logger.Fatal().Sample(100).SampleRate(0).Msg("fatal is not sampled")
+	}
+	if n := strings.Count(strings.Join(w.lines, ""), "fatal is not sampled"); n != 10 {
+		t.Errorf("fatal events should not be sampled, got %d", n)
+	}
+
+	if e := logger.Info().SampleRate(0); e != nil {
+		t.Errorf("SampleRate(0) should discard the event")
+	}
+
+	// the sampled events are recycled, so they allocate no more than the sent events.
+	logger.Writer = ioutil.Discard
+	base := allocsPerRun(1000, func() {
+		logger.Info().Str("foo", "bar").Msg("sample")
+	})
+	if n := allocsPerRun(1000, func() {
+		logger.Info().Sample(2).Str("foo", "bar").Msg("sample")
+	}); n > base {
+		t.Errorf("sampled events should be recycled, got %v allocs, want %v", n, base)
+	}
+}
+
+func TestLoggerPanic(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{
+		Writer: &buf,
+	}
+
+	for _, c := range []struct {
+		Event func() *Event
+		Msgf  bool
+	}{
+		{logger.Panic, false},
+		{logger.Panic, true},
+		{Panic, false},
+	} {
+		buf.Reset()
+		DefaultLogger.Writer = &buf
+		func() {
+			defer func() {
+				if r := recover(); r != "hello panic 42" {
+					t.Errorf("panic event should panic with the message, got %#v", r)
+				}
+			}()
+			if c.Msgf {
+				c.Event().Str("foo", "bar").Msgf("hello panic %d", 42)
+			} else {
+				c.Event().Str("foo", "bar").Msg("hello panic 42")
+			}
+		}()
+		if s := buf.String(); !strings.Contains(s, `"level":"panic",`) || !strings.Contains(s, `"foo":"bar","message":"hello panic 42"}`) {
+			t.Errorf("panic event should be written before panicking, got %s", buf.String())
+		}
+	}
+	DefaultLogger.Writer = os.Stderr
+
+	logger.Info().Msg("the recycled event should not panic")
+}
+
+func TestLoggerErr(t *testing.T) {
+	var buf bytes.Buffer
+
+	DefaultLogger.Writer = &buf
+	DefaultLogger.Caller = 1
+	DefaultLogger.SetLevel(DebugLevel)
+	defer func() {
+		DefaultLogger.Writer = os.Stderr
+		DefaultLogger.Caller = 0
+	}()
+
+	Err(errors.New("a package error")).Msg("package err")
+	Err(nil).Msg("package nil err")
+
+	logger := Logger{
+		Level:  InfoLevel,
+		Caller: 1,
+		Writer: &buf,
+	}
+	logger.Err(errors.New("a logger error")).Msg("logger err")
+	logger.Err(nil).Msg("logger nil err")
+
+	logger.SetLevel(ErrorLevel)
+	logger.Err(nil).Msg("logger nil err should be filtered")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 4 {
+		t.Fatalf("err output got %d lines, want 4: %s", len(lines), buf.Bytes())
+	}
+	for i, want := range []string{
+		`"level":"error","caller":"json_test.go:`,
+		`"level":"info","caller":"json_test.go:`,
+		`"level":"error","caller":"json_test.go:`,
+		`"level":"info","caller":"json_test.go:`,
+	} {
+		if !bytes.Contains(lines[i], []byte(want)) {
+			t.Errorf("err output %s does not contain %s", lines[i], want)
+		}
+	}
+	for i, want := range []string{`"error":"a package error"`, `"error":null`, `"error":"a logger error"`, `"error":null`} {
+		if !bytes.Contains(lines[i], []byte(want)) {
+			t.Errorf("err output %s does not contain %s", lines[i], want)
+		}
+	}
+}
+
+func TestLoggerAnErr(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf, ErrorField: "err"}
+
+	logger.Error().Err(errors.New("operation failed")).AnErr("rollback_error", errors.New("rollback failed")).AnErr("close_error", nil).Msg("")
+	if s := buf.String(); !strings.HasSuffix(s, `"level":"error","err":"operation failed","rollback_error":"rollback failed","close_error":null}`+"\n") {
+		t.Errorf("anerr output %s", s)
+	}
+}
+
+type codeError struct {
+	Code int
+}
+
+func (e codeError) Error() string { return "code " + strconv.Itoa(e.Code) }
+
+func (e codeError) MarshalJSON() ([]byte, error) {
+	if e.Code < 0 {
+		return nil, errors.New("bad code")
+	}
+	return []byte(`{ "code": ` + strconv.Itoa(e.Code) + ` }`), nil
+}
+
+type objectError struct{}
+
+func (objectError) Error() string { return "object error" }
+
+func (objectError) MarshalObject(e *Event) { e.Str("reason", "object") }
+
+func TestLoggerErrMarshaler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf, ErrorTypeField: "errorType"}
+
+	cases := []struct {
+		Err  error
+		JSON string
+	}{
+		{codeError{42}, `"error":{"code":42},"errorType":"log.codeError"}`},
+		{codeError{-1}, `"error":"code -1","errorType":"log.codeError"}`},
+		{objectError{}, `"error":{"reason":"object"},"errorType":"log.objectError"}`},
+		{errors.New("plain"), `"error":"plain","errorType":"*errors.errorString"}`},
+		{nil, `"error":null}`},
+	}
+
+	for _, c := range cases {
+		buf.Reset()
+		logger.Info().Err(c.Err).Msg("")
+		if s := buf.String(); !strings.HasSuffix(s, c.JSON+"\n") {
+			t.Errorf("err marshaler output %s, want %s", s, c.JSON)
+		}
+	}
+
+	buf.Reset()
+	logger.Info().AnErr("cause", codeError{7}).Msg("")
+	if s := buf.String(); !strings.HasSuffix(s, `"cause":{"code":7}}`+"\n") {
+		t.Errorf("anerr marshaler output %s", s)
+	}
+}
+
+type stackError struct {
+	msg string
+	pcs []stackFrame
+}
+
+type stackFrame uintptr
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) StackTrace() []stackFrame { return e.pcs }
+
+type wrapError struct {
+	msg string
+	err error
+}
+
+func (e *wrapError) Error() string { return e.msg + ": " + e.err.Error() }
+
+func (e *wrapError) Unwrap() error { return e.err }
+
+func TestLoggerErrStack(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+
+	var pcs [8]uintptr
+	cause := &stackError{msg: "cause"}
+	for _, pc := range pcs[:runtime.Callers(1, pcs[:])] {
+		cause.pcs = append(cause.pcs, stackFrame(pc))
+	}
+	err := &wrapError{"outer", &wrapError{"inner", cause}}
+
+	logger.Error().Stack().Err(err).Msg("")
+	s := buf.String()
+	if !strings.Contains(s, `"error":"outer: inner: cause","errorChain":["outer: inner: cause","inner: cause","cause"],"stack":[{"file":"`) {
+		t.Errorf("err stack output %s", s)
+	}
+	if !strings.Contains(s, `"func":"github.com/phuslu/log.TestLoggerErrStack"}`) {
+		t.Errorf("err stack output %s does not contain the test func", s)
+	}
+	if strings.Contains(s, "goroutine ") {
+		t.Errorf("err stack output %s should not contain the goroutine stacks", s)
+	}
+
+	buf.Reset()
+	logger.Error().Err(err).Msg("")
+	if s := buf.String(); !strings.HasSuffix(s, `"level":"error","error":"outer: inner: cause"}`+"\n") {
+		t.Errorf("err output %s should not contain the chain without Stack", s)
+	}
+
+	buf.Reset()
+	logger.Error().Err(errors.New("plain")).Msg("")
+	if s := buf.String(); !strings.HasSuffix(s, `"level":"error","error":"plain"}`+"\n") {
+		t.Errorf("plain err output %s", s)
+	}
+}
+
+func TestLoggerFieldNames(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{
+		Writer:       &buf,
+		Caller:       1,
+		TimeField:    "ts",
+		LevelField:   "severity",
+		MessageField: "msg",
+		CallerField:  "src",
+		ErrorField:   "err\"",
+	}
+
+	logger.Info().Err(errors.New("oops")).Msg("hello field names")
+	line := buf.String()
+	if !strings.HasPrefix(line, `{"ts":"`) {
+		t.Errorf("field names output %s does not start with ts", line)
+	}
+	for _, want := range []string{`,"severity":"info",`, `,"src":"json_test.go:`, `,"err\"":"oops",`, `,"msg":"hello field names"}`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("field names output %s does not contain %s", line, want)
+		}
+	}
+	if !json.Valid([]byte(line)) {
+		t.Errorf("field names output %s is invalid JSON", line)
+	}
+
+	buf.Reset()
+	logger.Timestamp = true
+	logger.With().Err(nil).Logger().Warn().Send()
+	if s := buf.String(); !strings.HasPrefix(s, `{"time":1`) || !strings.Contains(s, `,"severity":"warn","err\"":null,"src":"json_test.go:`) {
+		t.Errorf("field names timestamp output %s is unexpected", s)
+	}
+
+	// the names are rebuilt if they are changed.
+	buf.Reset()
+	logger.Timestamp = false
+	logger.MessageField = "text"
+	logger.Info().Msg("changed")
+	if s := buf.String(); !strings.HasSuffix(s, `,"text":"changed"}`+"\n") {
+		t.Errorf("field names changed output %s is unexpected", s)
+	}
+
+	logger.Writer = ioutil.Discard
+	logger.Caller = 0
+	plain := Logger{Writer: ioutil.Discard}
+	base := allocsPerRun(100, func() {
+		plain.Info().Str("foo", "bar").Msg("hello")
+	})
+	if n := allocsPerRun(100, func() {
+		logger.Info().Str("foo", "bar").Msg("hello")
+	}); n > base {
+		t.Errorf("field names allocate %v times per event, want %v", n, base)
+	}
+
+	if s := logger.String(); !strings.Contains(s, ` LevelField:"severity" MessageField:"text" CallerField:"src" ErrorField:"err\""`) {
+		t.Errorf("field names string %s", s)
+	}
+}
+
+func TestLoggerECS(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{
+		Level:     InfoLevel,
+		Caller:    1,
+		HostField: "host",
+		Writer:    &buf,
+		Schema:    ECS,
+	}
+
+	logger.Error().Err(errors.New("oops")).Str("foo", "bar").Msg("hello ecs")
+
+	line := buf.String()
+	if !strings.HasPrefix(line, `{"@timestamp":"`) {
+		t.Fatalf("ecs output %s does not start with @timestamp", line)
+	}
+	line = line[strings.Index(line, `Z",`)+3:]
+	want := `"log.level":"error","ecs.version":"1.6.0","host.name":` + strconv.Quote(hostname) + `,"log.origin.file.name":"json_test.go","log.origin.file.line":`
+	if !strings.HasPrefix(line, want) {
+		t.Errorf("ecs output %s does not start with %s", line, want)
+	}
+	if want := `,"error.message":"oops","foo":"bar","message":"hello ecs"}` + "\n"; !strings.HasSuffix(line, want) {
+		t.Errorf("ecs output %s does not end with %s", line, want)
+	}
+
+	buf.Reset()
+	logger = Logger{Writer: &buf, Timestamp: true, TimeField: "ts", Schema: ECS}
+	logger.WithLevel(NoLevel).Err(nil).Msg("")
+	if s := buf.String(); !strings.HasPrefix(s, `{"@timestamp":1`) || !strings.HasSuffix(s, `,"ecs.version":"1.6.0","error.message":null}`+"\n") {
+		t.Errorf("ecs output %s is unexpected", s)
+	}
+}
+
+func TestLoggerClone(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := Logger{
+		Level:         InfoLevel,
+		Timestamp:     true,
+		TimestampMode: TimestampFloat,
+		Caller:        1,
+		TimeField:     "ts",
+
+		CallerSkipFrames:  1,
+		CallerFullPath:    true,
+		CallerPackagePath: true,
+		CallerWithFunc:    true,
+
+		LevelField:     "severity",
+		MessageField:   "msg",
+		CallerField:    "src",
+		ErrorField:     "err",
+		ErrorTypeField: "errortype",
+		TraceIDField:   "traceid",
+		SpanIDField:    "spanid",
+		TimeFormat:     time.RFC3339,
+		HostField:      "host",
+		GoroutineField: "goid",
+		PIDField:       "pid",
+		ProgramField:   "program",
+		Writer:         &buf,
+		Context:        NewContext(nil).Str("foo", "bar").Value(),
+		ValidateJSON:   true,
+		RawJSONMode:    RawJSONCompact,
+
+		MaxInterfaceSize: 1024,
+		EscapeHTML:       true,
+		ErrorHandler:     func(error) {},
+		LevelEncoder:     LevelEncoderGCP,
+		Schema:           ECS,
+		Hooks:            []Hook{&fieldHook{"hook", "clone"}},
+		Sampler:          &BasicSampler{N: 1},
+	}
+
+	clone := logger.Clone()
+	if clone.ErrorHandler == nil {
+		t.Fatalf("clone does not copy ErrorHandler")
+	}
+	// func values are never deeply equal, compare the other fields.
+	handler := logger.ErrorHandler
+	logger.ErrorHandler, clone.ErrorHandler = nil, nil
+	if !reflect.DeepEqual(&logger, clone) {
+		t.Fatalf("clone %+v is not equal to %+v", clone, &logger)
+	}
+	logger.ErrorHandler = handler
+
+	// every exported field of Logger must be copied by Clone.
+	v := reflect.ValueOf(&logger).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).PkgPath != "" {
+			continue
+		}
+		if reflect.DeepEqual(v.Field(i).Interface(), reflect.Zero(v.Field(i).Type()).Interface()) {
+			t.Errorf("logger field %s is zero, please set it in the test", v.Type().Field(i).Name)
+		}
+	}
+
+	clone = logger.Clone(func(l *Logger) { l.Level = DebugLevel })
+	if clone.Level != DebugLevel || clone.Writer != logger.Writer {
+		t.Fatalf("clone %+v does not apply option", clone)
+	}
+
+	clone.SetLevel(ErrorLevel)
+	if logger.Level != InfoLevel {
+		t.Fatalf("clone SetLevel changes parent level to %v", logger.Level)
+	}
+
+	clone.Hooks[0] = &fieldHook{"hook", "changed"}
+	if h := logger.Hooks[0].(*fieldHook); h.value != "clone" {
+		t.Fatalf("clone shares the hooks with parent, got %+v", h)
+	}
+
+	logger.Writer = ioutil.Discard
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Clone().Info().Int("j", j).Msg("clone while logging")
+				logger.SetLevel(InfoLevel)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLoggerOnLevelChange(t *testing.T) {
+	logger := Logger{Level: InfoLevel}
+
+	var calls []string
+	unsubscribe1 := logger.OnLevelChange(func(old, new Level) {
+		calls = append(calls, fmt.Sprintf("1:%d->%d", old, new))
+	})
+	var unsubscribe2 func()
+	unsubscribe2 = logger.OnLevelChange(func(old, new Level) {
+		calls = append(calls, fmt.Sprintf("2:%d->%d", old, new))
+		// unsubscribe itself during the callback.
+		unsubscribe2()
+	})
+	logger.OnLevelChange(func(old, new Level) {
+		calls = append(calls, fmt.Sprintf("3:%d->%d", old, new))
+	})
+
+	logger.SetLevel(DebugLevel)
+	logger.SetLevel(DebugLevel)
+	unsubscribe1()
+	unsubscribe1()
+	logger.SetLevel(ErrorLevel)
+
+	want := []string{
+		fmt.Sprintf("1:%d->%d", InfoLevel, DebugLevel),
+		fmt.Sprintf("2:%d->%d", InfoLevel, DebugLevel),
+		fmt.Sprintf("3:%d->%d", InfoLevel, DebugLevel),
+		fmt.Sprintf("3:%d->%d", DebugLevel, ErrorLevel),
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("OnLevelChange calls got %v, want %v", calls, want)
+	}
+
+	// the callbacks must not race with concurrent SetLevel and OnLevelChange, run with -race.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			unsubscribe := logger.OnLevelChange(func(old, new Level) {})
+			logger.SetLevel(Level(i % 4))
+			unsubscribe()
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestLoggerOnLevelChangeOrder(t *testing.T) {
+	logger := Logger{Level: InfoLevel}
+
+	var mu sync.Mutex
+	var changes [][2]Level
+	logger.OnLevelChange(func(old, new Level) {
+		runtime.Gosched()
+		mu.Lock()
+		changes = append(changes, [2]Level{old, new})
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.SetLevel(Level((i+j)%5*10) + TraceLevel)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// each notification must start from the level of the previous one.
+	last := InfoLevel
+	for i, c := range changes {
+		if c[0] != last {
+			t.Fatalf("OnLevelChange notification %d got %d->%d, want from %d", i, c[0], c[1], last)
+		}
+		last = c[1]
+	}
+	if last != logger.level() {
+		t.Errorf("OnLevelChange last notification to %d, want %d", last, logger.level())
+	}
+}
+
+func TestLoggerSetWriter(t *testing.T) {
+	var buf1, buf2 bytes.Buffer
+	logger := Logger{Writer: &buf1}
+
+	logger.Info().Msg("first")
+	logger.SetWriter(&buf2)
+	logger.Info().Msg("second")
+	if !strings.Contains(buf1.String(), "first") || strings.Contains(buf1.String(), "second") || !strings.Contains(buf2.String(), "second") {
+		t.Fatalf("SetWriter does not swap the writer: %q %q", buf1.String(), buf2.String())
+	}
+
+	if clone := logger.Clone(); clone.Writer != &buf2 {
+		t.Errorf("clone should use the writer set by SetWriter, got %v", clone.Writer)
+	}
+
+	// swap the writers while other goroutines are logging, run with -race.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				logger.Info().Int("j", j).Msg("hello")
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		logger.SetWriter(ioutil.Discard)
+		logger.SetWriter(struct{ io.Writer }{ioutil.Discard})
+	}
+	wg.Wait()
+
+	logger.SetWriter(nil)
+	if e := logger.Info(); e.w != os.Stderr {
+		t.Errorf("SetWriter(nil) should write to os.Stderr, got %v", e.w)
+	} else {
+		e.Discard()
+	}
+}
+
 func TestLoggerTime(t *testing.T) {
 	logger := Logger{
-		Level:      ParseLevel("debug"),
+		Level:      DebugLevel,
 		TimeField:  "_time",
 		TimeFormat: time.RFC822,
 	}
@@ -194,20 +1129,866 @@ func TestLoggerTime(t *testing.T) {
 
 func TestLoggerTimestamp(t *testing.T) {
 	logger := Logger{
-		Level:     ParseLevel("debug"),
+		Level:     DebugLevel,
 		Timestamp: true,
 	}
 	logger.Info().Time("now", timeNow()).Msg("this is test time log event")
 }
 
+func TestLoggerTimestampMode(t *testing.T) {
+	cases := []struct {
+		Mode  TimestampMode
+		Scale float64
+	}{
+		{TimestampMilli, 1000},
+		{TimestampSecond, 1},
+		{TimestampFloat, 1},
+		{TimestampMicro, 1e6},
+		{TimestampNano, 1e9},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{
+			Timestamp:     true,
+			TimestampMode: c.Mode,
+			Writer:        &buf,
+		}
+		logger.Info().Msg("this is test timestamp mode log event")
+
+		var m struct {
+			Time json.Number
+		}
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+			t.Fatalf("json unmarshal %s error: %+v", buf.Bytes(), err)
+		}
+		f, err := m.Time.Float64()
+		if err != nil {
+			t.Fatalf("timestamp mode %v output invalid time %s", c.Mode, m.Time)
+		}
+		if d := float64(timeNow().UnixNano())/1e9 - f/c.Scale; d < 0 || d > 2 {
+			t.Errorf("timestamp mode %v output time %s is out of range", c.Mode, m.Time)
+		}
+		if c.Mode == TimestampFloat && !strings.Contains(m.Time.String(), ".") {
+			t.Errorf("timestamp mode %v output time %s is not a float", c.Mode, m.Time)
+		}
+		if c.Mode != TimestampFloat && strings.Contains(m.Time.String(), ".") {
+			t.Errorf("timestamp mode %v output time %s is not an integer", c.Mode, m.Time)
+		}
+	}
+
+	cases2 := []struct {
+		Sec    int64
+		Nsec   int32
+		Mode   TimestampMode
+		Output string
+	}{
+		{1585211234, 567890123, TimestampMilli, "1585211234567"},
+		{1585211234, 567890123, TimestampSecond, "1585211234"},
+		{1585211234, 567890123, TimestampFloat, "1585211234.567"},
+		{1585211234, 567890123, TimestampMicro, "1585211234567890"},
+		{1585211234, 567890123, TimestampNano, "1585211234567890123"},
+		{1585211234, 5, TimestampMilli, "1585211234000"},
+		{1585211234, 5, TimestampMicro, "1585211234000000"},
+		{1585211234, 5, TimestampNano, "1585211234000000005"},
+		{1585211234, 999999999, TimestampMicro, "1585211234999999"},
+		{1585211234, 999999999, TimestampNano, "1585211234999999999"},
+	}
+
+	for _, c := range cases2 {
+		e := &Event{}
+		e.unixTimestamp(c.Sec, c.Nsec, c.Mode)
+		if string(e.buf) != c.Output {
+			t.Errorf("timestamp mode %v of %d.%09d got %s, want %s", c.Mode, c.Sec, c.Nsec, e.buf, c.Output)
+		}
+	}
+
+	now := time.Now()
+	for _, c := range []struct {
+		Mode TimestampMode
+		Want int64
+	}{
+		{TimestampSecond, now.Unix()},
+		{TimestampMilli, now.UnixNano() / 1e6},
+		{TimestampMicro, now.UnixNano() / 1e3},
+		{TimestampNano, now.UnixNano()},
+	} {
+		e := &Event{}
+		e.unixTimestamp(now.Unix(), int32(now.Nanosecond()), c.Mode)
+		if string(e.buf) != strconv.FormatInt(c.Want, 10) {
+			t.Errorf("timestamp mode %v of %s got %s, want %d", c.Mode, now, e.buf, c.Want)
+		}
+	}
+}
+
+func TestLoggerTimestampOverflow(t *testing.T) {
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+
+	for _, tm := range []time.Time{
+		time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1970, 1, 1, 0, 0, 1, 2003004, time.UTC),
+		time.Date(1969, 12, 31, 23, 59, 58, 500000000, time.UTC),
+		time.Date(2001, 1, 1, 0, 0, 0, 7000000, time.UTC),
+		time.Date(2001, 9, 9, 1, 46, 40, 0, time.UTC),
+		time.Date(2020, 3, 26, 8, 27, 14, 567890123, time.UTC),
+		time.Date(2286, 11, 20, 17, 46, 40, 0, time.UTC),
+		time.Date(2300, 1, 1, 0, 0, 0, 123456789, time.UTC),
+	} {
+		timeNow = func() time.Time { return tm }
+		now := timeNow()
+		sec, nsec := now.Unix(), int32(now.Nanosecond())
+		// time.UnixMilli requires go1.17, and UnixNano overflows after 2262
+		milli := sec*1000 + int64(nsec)/1000000
+
+		for _, c := range []struct {
+			Mode TimestampMode
+			Want string
+		}{
+			{TimestampMilli, strconv.FormatInt(milli, 10)},
+			{TimestampSecond, strconv.FormatInt(sec, 10)},
+			{TimestampFloat, strconv.FormatFloat(float64(milli)/1000, 'f', 3, 64)},
+			{TimestampMicro, strconv.FormatInt(sec*1000000+int64(nsec)/1000, 10)},
+		} {
+			e := &Event{}
+			e.unixTimestamp(sec, nsec, c.Mode)
+			if string(e.buf) != c.Want {
+				t.Errorf("timestamp mode %v of %s got %s, want %s", c.Mode, now, e.buf, c.Want)
+			}
+		}
+
+		var m struct {
+			Time json.Number
+		}
+		e := &Event{}
+		e.buf = append(e.buf, `{"time":`...)
+		e.unixTimestamp(sec, nsec, TimestampMilli)
+		e.buf = append(e.buf, '}')
+		if err := json.Unmarshal(e.buf, &m); err != nil {
+			t.Errorf("timestamp of %s is not a valid json number %s: %+v", now, e.buf, err)
+		} else if n, _ := m.Time.Int64(); n != milli {
+			t.Errorf("timestamp of %s got %d, want %d", now, n, milli)
+		}
+	}
+}
+
+func TestLoggerHexdump(t *testing.T) {
+	data := []byte("hello \"world\"\\\x00\x01\x02\xff, this is a frame of 47 bytes")
+
+	cases := []struct {
+		Max  int
+		Dump string
+	}{
+		{0, strings.TrimSuffix(stdhex.Dump(data), "\n")},
+		{20, strings.TrimSuffix(stdhex.Dump(data[:20]), "\n") + "\n... 27 bytes truncated"},
+		{16, strings.TrimSuffix(stdhex.Dump(data[:16]), "\n") + "\n... 31 bytes truncated"},
+		{-1, strings.TrimSuffix(stdhex.Dump(data), "\n")},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{Writer: &buf}
+		logger.Info().Hexdump("frame", data, c.Max).Msg("")
+
+		var m map[string]string
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+			t.Fatalf("hexdump output %s is not valid json: %v", buf.Bytes(), err)
+		}
+		if m["frame"] != c.Dump {
+			t.Errorf("hexdump(max=%d) got\n%s\nwant\n%s", c.Max, m["frame"], c.Dump)
+		}
+	}
+
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+	logger.Info().Hexdump("empty", nil, 0).Msg("")
+	if !strings.Contains(buf.String(), `"empty":""`) {
+		t.Errorf("hexdump of empty bytes got %s", buf.String())
+	}
+}
+
+func TestLoggerRawJSONMode(t *testing.T) {
+	cases := []struct {
+		Mode  RawJSONMode
+		Input string
+		JSON  string
+	}{
+		{RawJSONValidate, `{"a": 1, "b": [2, 3]}`, `"raw":{"a": 1, "b": [2, 3]}}`},
+		{RawJSONValidate, `{"a":1`, `"raw":"{\"a\":1","_raw_json_error":"unexpected end of JSON input"}`},
+		{RawJSONCompact, "{\n  \"a\": 1,\n  \"b\": [2, 3]\n}", `"raw":{"a":1,"b":[2,3]}}`},
+		{RawJSONCompact, `{"a":}`, `"raw":"{\"a\":}","_raw_json_error":"invalid character '}' looking for beginning of value"}`},
+		{RawJSONStrict, `{"a": 1}`, `"raw":{"a": 1}}`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{
+			Writer:      &buf,
+			RawJSONMode: c.Mode,
+		}
+		logger.Info().RawJSON("raw", []byte(c.Input)).Msg("")
+		if !strings.HasSuffix(strings.TrimSpace(buf.String()), c.JSON) {
+			t.Errorf("raw json mode %d of %q got %s, want suffix %s", c.Mode, c.Input, buf.String(), c.JSON)
+		}
+	}
+}
+
+type testCyclic struct {
+	Name string
+	Next *testCyclic
+}
+
+type testPanicMarshaler struct{}
+
+func (testPanicMarshaler) MarshalJSON() ([]byte, error) {
+	panic("boom")
+}
+
+func TestLoggerInterfaceProtection(t *testing.T) {
+	cyclic := &testCyclic{Name: "a"}
+	cyclic.Next = cyclic
+
+	nested := map[string]interface{}{"level": 10}
+	for i := 9; i > 0; i-- {
+		nested = map[string]interface{}{"level": i, "next": nested}
+	}
+
+	cases := []struct {
+		Value interface{}
+		Max   int
+		JSON  string
+	}{
+		{cyclic, 0, `"value":"marshaling error: json: unsupported value: encountered a cycle via *log.testCyclic"`},
+		{testPanicMarshaler{}, 0, `"value":"marshaling error: panic: boom"`},
+		{nested, 32, `"value":"{\"level\":1,\"next\":{\"level\":2,\"ne... 151 bytes truncated"`},
+		{map[string]string{"a": "中文"}, 11, `"value":"{\"a\":\"中... 5 bytes truncated"`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{
+			Writer:           &buf,
+			MaxInterfaceSize: c.Max,
+		}
+		logger.Info().Interface("value", c.Value).Str("foo", "bar").Msg("")
+		if !strings.Contains(buf.String(), c.JSON+`,"foo":"bar"`) {
+			t.Errorf("interface output %s does not contain %s", buf.String(), c.JSON)
+		}
+	}
+}
+
+type testJSONMarshaler struct{}
+
+func (testJSONMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{"custom":true}`), nil
+}
+
+func TestLoggerFloatSpecial(t *testing.T) {
+	cases := []struct {
+		Value float64
+		JSON  string
+	}{
+		{math.NaN(), `"NaN"`},
+		{math.Inf(1), `"+Inf"`},
+		{math.Inf(-1), `"-Inf"`},
+		{1.5, `1.5`},
+		{0.1, `0.1`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{Writer: &buf}
+		logger.Info().
+			Float64("f64", c.Value).
+			Float32("f32", float32(c.Value)).
+			Floats64("fs64", []float64{c.Value, 0}).
+			Floats32("fs32", []float32{float32(c.Value), 0}).
+			Array("arr", Arr().Float64(c.Value)).
+			Msg("")
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("float %v output %s is not valid json", c.Value, buf.Bytes())
+		}
+		want := `"f64":` + c.JSON + `,"f32":` + c.JSON + `,"fs64":[` + c.JSON + `,0],"fs32":[` + c.JSON + `,0],"arr":[` + c.JSON + `]`
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("float %v output %s does not contain %s", c.Value, buf.Bytes(), want)
+		}
+	}
+}
+
+func TestLoggerInterface(t *testing.T) {
+	cases := []struct {
+		Value interface{}
+		JSON  string
+	}{
+		{nil, `"value":null`},
+		{struct{ Name string }{"bob"}, `"value":{"Name":"bob"}`},
+		{map[string]interface{}{"a": 1, "b": "<c>"}, `"value":{"a":1,"b":"<c>"}`},
+		{[]int{1, 2}, `"value":[1,2]`},
+		{"str", `"value":"str"`},
+		{testJSONMarshaler{}, `"value":{"custom":true}`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{Writer: &buf}
+		logger.Info().Interface("value", c.Value).Str("foo", "bar").Msg("")
+		if !strings.Contains(buf.String(), c.JSON+`,"foo":"bar"`) {
+			t.Errorf("interface output %s does not contain %s", buf.String(), c.JSON)
+		}
+	}
+}
+
+func TestLoggerIPAddrAnon(t *testing.T) {
+	cases := []struct {
+		IP   net.IP
+		Bits [2]int
+		JSON string
+	}{
+		{net.ParseIP("192.0.2.123"), [2]int{8, 80}, `"ip":"192.0.2.0"`},
+		{net.IP{192, 0, 2, 123}, [2]int{8, 80}, `"ip":"192.0.2.0"`},
+		{net.ParseIP("::ffff:192.0.2.123"), [2]int{8, 80}, `"ip":"192.0.2.0"`},
+		{net.ParseIP("2001:db8:85a3:1234:5678:8a2e:370:7334"), [2]int{8, 80}, `"ip":"2001:db8:85a3::"`},
+		{net.ParseIP("192.0.2.123"), [2]int{12, 80}, `"ip":"192.0.0.0"`},
+		{net.ParseIP("2001:db8:85a3:1234:5678:8a2e:370:7334"), [2]int{8, 64}, `"ip":"2001:db8:85a3:1234::"`},
+		{net.ParseIP("2001:db8:85a3:1234:5678:8a2e:370:7334"), [2]int{8, 68}, `"ip":"2001:db8:85a3:1230::"`},
+		{nil, [2]int{8, 80}, `"ip":null`},
+	}
+
+	defer func(v4, v6 int) { IPv4AnonBits, IPv6AnonBits = v4, v6 }(IPv4AnonBits, IPv6AnonBits)
+	for _, c := range cases {
+		IPv4AnonBits, IPv6AnonBits = c.Bits[0], c.Bits[1]
+		var buf bytes.Buffer
+		logger := Logger{Writer: &buf}
+		logger.Info().IPAddrAnon("ip", c.IP).Msg("")
+		if !strings.Contains(buf.String(), c.JSON) {
+			t.Errorf("IPAddrAnon(%v) with %v bits got %s, want %s", c.IP, c.Bits, buf.String(), c.JSON)
+		}
+	}
+}
+
+type testBroken struct{}
+
+func (testBroken) MarshalObject(e *Event) {
+	e.buf = append(e.buf, `,"broken":`...)
+}
+
+func TestLoggerValidateJSON(t *testing.T) {
+	var errs []error
+	defer func(h func(error)) { ErrorHandler = h }(ErrorHandler)
+	ErrorHandler = func(err error) { errs = append(errs, err) }
+
+	var buf bytes.Buffer
+	logger := Logger{
+		Writer:       &buf,
+		ValidateJSON: true,
+	}
+
+	logger.Info().Str("foo", "bar").Msg("valid")
+	if len(errs) != 0 || strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("valid output %q should pass the validation: %v", buf.String(), errs)
+	}
+
+	buf.Reset()
+	logger.Info().Objects("obj", []ObjectMarshaler{testBroken{}}).Msg("invalid")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || json.Valid([]byte(lines[0])) || !json.Valid([]byte(lines[1])) {
+		t.Fatalf("invalid output %q should be followed by a diagnostic event", buf.String())
+	}
+	if !strings.Contains(lines[1], `"level":"error"`) || !strings.Contains(lines[1], `log: invalid JSON output`) {
+		t.Errorf("unexpected diagnostic event %s", lines[1])
+	}
+	if len(errs) != 1 {
+		t.Errorf("invalid output should be reported to ErrorHandler once, got %v", errs)
+	}
+}
+
 func TestLoggerHost(t *testing.T) {
 	logger := Logger{
-		Level:     ParseLevel("debug"),
+		Level:     DebugLevel,
 		HostField: "host",
 	}
 	logger.Info().Time("now", timeNow()).Msg("this is test host log event")
 }
 
+func TestLoggerProcess(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{
+		HostField:    "host",
+		PIDField:     "pid",
+		ProgramField: "program",
+		Writer:       &buf,
+	}
+
+	logger.Info().Msg("hello process")
+	want := `,"host":` + strconv.Quote(hostname) + `,"pid":` + strconv.Itoa(os.Getpid()) + `,"program":` + strconv.Quote(filepath.Base(os.Args[0])) + `,"message":"hello process"}`
+	if s := buf.String(); !strings.Contains(s, want) {
+		t.Errorf("process output %s does not contain %s", s, want)
+	}
+
+	buf.Reset()
+	logger.Schema = ECS
+	logger.Info().Msg("hello ecs process")
+	want = `,"process.pid":` + strconv.Itoa(os.Getpid()) + `,"process.name":` + strconv.Quote(filepath.Base(os.Args[0])) + `,"message":"hello ecs process"}`
+	if s := buf.String(); !strings.Contains(s, want) {
+		t.Errorf("process output %s does not contain %s", s, want)
+	}
+
+	logger.Writer = ioutil.Discard
+	plain := Logger{Writer: ioutil.Discard}
+	base := allocsPerRun(100, func() {
+		plain.Info().Msg("hello process")
+	})
+	if n := allocsPerRun(100, func() {
+		logger.Info().Msg("hello process")
+	}); n > base {
+		t.Errorf("process fields allocate %v times per event, want %v", n, base)
+	}
+}
+
+func TestLoggerEscapeHTML(t *testing.T) {
+	cases := []struct {
+		EscapeHTML bool
+		JSON       string
+	}{
+		{false, `"html":"<a href='x'>&\"\\\n\u0001</a>"`},
+		{true, `"html":"\u003ca href=\u0027x\u0027\u003e\u0026\"\\\n\u0001\u003c/a\u003e"`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{Writer: &buf, EscapeHTML: c.EscapeHTML}
+		logger.Info().Str("html", "<a href='x'>&\"\\\n\x01</a>").Msg("")
+		if !bytes.Contains(buf.Bytes(), []byte(c.JSON)) {
+			t.Errorf("escape html %v output %s does not contain %s", c.EscapeHTML, buf.Bytes(), c.JSON)
+		}
+		var entry struct {
+			HTML string `json:"html"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil || entry.HTML != "<a href='x'>&\"\\\n\x01</a>" {
+			t.Errorf("escape html %v output %s does not round trip: %+v", c.EscapeHTML, buf.Bytes(), err)
+		}
+	}
+}
+
+func TestLoggerInvalidUTF8(t *testing.T) {
+	cases := []struct {
+		Input string
+		Value string
+	}{
+		{"ascii", "ascii"},
+		{"中文", "中文"},
+		{"\xff", "�"},
+		{"a\xffb", "a�b"},
+		{"中\xe4\xb8文", "中��文"},
+		{"\xe4\xb8", "��"},
+		{"\"\xc0\x80\n", "\"��\n"},
+		{"\xed\xa0\x80", "���"},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{Writer: &buf}
+		logger.Info().Str("str", c.Input).Bytes("bytes", []byte(c.Input)).Strs("strs", []string{c.Input}).Msg(c.Input)
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("invalid utf8 %q output %s is not valid json", c.Input, buf.Bytes())
+		}
+		var entry struct {
+			Str     string   `json:"str"`
+			Bytes   string   `json:"bytes"`
+			Strs    []string `json:"strs"`
+			Message string   `json:"message"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("unmarshal invalid utf8 output %s error: %+v", buf.Bytes(), err)
+		}
+		if entry.Str != c.Value || entry.Bytes != c.Value || entry.Strs[0] != c.Value || entry.Message != c.Value {
+			t.Errorf("invalid utf8 %q got %+v, want %q", c.Input, entry, c.Value)
+		}
+	}
+
+	logger := Logger{Writer: ioutil.Discard}
+	base := allocsPerRun(100, func() {
+		logger.Info().Msg("")
+	})
+	if n := allocsPerRun(100, func() {
+		logger.Info().Str("ascii", "hello world").Str("utf8", "你好，世界").Msg("")
+	}); n > base {
+		t.Errorf("valid utf8 strings should not allocate, got %v allocs, want %v", n, base)
+	}
+}
+
+func TestLoggerKeyEscape(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{
+		TimeField: "t\"s",
+		HostField: "ho\nst",
+		Writer:    &buf,
+	}
+	logger.Info().Str("a\"b", "x").Int("c\\d", 1).Bool("e\nf", true).Strs("plain", nil).Msg("")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unmarshal escaped keys output %s error: %+v", buf.Bytes(), err)
+	}
+	for _, key := range []string{"t\"s", "ho\nst", "a\"b", "c\\d", "e\nf", "plain"} {
+		if _, ok := entry[key]; !ok {
+			t.Errorf("escaped keys output %s does not contain key %q", buf.Bytes(), key)
+		}
+	}
+	if n := bytes.Count(buf.Bytes(), []byte{'\n'}); n != 1 {
+		t.Errorf("escaped keys output %q should be one line, got %d", buf.Bytes(), n)
+	}
+}
+
+type levelWriter struct {
+	levels []Level
+	lines  []string
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	w.levels = append(w.levels, NoLevel)
+	w.lines = append(w.lines, string(p))
+	return len(p), nil
+}
+
+func (w *levelWriter) WriteLevel(level Level, p []byte) (int, error) {
+	w.levels = append(w.levels, level)
+	w.lines = append(w.lines, string(p))
+	return len(p), nil
+}
+
+func TestLoggerLevelWriter(t *testing.T) {
+	if nodebug {
+		t.Skip("debug events are eliminated by log_nodebug")
+	}
+
+	w := &levelWriter{}
+
+	logger := Logger{
+		Level:  DebugLevel,
+		Writer: w,
+	}
+	logger.Debug().Msg("debug level writer")
+	logger.Info().Msg("info level writer")
+	logger.Warn().Msg("warn level writer")
+	logger.Error().Msg("error level writer")
+	logger.WithLevel(PanicLevel).Msg("panic level writer")
+
+	levels := []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel, PanicLevel}
+	if len(w.levels) != len(levels) {
+		t.Fatalf("level writer got %d lines, want %d", len(w.levels), len(levels))
+	}
+	for i, level := range levels {
+		if w.levels[i] != level {
+			t.Errorf("level writer line %d got level %v, want %v: %s", i, w.levels[i], level, w.lines[i])
+		}
+	}
+}
+
+type testBackend struct {
+	Name   string
+	Weight int
+}
+
+func (b *testBackend) MarshalObject(e *Event) {
+	e.Str("name", b.Name).Int("weight", b.Weight)
+}
+
+type testEmpty struct{}
+
+func (testEmpty) MarshalObject(e *Event) {}
+
+func TestLoggerObjects(t *testing.T) {
+	cases := []struct {
+		Items []ObjectMarshaler
+		JSON  string
+	}{
+		{nil, `"backends":[]`},
+		{[]ObjectMarshaler{}, `"backends":[]`},
+		{[]ObjectMarshaler{nil}, `"backends":[null]`},
+		{[]ObjectMarshaler{testEmpty{}}, `"backends":[{}]`},
+		{[]ObjectMarshaler{&testBackend{"a", 1}}, `"backends":[{"name":"a","weight":1}]`},
+		{[]ObjectMarshaler{&testBackend{"a", 1}, nil, testEmpty{}, &testBackend{"b", 2}}, `"backends":[{"name":"a","weight":1},null,{},{"name":"b","weight":2}]`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{Writer: &buf}
+		logger.Info().Objects("backends", c.Items).Msg("")
+		if !bytes.Contains(buf.Bytes(), []byte(c.JSON)) {
+			t.Errorf("objects output %s does not contain %s", buf.Bytes(), c.JSON)
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("objects output %s is not valid json", buf.Bytes())
+		}
+	}
+}
+
+func TestLoggerObject(t *testing.T) {
+	cases := []struct {
+		Object ObjectMarshaler
+		JSON   string
+	}{
+		{nil, `"backend":null`},
+		{testEmpty{}, `"backend":{}`},
+		{&testBackend{"a", 1}, `"backend":{"name":"a","weight":1}`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{Writer: &buf}
+		logger.Info().Object("backend", c.Object).EmbedObject(c.Object).Msg("")
+		if !bytes.Contains(buf.Bytes(), []byte(c.JSON)) {
+			t.Errorf("object output %s does not contain %s", buf.Bytes(), c.JSON)
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("object output %s is not valid json", buf.Bytes())
+		}
+	}
+
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+	logger.Info().EmbedObject(&testBackend{"a", 1}).Msg("")
+	if !bytes.Contains(buf.Bytes(), []byte(`"level":"info","name":"a","weight":1}`)) {
+		t.Errorf("embed object output %s has wrong fields", buf.Bytes())
+	}
+}
+
+func TestLoggerDict(t *testing.T) {
+	cases := []struct {
+		Dict *Event
+		JSON string
+	}{
+		{nil, `"http":null`},
+		{Dict(), `"http":{}`},
+		{Dict().Str("method", "GET").Int("status", 200), `"http":{"method":"GET","status":200}`},
+		{Dict().Str("method", "GET").Dict("url", Dict().Str("path", "/").Strs("query", []string{"a"})), `"http":{"method":"GET","url":{"path":"/","query":["a"]}}`},
+		{Dict().Dict("a", Dict().Dict("b", Dict())), `"http":{"a":{"b":{}}}`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{Writer: &buf}
+		logger.Info().Dict("http", c.Dict).Msg("")
+		if !bytes.Contains(buf.Bytes(), []byte(c.JSON)) {
+			t.Errorf("dict output %s does not contain %s", buf.Bytes(), c.JSON)
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("dict output %s is not valid json", buf.Bytes())
+		}
+	}
+
+	logger := Logger{Level: InfoLevel, Writer: ioutil.Discard}
+	logger.Debug().Dict("http", Dict().Str("method", "GET")).Msg("")
+}
+
+func TestLoggerFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+
+	logger.Info().Fields(map[string]interface{}{
+		"str":   "a\"b",
+		"bool":  true,
+		"int":   -1,
+		"uint8": uint8(2),
+		"float": 0.5,
+		"dur":   time.Second,
+		"err":   errors.New("oops"),
+		"nil":   nil,
+		"map":   map[string]int{"x": 1},
+		"nan":   math.NaN(),
+	}).Msg("")
+
+	want := `"bool":true,"dur":"1s","err":"oops","float":0.5,"int":-1,"map":{"x":1},"nan":"NaN","nil":null,"str":"a\"b","uint8":2`
+	if !bytes.Contains(buf.Bytes(), []byte(want)) {
+		t.Errorf("fields output %s does not contain %s", buf.Bytes(), want)
+	}
+
+	buf.Reset()
+	logger.Info().Fields(nil).Msg("")
+	if bytes.Contains(buf.Bytes(), []byte(`"level":"info",`)) {
+		t.Errorf("nil fields should be a no-op: %s", buf.Bytes())
+	}
+
+	logger = Logger{Level: InfoLevel, Writer: ioutil.Discard}
+	logger.Debug().Fields(map[string]interface{}{"a": 1}).Msg("")
+}
+
+func TestLoggerIntSlices(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+
+	logger.Info().
+		Ints("ints", []int{-1, 0, 1}).
+		Ints64("ints64", []int64{math.MinInt64, math.MaxInt64}).
+		Ints32("ints32", []int32{math.MinInt32}).
+		Uints("uints", []uint{0, 1}).
+		Uints64("uints64", []uint64{math.MaxUint64}).
+		Uints32("uints32", []uint32{}).
+		Ints("nil", nil).
+		Msg("")
+	want := `"ints":[-1,0,1],"ints64":[-9223372036854775808,9223372036854775807],"ints32":[-2147483648],"uints":[0,1],"uints64":[18446744073709551615],"uints32":[],"nil":[]}`
+	if s := buf.String(); !strings.HasSuffix(s, want+"\n") {
+		t.Errorf("int slices output %s, want %s", s, want)
+	}
+}
+
+func BenchmarkIntSlices(b *testing.B) {
+	logger := Logger{
+		Level:  DebugLevel,
+		Writer: ioutil.Discard,
+	}
+	ints := []int{1, 2, 3, 4, 5, 6, 7, 8}
+	uints64 := []uint64{1, 2, 3, 4, 5, 6, 7, 8}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info().Ints("ints", ints).Uints64("uints64", uints64).Msg("hello world")
+	}
+}
+
+type testStringer struct {
+	s      string
+	called *int
+}
+
+func (v testStringer) String() string {
+	*v.called++
+	return v.s
+}
+
+func TestLoggerStringer(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Level: InfoLevel, Writer: &buf}
+
+	var called int
+	logger.Info().
+		Stringer("a", testStringer{`"quoted"`, &called}).
+		Stringer("b", nil).
+		Stringers("c", []fmt.Stringer{testStringer{"x", &called}, nil}).
+		Stringers("d", nil).
+		Msg("")
+	want := `"a":"\"quoted\"","b":null,"c":["x",null],"d":[]}`
+	if s := buf.String(); !strings.HasSuffix(s, want+"\n") {
+		t.Errorf("stringer output %s, want %s", s, want)
+	}
+	if called != 2 {
+		t.Errorf("stringer String called %d times, want 2", called)
+	}
+
+	logger.Debug().Stringer("a", testStringer{"x", &called}).Stringers("b", []fmt.Stringer{testStringer{"x", &called}}).Msg("")
+	if called != 2 {
+		t.Errorf("stringer String of a disabled event called %d times, want 2", called)
+	}
+}
+
+func TestLoggerSlices(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	cases := []struct {
+		Name  string
+		Add   func(e *Event, v int) *Event
+		Value string
+	}{
+		{"Bools", func(e *Event, v int) *Event {
+			return e.Bools("v", [][]bool{nil, {}, {true, false}}[v])
+		}, `[true,false]`},
+		{"Durs", func(e *Event, v int) *Event {
+			return e.Durs("v", [][]time.Duration{nil, {}, {time.Second}}[v])
+		}, `["1s"]`},
+		{"Strs", func(e *Event, v int) *Event {
+			return e.Strs("v", [][]string{nil, {}, {"a", "b"}}[v])
+		}, `["a","b"]`},
+		{"Errs", func(e *Event, v int) *Event {
+			return e.Errs("v", [][]error{nil, {}, {errors.New("a"), nil}}[v])
+		}, `["a",null]`},
+		{"Floats64", func(e *Event, v int) *Event {
+			return e.Floats64("v", [][]float64{nil, {}, {1.5}}[v])
+		}, `[1.5]`},
+		{"Floats32", func(e *Event, v int) *Event {
+			return e.Floats32("v", [][]float32{nil, {}, {1.5}}[v])
+		}, `[1.5]`},
+		{"Ints", func(e *Event, v int) *Event {
+			return e.Ints("v", [][]int{nil, {}, {1, -1}}[v])
+		}, `[1,-1]`},
+		{"Ints64", func(e *Event, v int) *Event {
+			return e.Ints64("v", [][]int64{nil, {}, {1}}[v])
+		}, `[1]`},
+		{"Ints32", func(e *Event, v int) *Event {
+			return e.Ints32("v", [][]int32{nil, {}, {1}}[v])
+		}, `[1]`},
+		{"Uints", func(e *Event, v int) *Event {
+			return e.Uints("v", [][]uint{nil, {}, {1}}[v])
+		}, `[1]`},
+		{"Uints64", func(e *Event, v int) *Event {
+			return e.Uints64("v", [][]uint64{nil, {}, {1}}[v])
+		}, `[1]`},
+		{"Uints32", func(e *Event, v int) *Event {
+			return e.Uints32("v", [][]uint32{nil, {}, {1}}[v])
+		}, `[1]`},
+		{"Times", func(e *Event, v int) *Event {
+			return e.Times("v", [][]time.Time{nil, {}, {ts, ts.Add(time.Hour)}}[v])
+		}, `["2024-01-02T03:04:05.0000006Z","2024-01-02T04:04:05.0000006Z"]`},
+		{"TimesFormat", func(e *Event, v int) *Event {
+			return e.TimesFormat("v", time.Kitchen, [][]time.Time{nil, {}, {ts}}[v])
+		}, `["3:04AM"]`},
+		{"Stringers", func(e *Event, v int) *Event {
+			return e.Stringers("v", [][]fmt.Stringer{nil, {}, {time.Second}}[v])
+		}, `["1s"]`},
+		{"Objects", func(e *Event, v int) *Event {
+			return e.Objects("v", [][]ObjectMarshaler{nil, {}, {objectError{}}}[v])
+		}, `[{"reason":"object"}]`},
+	}
+
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+	for _, c := range cases {
+		// the value 0 adds a nil slice, 1 an empty slice and 2 a populated slice.
+		for v, want := range []string{`[]`, `[]`, c.Value} {
+			buf.Reset()
+			c.Add(logger.Info(), v).Msg("")
+			if s := buf.String(); !strings.HasSuffix(s, `"v":`+want+"}\n") {
+				t.Errorf("%s of slice %d output %s, want %s", c.Name, v, s, want)
+			}
+		}
+	}
+}
+
+func TestLoggerBase64(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+
+	data := []byte("\x00\xff\xfe hello world")
+	for _, n := range []int{0, 1, 2, 3, 4, 5, len(data)} {
+		for _, enc := range []*base64.Encoding{nil, base64.URLEncoding, base64.RawStdEncoding} {
+			want := base64.StdEncoding.EncodeToString(data[:n])
+			if enc != nil {
+				want = enc.EncodeToString(data[:n])
+			}
+			buf.Reset()
+			logger.Info().Base64("data", data[:n], enc).Msg("")
+			if s := buf.String(); !strings.HasSuffix(s, `"data":"`+want+`"}`+"\n") {
+				t.Errorf("base64 of %d bytes output %s, want %s", n, s, want)
+			}
+		}
+	}
+}
+
+func BenchmarkCallers(b *testing.B) {
+	logger := Logger{
+		Level:  DebugLevel,
+		Writer: ioutil.Discard,
+	}
+
+	for _, depth := range []int{1, 4, 8, 32} {
+		b.Run(strconv.Itoa(depth), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				logger.Info().Callers(depth).Msg("hello world")
+			}
+		})
+	}
+}
+
 func BenchmarkLogger(b *testing.B) {
 	logger := Logger{
 		Timestamp: true,
@@ -0,0 +1,10 @@
+// +build !log_leakcheck
+
+package log
+
+// eventLeak is empty unless built with the log_leakcheck tag.
+type eventLeak struct{}
+
+func leakTrack(e *Event) {}
+
+func leakDone(e *Event) {}
@@ -0,0 +1,48 @@
+// +build log_leakcheck
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// eventLeak records the creation site of an event, it is reported by
+// its finalizer if the event was neither sent nor discarded.
+type eventLeak struct {
+	file string
+	line int
+	done uint32
+}
+
+func leakTrack(e *Event) {
+	l := &eventLeak{}
+	_, l.file, l.line, _ = runtime.Caller(3)
+	if i := strings.LastIndex(l.file, "/"); i >= 0 {
+		l.file = l.file[i+1:]
+	}
+	runtime.SetFinalizer(l, leakReport)
+	e.leak = l
+}
+
+func leakDone(e *Event) {
+	if e.leak != nil {
+		atomic.StoreUint32(&e.leak.done, 1)
+		e.leak = nil
+	}
+}
+
+func leakReport(l *eventLeak) {
+	if atomic.LoadUint32(&l.done) != 0 {
+		return
+	}
+	err := fmt.Errorf("log: event created at %s:%d was neither sent nor discarded", l.file, l.line)
+	if ErrorHandler != nil {
+		ErrorHandler(err)
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
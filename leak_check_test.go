@@ -0,0 +1,52 @@
+// +build log_leakcheck
+
+package log
+
+import (
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeakCheck(t *testing.T) {
+	errs := make(chan error, 8)
+	defer func(h func(error)) { ErrorHandler = h }(ErrorHandler)
+	ErrorHandler = func(err error) { errs <- err }
+
+	logger := Logger{Writer: ioutil.Discard}
+
+	logger.Info().Str("foo", "bar").Msg("sent event")
+	logger.Info().Str("foo", "bar").Discard()
+	for i := 0; i < 10; i++ {
+		logger.Info().Sample(2).Str("foo", "bar").Msg("sampled event")
+	}
+	func() {
+		e := logger.Info().Str("foo", "bar")
+		if e == nil {
+			return
+		}
+		// forget to call e.Msg
+	}()
+
+	for i := 0; i < 5; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "leak_check_test.go:") {
+			t.Errorf("leak check reports wrong site: %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("leak check does not report the leaked event")
+	}
+
+	select {
+	case err := <-errs:
+		t.Errorf("leak check reports unexpected leak: %+v", err)
+	default:
+	}
+}
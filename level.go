@@ -1,31 +1,153 @@
 package log
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // Level defines log levels.
 type Level uint32
 
+// The levels are spaced out so custom levels can be registered between them by RegisterLevel.
+// They were numbered 1 to 7 in the previous versions, and the zero Level still logs all levels.
 const (
+	// TraceLevel defines trace log level.
+	TraceLevel Level = 10
 	// DebugLevel defines debug log level.
-	DebugLevel Level = iota
+	DebugLevel Level = 20
 	// InfoLevel defines info log level.
-	InfoLevel
+	InfoLevel Level = 30
 	// WarnLevel defines warn log level.
-	WarnLevel
+	WarnLevel Level = 40
 	// ErrorLevel defines error log level.
-	ErrorLevel
+	ErrorLevel Level = 50
 	// FatalLevel defines fatal log level.
-	FatalLevel
+	FatalLevel Level = 60
 	// PanicLevel defines panic log level.
-	PanicLevel
+	PanicLevel Level = 70
 	// NoLevel defines an absent log level.
-	NoLevel
+	NoLevel Level = 254
 	// Disabled disables the logger.
-	Disabled
+	Disabled Level = 255
+)
+
+// levelNames is the names of levels, the level field of an event is omitted if its name is empty.
+var levelNames = [256]string{
+	TraceLevel: "trace",
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+	FatalLevel: "fatal",
+	PanicLevel: "panic",
+}
+
+// levelFields is the precomputed level fields of levels, e.g. `,"level":"info"`.
+var levelFields = func() (fields [256]string) {
+	for i, name := range levelNames {
+		if name != "" {
+			fields[i] = ",\"level\":\"" + name + "\""
+		}
+	}
+	return
+}()
+
+// LevelEncoder defines how Logger encodes the level field.
+type LevelEncoder int
+
+const (
+	// LevelEncoderLowercase encodes the level as its lowercase name, e.g. "info".
+	LevelEncoderLowercase LevelEncoder = iota
+	// LevelEncoderGCP encodes the level as the severity of Google Cloud Logging by
+	// the syslog severity of SyslogWriter, i.e. trace and debug to "DEBUG", info to
+	// "INFO", warn to "WARNING", error to "ERROR", fatal to "CRITICAL" and panic to
+	// "EMERGENCY". A custom level uses the severity of the nearest lower one.
+	LevelEncoderGCP
+	// LevelEncoderSyslog encodes the level as the numeric syslog severity like
+	// LevelEncoderGCP, e.g. 6 for info and 2 for fatal.
+	LevelEncoderSyslog
 )
 
-// ParseLevel converts a level string into a log Level value.
-// returns an error if the input string does not match known values.
-func ParseLevel(s string) (level Level) {
+// gcpSeverities is the severities of Google Cloud Logging indexed by the syslog severity.
+var gcpSeverities = [8]string{"EMERGENCY", "ALERT", "CRITICAL", "ERROR", "WARNING", "NOTICE", "INFO", "DEBUG"}
+
+// levelValues is the precomputed JSON values of the level field by LevelEncoder,
+// e.g. `"info"`, `"INFO"` and `6`.
+var levelValues = func() (values [3][256]string) {
+	for i, name := range levelNames {
+		if name != "" {
+			setLevelValues(&values, Level(i), name)
+		}
+	}
+	return
+}()
+
+func setLevelValues(values *[3][256]string, level Level, name string) {
+	values[LevelEncoderLowercase][level] = "\"" + name + "\""
+	values[LevelEncoderGCP][level] = "\"" + gcpSeverities[syslogSeverity(level)] + "\""
+	values[LevelEncoderSyslog][level] = strconv.Itoa(syslogSeverity(level))
+}
+
+// RegisterLevel registers a custom level with the numeric ordering and name, e.g.
+// RegisterLevel(35, "notice") for a level between info and warn. The events of the
+// level are written with the name in the level field, and ParseLevel, Level.String
+// and ConsoleWriter honor it.
+//
+// The level must be between 0 and NoLevel exclusively and must not collide
+// with a built-in or registered level. It is not safe for concurrent use with logging,
+// and should be called in the initialization.
+func RegisterLevel(level Level, name string) error {
+	if level == 0 || level >= NoLevel {
+		return fmt.Errorf("log: level %d of %q is out of range", level, name)
+	}
+	if levelNames[level] != "" {
+		return fmt.Errorf("log: level %d of %q collides with level %q", level, name, levelNames[level])
+	}
+	if name == "" || strings.ContainsAny(name, "\"\\") || parseLevel(name) != NoLevel {
+		return fmt.Errorf("log: level name %q is invalid or registered", name)
+	}
+	levelNames[level] = name
+	levelFields[level] = ",\"level\":\"" + name + "\""
+	setLevelValues(&levelValues, level, name)
+	return nil
+}
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch {
+	case l == NoLevel:
+		return "nolevel"
+	case l == Disabled:
+		return "disabled"
+	case l < Level(len(levelNames)) && levelNames[l] != "":
+		return levelNames[l]
+	}
+	return "Level(" + strconv.FormatUint(uint64(l), 10) + ")"
+}
+
+// ParseLevel converts a level name into a log Level value case-insensitively, e.g.
+// "info", "WARN" or "warning", and the names registered by RegisterLevel.
+// It returns an error if the name is unknown.
+func ParseLevel(s string) (Level, error) {
+	if level := parseLevel(s); level != NoLevel {
+		return level, nil
+	}
+	switch {
+	case strings.EqualFold(s, "nolevel"):
+		return NoLevel, nil
+	case strings.EqualFold(s, "disabled"):
+		return Disabled, nil
+	}
+	return NoLevel, fmt.Errorf("log: unknown level %q", s)
+}
+
+// parseLevel converts a level name or its abbreviation into a log Level value,
+// it returns NoLevel if the name is unknown.
+func parseLevel(s string) (level Level) {
 	switch s {
+	case "trace", "Trace", "TRACE", "T", "TRC":
+		level = TraceLevel
 	case "debug", "Debug", "DEBUG", "D", "DBG":
 		level = DebugLevel
 	case "info", "Info", "INFO", "I", "INF":
@@ -40,6 +162,38 @@ func ParseLevel(s string) (level Level) {
 		level = PanicLevel
 	default:
 		level = NoLevel
+		if strings.EqualFold(s, "warning") {
+			level = WarnLevel
+			break
+		}
+		for i, name := range levelNames {
+			if name != "" && strings.EqualFold(name, s) {
+				level = Level(i)
+				break
+			}
+		}
 	}
 	return
 }
+
+// MarshalText implements encoding.TextMarshaler, the level is marshaled as its name.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, the level is parsed by ParseLevel.
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// Set implements flag.Value, the level is parsed by ParseLevel, e.g.
+//
+//	flag.Var(&logger.Level, "log-level", "the level of logger")
+func (l *Level) Set(s string) error {
+	return l.UnmarshalText([]byte(s))
+}
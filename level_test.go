@@ -1,6 +1,12 @@
 package log
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -9,16 +15,221 @@ func TestParseLevel(t *testing.T) {
 		Level  Level
 		String string
 	}{
+		{TraceLevel, "trace"},
 		{DebugLevel, "debug"},
 		{InfoLevel, "info"},
 		{WarnLevel, "warn"},
+		{WarnLevel, "warning"},
+		{WarnLevel, "WaRnInG"},
 		{ErrorLevel, "error"},
+		{ErrorLevel, "eRROR"},
 		{FatalLevel, "fatal"},
+		{PanicLevel, "PANIC"},
+		{Disabled, "disabled"},
 	}
 
 	for _, c := range cases {
-		if v := ParseLevel(c.String); v != c.Level {
-			t.Errorf("ParseLevel(%#v) must return %#v, not %#v", c.String, c.Level, v)
+		if v, err := ParseLevel(c.String); err != nil || v != c.Level {
+			t.Errorf("ParseLevel(%#v) must return %#v, not %#v, %v", c.String, c.Level, v, err)
 		}
 	}
+
+	for _, s := range []string{"", "verbose", "infoo"} {
+		if _, err := ParseLevel(s); err == nil || !strings.Contains(err.Error(), "unknown level") {
+			t.Errorf("ParseLevel(%#v) should return an unknown level error, got %v", s, err)
+		}
+	}
+}
+
+func TestLevelText(t *testing.T) {
+	var config struct {
+		Level Level `json:"level"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"level":"Warning"}`), &config); err != nil || config.Level != WarnLevel {
+		t.Errorf("unmarshal level got %v, %v", config.Level, err)
+	}
+	if b, err := json.Marshal(config); err != nil || string(b) != `{"level":"warn"}` {
+		t.Errorf("marshal level got %s, %v", b, err)
+	}
+	if err := json.Unmarshal([]byte(`{"level":"verbose"}`), &config); err == nil {
+		t.Errorf("unmarshal unknown level should return an error")
+	}
+	if config.Level != WarnLevel {
+		t.Errorf("unmarshal unknown level changes the level to %v", config.Level)
+	}
+
+	for _, level := range []Level{TraceLevel, InfoLevel, PanicLevel, NoLevel, Disabled} {
+		var l Level
+		text, _ := level.MarshalText()
+		if err := l.UnmarshalText(text); err != nil || l != level {
+			t.Errorf("level %v does not round trip, got %v, %v", level, l, err)
+		}
+	}
+}
+
+func TestLevelFlag(t *testing.T) {
+	logger := Logger{Level: InfoLevel}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Var(&logger.Level, "log-level", "the level of logger")
+
+	if err := fs.Parse([]string{"-log-level", "ERROR"}); err != nil || logger.Level != ErrorLevel {
+		t.Errorf("flag level got %v, %v", logger.Level, err)
+	}
+	if err := fs.Parse([]string{"-log-level", "loud"}); err == nil {
+		t.Errorf("flag unknown level should return an error")
+	}
+	if f := fs.Lookup("log-level"); f.Value.String() != "error" {
+		t.Errorf("flag level string got %q", f.Value.String())
+	}
+}
+
+func TestLevelString(t *testing.T) {
+	cases := []struct {
+		Level  Level
+		String string
+	}{
+		{TraceLevel, "trace"},
+		{InfoLevel, "info"},
+		{PanicLevel, "panic"},
+		{NoLevel, "nolevel"},
+		{Disabled, "disabled"},
+		{Level(42), "Level(42)"},
+		{Level(1000), "Level(1000)"},
+	}
+
+	for _, c := range cases {
+		if s := c.Level.String(); s != c.String {
+			t.Errorf("Level(%d).String() must return %#v, not %#v", c.Level, c.String, s)
+		}
+	}
+}
+
+func TestRegisterLevel(t *testing.T) {
+	defer func() {
+		for _, level := range []Level{35, 100} {
+			levelNames[level] = ""
+			levelFields[level] = ""
+			for i := range levelValues {
+				levelValues[i][level] = ""
+			}
+		}
+	}()
+
+	if err := RegisterLevel(35, "notice"); err != nil {
+		t.Fatalf("register notice level error: %+v", err)
+	}
+	if err := RegisterLevel(100, "audit"); err != nil {
+		t.Fatalf("register audit level error: %+v", err)
+	}
+
+	for _, c := range []struct {
+		Level Level
+		Name  string
+	}{
+		{0, "zero"},
+		{NoLevel, "none"},
+		{1000, "big"},
+		{InfoLevel, "information"},
+		{35, "notice2"},
+		{36, "notice"},
+		{37, "Warning"},
+		{38, `bad"name`},
+		{39, ""},
+	} {
+		if err := RegisterLevel(c.Level, c.Name); err == nil {
+			t.Errorf("RegisterLevel(%d, %#v) should return an error", c.Level, c.Name)
+		}
+	}
+
+	if s := Level(35).String(); s != "notice" {
+		t.Errorf("custom level string got %#v", s)
+	}
+	if level, err := ParseLevel("NOTICE"); err != nil || level != 35 {
+		t.Errorf("ParseLevel of custom level got %d, %v", level, err)
+	}
+
+	var buf bytes.Buffer
+	logger := Logger{
+		Level:  WarnLevel,
+		Writer: &buf,
+	}
+	logger.WithLevel(35).Msg("dropped")
+	logger.WithLevel(100).Msg("always")
+	logger.WithLevel(77).Msg("unknown")
+	if s := buf.String(); strings.Contains(s, "dropped") ||
+		!strings.Contains(s, `"level":"audit","message":"always"`) ||
+		!strings.Contains(s, `,"message":"unknown"`) || strings.Count(s, `"level"`) != 1 {
+		t.Errorf("unexpected custom level output %s", s)
+	}
+
+	buf.Reset()
+	w := &ConsoleWriter{Out: &buf, HideTime: true}
+	w.WriteLevel(35, []byte(`{"level":"notice","message":"hello"}`+"\n"))
+	w.Write([]byte(`{"level":"audit","message":"hello"}` + "\n"))
+	if s := buf.String(); s != "NOT > hello\nAUD > hello\n" {
+		t.Errorf("unexpected custom level console output %q", s)
+	}
+}
+
+func TestLevelEncoder(t *testing.T) {
+	cases := []struct {
+		Encoder LevelEncoder
+		Field   string
+		Values  []string
+	}{
+		{LevelEncoderLowercase, "", []string{`"level":"trace"`, `"level":"debug"`, `"level":"info"`, `"level":"warn"`, `"level":"error"`, `"level":"fatal"`, `"level":"panic"`}},
+		{LevelEncoderGCP, "severity", []string{`"severity":"DEBUG"`, `"severity":"DEBUG"`, `"severity":"INFO"`, `"severity":"WARNING"`, `"severity":"ERROR"`, `"severity":"CRITICAL"`, `"severity":"EMERGENCY"`}},
+		{LevelEncoderSyslog, "", []string{`"level":7`, `"level":7`, `"level":6`, `"level":4`, `"level":3`, `"level":2`, `"level":0`}},
+	}
+
+	osExit = func(int) {}
+	defer func() { osExit = os.Exit }()
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{Level: TraceLevel, Writer: &buf, LevelEncoder: c.Encoder, LevelField: c.Field}
+		for _, level := range []Level{TraceLevel, DebugLevel, InfoLevel, WarnLevel, ErrorLevel, FatalLevel, PanicLevel} {
+			logger.WithLevel(level).Msg("hello")
+		}
+		logger.WithLevel(NoLevel).Msg("no level")
+
+		// the fatal event is followed by the stacks.
+		var lines []string
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if strings.Contains(line, `"message":"hello"`) || strings.Contains(line, `"message":"no level"`) {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) != 8 {
+			t.Fatalf("level encoder %d output %d lines, want 8: %s", c.Encoder, len(lines), buf.String())
+		}
+		for i, want := range c.Values {
+			if !strings.Contains(lines[i], ","+want+",") {
+				t.Errorf("level encoder %d output %s, want %s", c.Encoder, lines[i], want)
+			}
+		}
+		if strings.Contains(lines[7], `"level"`) || strings.Contains(lines[7], `"severity"`) {
+			t.Errorf("level encoder %d output %s has a level field", c.Encoder, lines[7])
+		}
+	}
+
+	defer func() {
+		levelNames[45] = ""
+		levelFields[45] = ""
+		for i := range levelValues {
+			levelValues[i][45] = ""
+		}
+	}()
+	if err := RegisterLevel(45, "alarm"); err != nil {
+		t.Fatalf("register alarm level error: %+v", err)
+	}
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf, LevelEncoder: LevelEncoderGCP}
+	logger.WithLevel(45).Msg("custom")
+	if !strings.Contains(buf.String(), `,"level":"WARNING",`) {
+		t.Errorf("level encoder custom level output %s, want WARNING", buf.String())
+	}
 }
@@ -0,0 +1,164 @@
+package log
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// KeyedLimiter is an io.Writer wrapper which writes at most one event per key in
+// every Interval. The suppressed events of a key are counted and the count is added
+// to the next written event of the key as the "suppressed" field.
+// The keys are tracked in a LRU list so the memory is bounded by Capacity.
+type KeyedLimiter struct {
+	// Key returns the key of the event p. It uses the level and message of the event in if nil.
+	// The message is passed by Msg if the Logger writes to the limiter directly, otherwise
+	// it is parsed from the "message" field of p, or the whole p is used if p has no such
+	// field, e.g. the events of a Logger with MessageField written through another writer.
+	Key func(level Level, p []byte) string
+
+	// Interval specifies the minimum interval between the events of a key.
+	Interval time.Duration
+
+	// Capacity specifies the maximum number of keys tracked. It uses 1024 in if zero.
+	Capacity int
+
+	// Writer specifies the writer of output. It uses os.Stderr in if empty.
+	Writer io.Writer
+
+	mu   sync.Mutex
+	key  []byte
+	buf  []byte
+	list *list.List
+	keys map[string]*list.Element
+}
+
+type limiterEntry struct {
+	key        string
+	last       int64
+	suppressed int64
+}
+
+// Write implements io.Writer.
+func (l *KeyedLimiter) Write(p []byte) (n int, err error) {
+	return l.WriteLevel(NoLevel, p)
+}
+
+// WriteLevel implements LevelWriter.
+func (l *KeyedLimiter) WriteLevel(level Level, p []byte) (n int, err error) {
+	msg := messageOf(p)
+	if msg == nil {
+		msg = p
+	}
+	return l.limit(level, msg, p)
+}
+
+func (l *KeyedLimiter) writeMessage(level Level, msg string, p []byte) (n int, err error) {
+	return l.limit(level, *(*[]byte)(unsafe.Pointer(&sliceHeader{msg, len(msg)})), p)
+}
+
+// limit writes p unless the key of level and msg is written in Interval.
+func (l *KeyedLimiter) limit(level Level, msg, p []byte) (n int, err error) {
+	sec, nsec := walltime()
+	now := sec*1000000000 + int64(nsec)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.keys == nil {
+		l.list = list.New()
+		l.keys = make(map[string]*list.Element)
+	}
+
+	var key string
+	var entry *limiterEntry
+	var elem *list.Element
+	if l.Key != nil {
+		key = l.Key(level, p)
+		elem = l.keys[key]
+	} else {
+		l.key = append(append(l.key[:0], byte(level)), msg...)
+		elem = l.keys[string(l.key)]
+	}
+
+	if elem != nil {
+		entry = elem.Value.(*limiterEntry)
+		l.list.MoveToFront(elem)
+		if now-entry.last < int64(l.Interval) {
+			entry.suppressed++
+			return len(p), nil
+		}
+	} else {
+		if l.Key == nil {
+			key = string(l.key)
+		}
+		entry = &limiterEntry{key: key}
+		l.keys[key] = l.list.PushFront(entry)
+		capacity := l.Capacity
+		if capacity <= 0 {
+			capacity = 1024
+		}
+		for l.list.Len() > capacity {
+			back := l.list.Back()
+			delete(l.keys, back.Value.(*limiterEntry).key)
+			l.list.Remove(back)
+		}
+	}
+
+	entry.last = now
+	if entry.suppressed != 0 && len(p) >= 2 && p[len(p)-2] == '}' {
+		l.buf = append(l.buf[:0], p[:len(p)-2]...)
+		l.buf = append(l.buf, ",\"suppressed\":"...)
+		l.buf = strconv.AppendInt(l.buf, entry.suppressed, 10)
+		l.buf = append(l.buf, '}', '\n')
+		entry.suppressed = 0
+		_, err = l.write(level, l.buf)
+		return len(p), err
+	}
+
+	return l.write(level, p)
+}
+
+// Describe implements WriterDescriber.
+func (l *KeyedLimiter) Describe() string {
+	capacity := l.Capacity
+	if capacity <= 0 {
+		capacity = 1024
+	}
+	return fmt.Sprintf("KeyedLimiter{Interval:%s Capacity:%d} -> %s", l.Interval, capacity, describeWriter(l.Writer))
+}
+
+func (l *KeyedLimiter) write(level Level, p []byte) (int, error) {
+	w := l.Writer
+	if w == nil {
+		w = os.Stderr
+	}
+	if lw, ok := w.(LevelWriter); ok && level != NoLevel {
+		return lw.WriteLevel(level, p)
+	}
+	return w.Write(p)
+}
+
+// messageOf returns the encoded message string of the JSON event p.
+func messageOf(p []byte) []byte {
+	i := bytes.LastIndex(p, []byte(",\"message\":\""))
+	if i < 0 {
+		return nil
+	}
+	p = p[i+len(",\"message\":\""):]
+	for j := 0; j < len(p); j++ {
+		switch p[j] {
+		case '\\':
+			j++
+		case '"':
+			return p[:j]
+		}
+	}
+	return p
+}
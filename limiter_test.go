@@ -0,0 +1,142 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyedLimiter(t *testing.T) {
+	var buf bytes.Buffer
+	limiter := &KeyedLimiter{
+		Interval: 100 * time.Millisecond,
+		Writer:   &buf,
+	}
+	logger := Logger{
+		Writer: limiter,
+	}
+
+	for i := 0; i < 5; i++ {
+		logger.Warn().Int("i", i).Msg("disk is almost full")
+		logger.Error().Int("i", i).Msg("disk is almost full")
+		logger.Warn().Int("i", i).Msg("disk is full")
+	}
+	if s := buf.String(); strings.Count(s, "\n") != 3 || strings.Contains(s, `"i":1`) {
+		t.Fatalf("limiter should write one event per key, got %s", s)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	buf.Reset()
+	logger.Warn().Int("i", 5).Msg("disk is almost full")
+	if s := buf.String(); !strings.HasSuffix(s, `"i":5,"message":"disk is almost full","suppressed":4}`+"\n") {
+		t.Errorf("limiter should add the suppressed count, got %s", s)
+	}
+
+	buf.Reset()
+	time.Sleep(150 * time.Millisecond)
+	logger.Warn().Int("i", 6).Msg("disk is almost full")
+	if s := buf.String(); strings.Contains(s, "suppressed") {
+		t.Errorf("limiter should reset the suppressed count, got %s", s)
+	}
+}
+
+func TestKeyedLimiterMessageField(t *testing.T) {
+	var buf bytes.Buffer
+	limiter := &KeyedLimiter{
+		Interval: time.Hour,
+		Writer:   &buf,
+	}
+
+	for _, w := range []io.Writer{limiter, struct{ io.Writer }{limiter}} {
+		buf.Reset()
+		logger := Logger{Writer: w, MessageField: "msg"}
+		logger.Info().Msg("one")
+		logger.Info().Msg("two")
+		if s := buf.String(); !strings.Contains(s, `"msg":"one"`) || !strings.Contains(s, `"msg":"two"`) {
+			t.Errorf("limiter with MessageField should key the events by the messages, got %s", s)
+		}
+	}
+
+	buf.Reset()
+	logger := Logger{Writer: limiter, MessageField: "msg"}
+	logger.Info().Msg("three")
+	logger.Info().Msg("three")
+	if s := buf.String(); strings.Count(s, "\n") != 1 {
+		t.Errorf("limiter with MessageField should suppress the same messages, got %s", s)
+	}
+}
+
+func TestKeyedLimiterKey(t *testing.T) {
+	var buf bytes.Buffer
+	limiter := &KeyedLimiter{
+		Key: func(level Level, p []byte) string {
+			if bytes.Contains(p, []byte(`"user":"bob"`)) {
+				return "bob"
+			}
+			return "other"
+		},
+		Interval: time.Hour,
+		Capacity: 1,
+		Writer:   &buf,
+	}
+	logger := Logger{Writer: limiter}
+
+	logger.Info().Str("user", "bob").Msg("a")
+	logger.Info().Str("user", "bob").Msg("b")
+	logger.Info().Str("user", "alice").Msg("c")
+	// the capacity is 1, so the key "bob" has been evicted.
+	logger.Info().Str("user", "bob").Msg("d")
+	if s := buf.String(); strings.Count(s, "\n") != 3 || strings.Contains(s, `"message":"b"`) {
+		t.Errorf("unexpected limiter output %s", s)
+	}
+	if len(limiter.keys) != 1 || limiter.list.Len() != 1 {
+		t.Errorf("limiter should track at most 1 key, got %d", len(limiter.keys))
+	}
+}
+
+func TestMessageOf(t *testing.T) {
+	cases := []struct {
+		JSON    string
+		Message string
+	}{
+		{`{"level":"info"}`, ``},
+		{`{"level":"info","message":"hello"}`, `hello`},
+		{`{"level":"info","message":"a \"quoted\" message"}`, `a \"quoted\" message`},
+		{`{"level":"info","raw":{"message":"inner"},"message":"outer"}`, `outer`},
+	}
+
+	for _, c := range cases {
+		if got := string(messageOf([]byte(c.JSON + "\n"))); got != c.Message {
+			t.Errorf("messageOf(%s) got %q, want %q", c.JSON, got, c.Message)
+		}
+	}
+}
+
+func BenchmarkKeyedLimiter(b *testing.B) {
+	limiter := &KeyedLimiter{
+		Interval: time.Second,
+		Writer:   ioutil.Discard,
+	}
+	logger := Logger{
+		Timestamp: true,
+		Writer:    limiter,
+	}
+	messages := make([]string, 64)
+	for i := range messages {
+		messages[i] = fmt.Sprintf("message %d", i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			logger.Warn().Str("foo", "bar").Msg(messages[i%len(messages)])
+			i++
+		}
+	})
+}
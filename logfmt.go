@@ -0,0 +1,142 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// LogfmtWriter is an io.WriteCloser and LevelWriter that transcodes the JSON lines
+// to logfmt lines in the order of fields, e.g.
+//
+//	time=2019-07-10T05:35:54.277Z level=info msg="hello world" foo=bar n=42
+//
+// The "message" field is renamed to msg, a value with spaces, quotes, '=' or control
+// characters is quoted, and an object or array value is written as quoted JSON.
+// A line which is not a JSON object is written as is.
+type LogfmtWriter struct {
+	// Writer specifies the writer of output. It uses os.Stderr in if empty.
+	Writer io.Writer
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write implements io.Writer.
+func (w *LogfmtWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(NoLevel, p)
+}
+
+// WriteLevel implements LevelWriter, level is passed to Writer if it is a LevelWriter.
+func (w *LogfmtWriter) WriteLevel(level Level, p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var ok bool
+	if w.buf, ok = appendLogfmt(w.buf[:0], p); !ok {
+		w.buf = append(w.buf[:0], p...)
+	}
+	if _, err = writeLevel(w.writer(), level, w.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer, and closes Writer if it is an io.Closer.
+func (w *LogfmtWriter) Close() (err error) {
+	return closeWriter(w.writer())
+}
+
+// Describe implements WriterDescriber.
+func (w *LogfmtWriter) Describe() string {
+	return "LogfmtWriter -> " + describeWriter(w.Writer)
+}
+
+func (w *LogfmtWriter) writer() io.Writer {
+	if w.Writer != nil {
+		return w.Writer
+	}
+	return os.Stderr
+}
+
+// appendLogfmt appends the logfmt line of the JSON object p to dst, it returns false
+// if p is not a JSON object.
+func appendLogfmt(dst, p []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	if t, err := decoder.Token(); err != nil || t != json.Delim('{') {
+		return dst, false
+	}
+
+	var raw json.RawMessage
+	for n := 0; decoder.More(); n++ {
+		t, err := decoder.Token()
+		if err != nil {
+			return dst, false
+		}
+		key, _ := t.(string)
+		if err = decoder.Decode(&raw); err != nil {
+			return dst, false
+		}
+
+		if n != 0 {
+			dst = append(dst, ' ')
+		}
+		if key == "message" {
+			key = "msg"
+		}
+		dst = appendLogfmtKey(dst, key)
+		dst = append(dst, '=')
+
+		switch raw[0] {
+		case '"':
+			var s string
+			json.Unmarshal(raw, &s)
+			dst = appendLogfmtValue(dst, s)
+		case '{', '[':
+			var b bytes.Buffer
+			json.Compact(&b, raw)
+			dst = strconv.AppendQuote(dst, b.String())
+		default:
+			dst = append(dst, raw...)
+		}
+	}
+
+	return append(dst, '\n'), true
+}
+
+// appendLogfmtKey appends key to dst, the characters invalid in a logfmt key are
+// replaced by '_'.
+func appendLogfmtKey(dst []byte, key string) []byte {
+	if key == "" {
+		return append(dst, '_')
+	}
+	for i := 0; i < len(key); i++ {
+		if c := key[i]; c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+			dst = append(dst, '_')
+		} else {
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+// appendLogfmtValue appends s to dst, it is quoted if it is empty or contains
+// spaces, quotes, '=', control characters or invalid UTF-8.
+func appendLogfmtValue(dst []byte, s string) []byte {
+	if s == "" {
+		return append(dst, '"', '"')
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c == '=' || c == '"' || c == '\\' || c == 0x7f {
+			return strconv.AppendQuote(dst, s)
+		}
+	}
+	if !utf8.ValidString(s) {
+		return strconv.AppendQuote(dst, s)
+	}
+	return append(dst, s...)
+}
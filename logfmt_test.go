@@ -0,0 +1,68 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLogfmtWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &LogfmtWriter{Writer: &buf}
+
+	cases := []struct {
+		JSON   string
+		Logfmt string
+	}{
+		{
+			`{"time":"2019-07-10T05:35:54.277Z","level":"info","foo":"bar","n":42,"ok":true,"nil":null,"message":"hello world"}`,
+			`time=2019-07-10T05:35:54.277Z level=info foo=bar n=42 ok=true nil=null msg="hello world"`,
+		},
+		{
+			`{"empty":"","eq":"a=b","quote":"say \"hi\"","nl":"a\nb","unicode":"héllo"}`,
+			`empty="" eq="a=b" quote="say \"hi\"" nl="a\nb" unicode=héllo`,
+		},
+		{
+			`{"obj":{"a": [1, 2]},"arr":["x"],"my key":1}`,
+			`obj="{\"a\":[1,2]}" arr="[\"x\"]" my_key=1`,
+		},
+		{
+			`not a json line`,
+			`not a json line`,
+		},
+	}
+
+	for _, c := range cases {
+		buf.Reset()
+		if _, err := w.Write([]byte(c.JSON + "\n")); err != nil {
+			t.Errorf("logfmt writer error: %+v", err)
+		}
+		if got := strings.TrimSuffix(buf.String(), "\n"); got != c.Logfmt {
+			t.Errorf("logfmt writer got %s, want %s", got, c.Logfmt)
+		}
+	}
+}
+
+func TestLogfmtWriterLogger(t *testing.T) {
+	var lw levelWriter
+	logger := Logger{
+		TimeFormat: "2006",
+		Writer:     &LogfmtWriter{Writer: &lw},
+	}
+	logger.Warn().Str("foo", "bar baz").Interface("obj", map[string]int{"a": 1}).Msg("hello logfmt")
+
+	if len(lw.levels) != 1 || lw.levels[0] != WarnLevel {
+		t.Errorf("logfmt writer should pass the level, got %v", lw.levels)
+	}
+	if want := ` level=warn foo="bar baz" obj="{\"a\":1}" msg="hello logfmt"` + "\n"; !strings.HasSuffix(lw.lines[0], want) {
+		t.Errorf("logfmt writer got %s, want %s", lw.lines[0], want)
+	}
+
+	// ConsoleWriter passes the logfmt lines through.
+	var out bytes.Buffer
+	cw := &ConsoleWriter{Out: &out}
+	cw.Write([]byte(lw.lines[0]))
+	if out.String() != lw.lines[0] {
+		t.Errorf("console writer should pass the logfmt line through, got %s", out.String())
+	}
+}
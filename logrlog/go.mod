@@ -0,0 +1,10 @@
+module github.com/phuslu/log/logrlog
+
+go 1.21
+
+require (
+	github.com/go-logr/logr v1.4.1
+	github.com/phuslu/log v0.0.0-00010101000000-000000000000
+)
+
+replace github.com/phuslu/log => ../
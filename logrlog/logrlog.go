@@ -0,0 +1,160 @@
+// Package logrlog provides a logr.LogSink implemented by github.com/phuslu/log.
+//
+//	logger := logr.New(logrlog.NewLogrSink(&log.DefaultLogger))
+package logrlog
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/phuslu/log"
+)
+
+// NewLogrSink returns a logr.LogSink which writes the events by l. The V-level 0 is
+// logged with info level, 1 with debug level and others with trace level.
+func NewLogrSink(l *log.Logger) logr.LogSink {
+	if l == nil {
+		l = &log.DefaultLogger
+	}
+	s := &LogSink{logger: l}
+	s.setCaller()
+	return s
+}
+
+// LogSink implements logr.LogSink and logr.CallDepthLogSink. The name of the logger is
+// added as the "logger" field and the values of WithValues are bound to a sub logger.
+//
+// If the logger has Caller, the logger skipping the frames of logr is cloned once by
+// Init and WithCallDepth, the level of events is still checked by the wrapped logger.
+type LogSink struct {
+	logger *log.Logger
+	caller *log.Logger
+	name   string
+	depth  int
+}
+
+// Init implements logr.LogSink.
+func (s *LogSink) Init(info logr.RuntimeInfo) {
+	s.depth = info.CallDepth + 1
+	s.setCaller()
+}
+
+// Enabled implements logr.LogSink.
+func (s *LogSink) Enabled(level int) bool {
+	return s.logger.GetLevel() <= vlevel(level)
+}
+
+// Info implements logr.LogSink.
+func (s *LogSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	l := s.sink(vlevel(level))
+	if l == nil {
+		return
+	}
+	e := l.WithLevel(vlevel(level))
+	if e == nil {
+		return
+	}
+	s.event(e, keysAndValues).Msg(msg)
+}
+
+// Error implements logr.LogSink, err is added by Event.Err as the native usage.
+func (s *LogSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	l := s.sink(log.ErrorLevel)
+	if l == nil {
+		return
+	}
+	e := l.Error()
+	if e == nil {
+		return
+	}
+	s.event(e.Err(err), keysAndValues).Msg(msg)
+}
+
+// WithValues implements logr.LogSink.
+func (s *LogSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	c := *s
+	c.logger = s.logger.With().Fields(fields(keysAndValues)).Logger()
+	c.setCaller()
+	return &c
+}
+
+// WithName implements logr.LogSink, the names are joined by "/".
+func (s *LogSink) WithName(name string) logr.LogSink {
+	c := *s
+	if c.name == "" {
+		c.name = name
+	} else {
+		c.name += "/" + name
+	}
+	return &c
+}
+
+// WithCallDepth implements logr.CallDepthLogSink.
+func (s *LogSink) WithCallDepth(depth int) logr.LogSink {
+	c := *s
+	c.depth += depth
+	c.setCaller()
+	return &c
+}
+
+// setCaller clones the logger skipping the frames of logr if the logger has Caller,
+// the clone logs at any level as the level is checked by sink.
+func (s *LogSink) setCaller() {
+	s.caller = nil
+	if s.logger.Caller > 0 {
+		s.caller = s.logger.Clone(func(c *log.Logger) {
+			c.Level = log.TraceLevel
+			c.CallerSkipFrames += s.depth
+		})
+	}
+}
+
+// sink returns the logger of the events at level, or nil if level is not enabled.
+func (s *LogSink) sink(level log.Level) *log.Logger {
+	if s.logger.GetLevel() > level {
+		return nil
+	}
+	if s.caller != nil {
+		return s.caller
+	}
+	return s.logger
+}
+
+func (s *LogSink) event(e *log.Event, keysAndValues []interface{}) *log.Event {
+	if s.name != "" {
+		e = e.Str("logger", s.name)
+	}
+	return e.Fields(fields(keysAndValues))
+}
+
+func vlevel(level int) log.Level {
+	switch {
+	case level <= 0:
+		return log.InfoLevel
+	case level == 1:
+		return log.DebugLevel
+	default:
+		return log.TraceLevel
+	}
+}
+
+// fields converts the key/value pairs of logr to the fields of Event.Fields, a key
+// without value is added with nil.
+func fields(keysAndValues []interface{}) map[string]interface{} {
+	if len(keysAndValues) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		var value interface{}
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		m[key] = value
+	}
+	return m
+}
@@ -0,0 +1,90 @@
+package logrlog
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/phuslu/log"
+)
+
+func TestLogSink(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logr.New(NewLogrSink(&log.Logger{
+		Level:  log.DebugLevel,
+		Writer: &buf,
+	}))
+
+	cases := []struct {
+		Log  func()
+		JSON string
+	}{
+		{
+			func() { logger.Info("hello", "foo", "bar", "n", 42) },
+			`"level":"info","foo":"bar","n":42,"message":"hello"}`,
+		},
+		{
+			func() { logger.V(1).Info("hello") },
+			`"level":"debug","message":"hello"}`,
+		},
+		{
+			func() { logger.V(2).Info("hello") },
+			``,
+		},
+		{
+			func() { logger.Error(errors.New("boom"), "failed", "id", 1) },
+			`"level":"error","error":"boom","id":1,"message":"failed"}`,
+		},
+		{
+			func() { logger.WithName("a").WithName("b").WithValues("k", "v").Info("hello", "odd") },
+			`"level":"info","k":"v","logger":"a/b","odd":null,"message":"hello"}`,
+		},
+	}
+
+	for _, c := range cases {
+		buf.Reset()
+		c.Log()
+		if got := buf.String(); c.JSON == "" && got != "" || !strings.HasSuffix(got, c.JSON+"\n") && c.JSON != "" {
+			t.Errorf("logrlog output %s, want %s", got, c.JSON)
+		}
+	}
+
+	if logger.V(2).Enabled() {
+		t.Errorf("logrlog V(2) should not be enabled at debug level")
+	}
+}
+
+func TestLogSinkCaller(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logr.New(NewLogrSink(&log.Logger{
+		Caller: 1,
+		Writer: &buf,
+	}))
+
+	logger.Info("hello")
+	if !strings.Contains(buf.String(), `"caller":"logrlog_test.go:`) {
+		t.Errorf("logrlog caller output %s", buf.String())
+	}
+}
+
+func TestLogSinkCallerSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := &log.Logger{
+		Level:  log.InfoLevel,
+		Caller: 1,
+		Writer: &buf,
+	}
+	logger := logr.New(NewLogrSink(l))
+
+	logger.V(1).Info("hidden")
+	l.SetLevel(log.DebugLevel)
+	logger.V(1).Info("shown")
+	l.SetLevel(log.ErrorLevel + 1)
+	logger.Error(errors.New("boom"), "hidden")
+
+	if got := buf.String(); strings.Count(got, "\n") != 1 || !strings.Contains(got, `"caller":"logrlog_test.go:`) || !strings.Contains(got, `"message":"shown"`) {
+		t.Errorf("logrlog caller output %s should follow SetLevel", got)
+	}
+}
@@ -0,0 +1,123 @@
+package log
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// MinLevelWriter is an io.Writer and LevelWriter that writes the events of level
+// greater than or equal to Level to Writer and discards the others, e.g.
+//
+//	&log.MinLevelWriter{Level: log.ErrorLevel, Writer: os.Stderr}
+//
+// The events of NoLevel are always written.
+type MinLevelWriter struct {
+	// Level specifies the minimum level of the events written to Writer.
+	Level Level
+
+	// Writer specifies the writer of output. It uses os.Stderr in if empty.
+	Writer io.Writer
+}
+
+// Write implements io.Writer, the level is parsed from the level field of p.
+func (w *MinLevelWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(levelOf(p), p)
+}
+
+// WriteLevel implements LevelWriter, level is passed to Writer if it is a LevelWriter.
+func (w *MinLevelWriter) WriteLevel(level Level, p []byte) (n int, err error) {
+	if level < w.Level {
+		return len(p), nil
+	}
+	return writeLevel(w.writer(), level, p)
+}
+
+// Close implements io.Closer, and closes Writer if it is an io.Closer.
+func (w *MinLevelWriter) Close() (err error) {
+	return closeWriter(w.writer())
+}
+
+// Describe implements WriterDescriber.
+func (w *MinLevelWriter) Describe() string {
+	return "MinLevelWriter{Level:" + w.Level.String() + "} -> " + describeWriter(w.Writer)
+}
+
+func (w *MinLevelWriter) writer() io.Writer {
+	if w.Writer != nil {
+		return w.Writer
+	}
+	return os.Stderr
+}
+
+// MultiLevelWriter is an io.WriteCloser and LevelWriter that writes an event to
+// each of Writers in order, the level of the event is passed to the writers which
+// implement LevelWriter, so a writer wrapped by MinLevelWriter receives only the
+// events of its level, e.g.
+//
+//	log.DefaultLogger.Writer = &log.MultiLevelWriter{
+//		Writers: []io.Writer{
+//			&log.MinLevelWriter{Level: log.ErrorLevel, Writer: os.Stderr},
+//			&log.FileWriter{Filename: "main.log"},
+//		},
+//	}
+//
+// A failed write does not prevent the writes to the other writers, the first error
+// is returned.
+type MultiLevelWriter struct {
+	// Writers specifies the writers of output.
+	Writers []io.Writer
+}
+
+// Write implements io.Writer, the level is parsed from the level field of p.
+func (w *MultiLevelWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(levelOf(p), p)
+}
+
+// WriteLevel implements LevelWriter.
+func (w *MultiLevelWriter) WriteLevel(level Level, p []byte) (n int, err error) {
+	for _, writer := range w.Writers {
+		if _, err1 := writeLevel(writer, level, p); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	return len(p), err
+}
+
+// Close implements io.Closer, and closes the writers which are io.Closer.
+func (w *MultiLevelWriter) Close() (err error) {
+	for _, writer := range w.Writers {
+		if err1 := closeWriter(writer); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	return
+}
+
+// Describe implements WriterDescriber.
+func (w *MultiLevelWriter) Describe() string {
+	names := make([]string, len(w.Writers))
+	for i, writer := range w.Writers {
+		names[i] = describeWriter(writer)
+	}
+	return "MultiLevelWriter{" + strings.Join(names, ", ") + "}"
+}
+
+func writeLevel(w io.Writer, level Level, p []byte) (n int, err error) {
+	if lw, ok := w.(LevelWriter); ok && level != NoLevel {
+		n, err = lw.WriteLevel(level, p)
+	} else {
+		n, err = w.Write(p)
+	}
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return
+}
+
+func closeWriter(w io.Writer) error {
+	if closer, ok := w.(io.Closer); ok && closer != io.Closer(os.Stderr) && closer != io.Closer(os.Stdout) {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMultiLevelWriter(t *testing.T) {
+	var stderr, file bytes.Buffer
+	w := &MultiLevelWriter{
+		Writers: []io.Writer{
+			&MinLevelWriter{Level: ErrorLevel, Writer: &stderr},
+			&file,
+		},
+	}
+
+	logger := Logger{Level: DebugLevel, Writer: w}
+	logger.Debug().Msg("hello debug")
+	logger.Error().Msg("hello error")
+	logger.WithLevel(NoLevel).Msg("hello nolevel")
+
+	if s := stderr.String(); strings.Contains(s, "hello debug") || !strings.Contains(s, "hello error") || !strings.Contains(s, "hello nolevel") {
+		t.Errorf("min level writer got unexpected output: %s", s)
+	}
+	want := 3
+	if nodebug {
+		want = 2
+	}
+	if n := strings.Count(file.String(), "\n"); n != want {
+		t.Errorf("multi level writer got %d lines, want %d", n, want)
+	}
+
+	stderr.Reset()
+	w.Write([]byte(`{"level":"info","message":"hello write"}` + "\n"))
+	w.Write([]byte(`{"level":"error","message":"hello write"}` + "\n"))
+	if n := strings.Count(stderr.String(), "\n"); n != 1 {
+		t.Errorf("min level writer should parse the level of Write, got %d lines", n)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("multi level writer close error: %+v", err)
+	}
+}
+
+func TestMultiLevelWriterError(t *testing.T) {
+	var buf bytes.Buffer
+	w := &MultiLevelWriter{
+		Writers: []io.Writer{
+			&errorWriter{n: -1, err: errors.New("disk full")},
+			&errorWriter{n: 1},
+			&buf,
+		},
+	}
+
+	var errs []error
+	logger := Logger{Writer: w, ErrorHandler: func(err error) { errs = append(errs, err) }}
+	logger.Info().Msg("hello partial")
+
+	if !strings.Contains(buf.String(), "hello partial") {
+		t.Errorf("multi level writer should write to the other writers after an error")
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "disk full") {
+		t.Errorf("multi level writer should return the first error, got %v", errs)
+	}
+
+	w = &MultiLevelWriter{Writers: []io.Writer{&errorWriter{n: 1}}}
+	if _, err := w.Write([]byte("hello")); err != io.ErrShortWrite {
+		t.Errorf("multi level writer should return io.ErrShortWrite, got %v", err)
+	}
+}
+
+func TestMultiLevelWriterDescribe(t *testing.T) {
+	w := &MultiLevelWriter{
+		Writers: []io.Writer{
+			&MinLevelWriter{Level: ErrorLevel},
+			&FileWriter{Filename: "main.log"},
+		},
+	}
+	if s := w.Describe(); !strings.HasPrefix(s, "MultiLevelWriter{MinLevelWriter{Level:error} -> os.Stderr, FileWriter{") {
+		t.Errorf("multi level writer describe got %s", s)
+	}
+}
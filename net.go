@@ -0,0 +1,292 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NetWriter is an io.WriteCloser that writes the JSON lines to a network connection,
+// e.g. a log collector, each line is written to the connection by one write.
+//
+// The connection is dialed by a background goroutine. While it is disconnected, the
+// lines are kept in a buffer of BufferSize bytes and the oldest lines are dropped if
+// it is full, the connection is redialed with an exponential backoff and the buffered
+// lines are written in order after it is reconnected.
+//
+// Each write has a deadline of WriteTimeout. A line written partially is dropped
+// with the connection rather than written again, so the collector never receives
+// a line twice.
+type NetWriter struct {
+	// make aligncheck happy
+	dropped uint64
+
+	// Network specifies the network of the connection, e.g. "tcp", "udp" or "unix". It uses "tcp" in if empty.
+	Network string
+
+	// Address specifies the address of the connection.
+	Address string
+
+	// DialTimeout specifies the timeout of dialing. It uses 5s in if zero.
+	DialTimeout time.Duration
+
+	// WriteTimeout specifies the timeout of a write. It uses 5s in if zero.
+	WriteTimeout time.Duration
+
+	// BufferSize specifies the maximum size in bytes of the lines buffered while
+	// disconnected. It uses 1MB in if zero.
+	BufferSize int
+
+	// CloseTimeout specifies the timeout of Close to write the buffered lines. It uses 5s in if zero.
+	CloseTimeout time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	pending [][]byte
+	size    int
+	dialing bool
+	closed  bool
+	done    chan struct{}
+}
+
+const (
+	netMinBackoff = 100 * time.Millisecond
+	netMaxBackoff = 30 * time.Second
+)
+
+// Write implements io.Writer. It returns the error of the connection after which
+// p is buffered and the connection is redialed.
+func (w *NetWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errors.New("log: NetWriter is closed")
+	}
+
+	if w.conn != nil {
+		if n, err = w.write(w.conn, p, time.Time{}); err == nil {
+			return len(p), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+		if n > 0 {
+			atomic.AddUint64(&w.dropped, 1)
+		} else {
+			w.buffer(p)
+		}
+		err = fmt.Errorf("log: NetWriter write error: %v", err)
+	} else {
+		w.buffer(p)
+	}
+
+	if !w.dialing {
+		w.dialing = true
+		if w.done == nil {
+			w.done = make(chan struct{})
+		}
+		go w.reconnect(w.done)
+	}
+
+	return len(p), err
+}
+
+// Dropped returns the number of lines dropped while disconnected.
+func (w *NetWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Close implements io.Closer, it writes the buffered lines within CloseTimeout
+// and closes the connection. The lines failed to write are counted as dropped.
+func (w *NetWriter) Close() (err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.done != nil {
+		close(w.done)
+	}
+
+	timeout := w.CloseTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+
+	if w.conn == nil && len(w.pending) > 0 {
+		network, address, _ := w.config()
+		conn, err := net.DialTimeout(network, address, timeout)
+		if err != nil {
+			atomic.AddUint64(&w.dropped, uint64(len(w.pending)))
+			w.pending = nil
+			return fmt.Errorf("log: NetWriter dial error: %v", err)
+		}
+		w.conn = conn
+	}
+
+	if w.conn != nil {
+		w.pending, err = w.flush(w.conn, w.pending, deadline)
+		if err1 := w.conn.Close(); err == nil {
+			err = err1
+		}
+		w.conn = nil
+	}
+	if len(w.pending) > 0 {
+		atomic.AddUint64(&w.dropped, uint64(len(w.pending)))
+		w.pending = nil
+	}
+
+	return
+}
+
+// Describe implements WriterDescriber.
+func (w *NetWriter) Describe() string {
+	network, address, _ := w.config()
+	return fmt.Sprintf("NetWriter{Network:%s Address:%s}", network, address)
+}
+
+func (w *NetWriter) config() (network, address string, timeout time.Duration) {
+	network, address, timeout = w.Network, w.Address, w.DialTimeout
+	if network == "" {
+		network = "tcp"
+	}
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	return
+}
+
+// reconnect dials the connection until it succeeds or the writer is closed, and
+// hands the connection over to Write after writing the buffered lines.
+func (w *NetWriter) reconnect(done chan struct{}) {
+	network, address, timeout := w.config()
+	backoff := netMinBackoff
+	for {
+		conn, err := net.DialTimeout(network, address, timeout)
+		if err == nil {
+			var closed bool
+			if closed, err = w.handoff(conn); closed || err == nil {
+				return
+			}
+			conn.Close()
+		} else {
+			w.mu.Lock()
+			closed := w.closed
+			w.mu.Unlock()
+			if closed {
+				return
+			}
+		}
+
+		if ErrorHandler != nil {
+			ErrorHandler(fmt.Errorf("log: NetWriter reconnect error: %v", err))
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-done:
+			return
+		}
+		if backoff *= 2; backoff > netMaxBackoff {
+			backoff = netMaxBackoff
+		}
+	}
+}
+
+// handoff writes the buffered lines to conn without holding mu, so that Write keeps
+// buffering meanwhile, and sets conn as the connection once the buffer is empty.
+// The lines not written are put back to the buffer on error.
+func (w *NetWriter) handoff(conn net.Conn) (closed bool, err error) {
+	for {
+		w.mu.Lock()
+		if w.closed {
+			w.mu.Unlock()
+			conn.Close()
+			return true, nil
+		}
+		if len(w.pending) == 0 {
+			w.conn = conn
+			w.dialing = false
+			w.mu.Unlock()
+			return false, nil
+		}
+		lines := w.pending
+		w.pending, w.size = nil, 0
+		w.mu.Unlock()
+
+		if lines, err = w.flush(conn, lines, time.Time{}); err != nil {
+			w.mu.Lock()
+			w.requeue(lines)
+			w.mu.Unlock()
+			return false, err
+		}
+	}
+}
+
+// write writes p to conn by the deadline, it uses WriteTimeout from now if the deadline is zero.
+func (w *NetWriter) write(conn net.Conn, p []byte, deadline time.Time) (int, error) {
+	if deadline.IsZero() {
+		timeout := w.WriteTimeout
+		if timeout == 0 {
+			timeout = 5 * time.Second
+		}
+		deadline = time.Now().Add(timeout)
+	}
+	conn.SetWriteDeadline(deadline)
+	return conn.Write(p)
+}
+
+// flush writes the lines to conn and returns the lines not written, the line written
+// partially is counted as dropped and not returned.
+func (w *NetWriter) flush(conn net.Conn, lines [][]byte, deadline time.Time) ([][]byte, error) {
+	for i, p := range lines {
+		if n, err := w.write(conn, p, deadline); err != nil {
+			if n > 0 {
+				atomic.AddUint64(&w.dropped, 1)
+				i++
+			}
+			return lines[i:], err
+		}
+	}
+	return nil, nil
+}
+
+// requeue puts the lines back in front of the buffer, the oldest lines are dropped
+// if it is full or the writer is closed. It must be called with mu held.
+func (w *NetWriter) requeue(lines [][]byte) {
+	if w.closed {
+		atomic.AddUint64(&w.dropped, uint64(len(lines)))
+		return
+	}
+	pending := w.pending
+	w.pending, w.size = nil, 0
+	for _, p := range append(lines, pending...) {
+		w.buffer(p)
+	}
+}
+
+// buffer copies p into the buffer, the oldest lines are dropped to make room for p.
+func (w *NetWriter) buffer(p []byte) {
+	max := w.BufferSize
+	if max == 0 {
+		max = 1024 * 1024
+	}
+	if len(p) > max {
+		atomic.AddUint64(&w.dropped, 1)
+		return
+	}
+	for w.size+len(p) > max {
+		w.size -= len(w.pending[0])
+		w.pending[0] = nil
+		w.pending = w.pending[1:]
+		atomic.AddUint64(&w.dropped, 1)
+	}
+	w.pending = append(w.pending, append([]byte(nil), p...))
+	w.size += len(p)
+}
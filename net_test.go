@@ -0,0 +1,173 @@
+package log
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func acceptLines(ln net.Listener) chan string {
+	lines := make(chan string, 64)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					lines <- line
+				}
+			}()
+		}
+	}()
+	return lines
+}
+
+func expectLines(t *testing.T, lines chan string, msgs ...string) {
+	for _, msg := range msgs {
+		select {
+		case line := <-lines:
+			if !strings.Contains(line, msg) {
+				t.Errorf("net writer got %q, want %s", line, msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("net writer timed out waiting for %s", msg)
+		}
+	}
+}
+
+func TestNetWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen tcp error: %+v", err)
+	}
+	defer ln.Close()
+	lines := acceptLines(ln)
+
+	w := &NetWriter{Address: ln.Addr().String()}
+	logger := Logger{Writer: w}
+	for i := 0; i < 10; i++ {
+		logger.Info().Int("seq", i).Msg("hello net")
+	}
+
+	msgs := make([]string, 10)
+	for i := range msgs {
+		msgs[i] = `"seq":` + strconv.Itoa(i) + `,`
+	}
+	expectLines(t, lines, msgs...)
+
+	if err := w.Close(); err != nil {
+		t.Errorf("net writer close error: %+v", err)
+	}
+	if _, err := w.Write([]byte("line\n")); err == nil {
+		t.Errorf("net writer should fail after close")
+	}
+}
+
+func TestNetWriterReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen tcp error: %+v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	defer func(h func(error)) { ErrorHandler = h }(ErrorHandler)
+	ErrorHandler = nil
+
+	w := &NetWriter{Address: addr, BufferSize: 16}
+	defer w.Close()
+
+	for i := 0; i < 3; i++ {
+		w.Write([]byte("line " + strconv.Itoa(i) + "\n"))
+	}
+	if n := w.Dropped(); n != 1 {
+		t.Errorf("net writer dropped %d lines, want 1", n)
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("listen tcp error: %+v", err)
+	}
+	defer ln.Close()
+	lines := acceptLines(ln)
+
+	expectLines(t, lines, "line 1", "line 2")
+
+	w.Write([]byte("line 3\n"))
+	expectLines(t, lines, "line 3")
+}
+
+func TestNetWriterClose(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen tcp error: %+v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	defer func(h func(error)) { ErrorHandler = h }(ErrorHandler)
+	ErrorHandler = nil
+
+	w := &NetWriter{Address: addr}
+	w.Write([]byte("line 0\n"))
+	w.Write([]byte("line 1\n"))
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("listen tcp error: %+v", err)
+	}
+	defer ln.Close()
+	lines := acceptLines(ln)
+
+	if err := w.Close(); err != nil {
+		t.Errorf("net writer close error: %+v", err)
+	}
+	expectLines(t, lines, "line 0", "line 1")
+	if n := w.Dropped(); n != 0 {
+		t.Errorf("net writer dropped %d lines, want 0", n)
+	}
+}
+
+func TestNetWriterWriteTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen tcp error: %+v", err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			defer c.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	defer func(h func(error)) { ErrorHandler = h }(ErrorHandler)
+	ErrorHandler = nil
+
+	w := &NetWriter{Address: ln.Addr().String(), WriteTimeout: 50 * time.Millisecond, CloseTimeout: 50 * time.Millisecond}
+	defer w.Close()
+
+	line := []byte(strings.Repeat("x", 1024*1024-1) + "\n")
+	for start := time.Now(); err == nil && time.Since(start) < 3*time.Second; {
+		begin := time.Now()
+		if _, err = w.Write(line); time.Since(begin) > time.Second {
+			t.Fatalf("net writer took %s to write a line", time.Since(begin))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err == nil {
+		t.Fatalf("net writer should time out on a stalled connection")
+	}
+}
@@ -0,0 +1,58 @@
+// +build go1.18
+
+package log
+
+import (
+	"net/netip"
+)
+
+// NetipAddr adds the field key with a in its canonical textual form, or null if a is
+// the zero Addr.
+func (e *Event) NetipAddr(key string, a netip.Addr) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	if !a.IsValid() {
+		e.buf = append(e.buf, "null"...)
+		return e
+	}
+	e.buf = append(e.buf, '"')
+	e.buf = a.AppendTo(e.buf)
+	e.buf = append(e.buf, '"')
+	return e
+}
+
+// NetipAddrPort adds the field key with ap in its canonical textual form, or null if
+// the address of ap is invalid.
+func (e *Event) NetipAddrPort(key string, ap netip.AddrPort) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	if !ap.IsValid() {
+		e.buf = append(e.buf, "null"...)
+		return e
+	}
+	e.buf = append(e.buf, '"')
+	e.buf = ap.AppendTo(e.buf)
+	e.buf = append(e.buf, '"')
+	return e
+}
+
+// NetipPrefix adds the field key with p in its canonical textual form, or null if p
+// is invalid.
+func (e *Event) NetipPrefix(key string, p netip.Prefix) *Event {
+	if e == nil {
+		return nil
+	}
+	e.key(key)
+	if !p.IsValid() {
+		e.buf = append(e.buf, "null"...)
+		return e
+	}
+	e.buf = append(e.buf, '"')
+	e.buf = p.AppendTo(e.buf)
+	e.buf = append(e.buf, '"')
+	return e
+}
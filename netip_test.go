@@ -0,0 +1,45 @@
+// +build go1.18
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestLoggerNetip(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+
+	logger.Info().
+		NetipAddr("ip4", netip.MustParseAddr("192.0.2.1")).
+		NetipAddr("ip6", netip.MustParseAddr("2001:db8::1")).
+		NetipAddr("zone", netip.MustParseAddr("fe80::1%eth0")).
+		NetipAddr("zero", netip.Addr{}).
+		NetipAddrPort("addrport", netip.MustParseAddrPort("[2001:db8::1]:443")).
+		NetipAddrPort("zero_addrport", netip.AddrPort{}).
+		NetipPrefix("prefix", netip.MustParsePrefix("10.0.0.0/8")).
+		NetipPrefix("zero_prefix", netip.Prefix{}).
+		Msg("")
+
+	want := `"ip4":"192.0.2.1","ip6":"2001:db8::1","zone":"fe80::1%eth0","zero":null,"addrport":"[2001:db8::1]:443","zero_addrport":null,"prefix":"10.0.0.0/8","zero_prefix":null}`
+	if s := buf.String(); !strings.HasSuffix(s, want+"\n") {
+		t.Errorf("netip output %s, want %s", s, want)
+	}
+}
+
+func BenchmarkNetip(b *testing.B) {
+	logger := Logger{Writer: ioutil.Discard}
+	addr := netip.MustParseAddr("2001:db8::1")
+	addrport := netip.MustParseAddrPort("192.0.2.1:443")
+	prefix := netip.MustParsePrefix("10.0.0.0/8")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info().NetipAddr("addr", addr).NetipAddrPort("addrport", addrport).NetipPrefix("prefix", prefix).Msg("")
+	}
+}
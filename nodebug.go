@@ -0,0 +1,26 @@
+// +build log_nodebug
+
+package log
+
+// nodebug reports whether the trace and debug events are eliminated by the log_nodebug build tag.
+const nodebug = true
+
+// Trace returns nil under the log_nodebug build tag, so the compiler eliminates the call site.
+func Trace() (e *Event) {
+	return nil
+}
+
+// Debug returns nil under the log_nodebug build tag, so the compiler eliminates the call site.
+func Debug() (e *Event) {
+	return nil
+}
+
+// Trace returns nil under the log_nodebug build tag, so the compiler eliminates the call site.
+func (l *Logger) Trace() (e *Event) {
+	return nil
+}
+
+// Debug returns nil under the log_nodebug build tag, so the compiler eliminates the call site.
+func (l *Logger) Debug() (e *Event) {
+	return nil
+}
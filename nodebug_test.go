@@ -0,0 +1,26 @@
+// +build log_nodebug
+
+package log
+
+import (
+	"testing"
+)
+
+func TestNoDebug(t *testing.T) {
+	logger := Logger{Level: TraceLevel}
+
+	if e := logger.Trace(); e != nil {
+		t.Errorf("Logger.Trace should return nil under log_nodebug")
+	}
+	if e := logger.Debug(); e != nil {
+		t.Errorf("Logger.Debug should return nil under log_nodebug")
+	}
+	if e := Trace(); e != nil {
+		t.Errorf("Trace should return nil under log_nodebug")
+	}
+	if e := Debug(); e != nil {
+		t.Errorf("Debug should return nil under log_nodebug")
+	}
+
+	logger.Debug().Str("foo", "bar").Msg("eliminated")
+}
@@ -0,0 +1,12 @@
+module github.com/phuslu/log/otellog
+
+go 1.21
+
+require (
+	github.com/phuslu/log v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require go.opentelemetry.io/otel v1.24.0 // indirect
+
+replace github.com/phuslu/log => ../
@@ -0,0 +1,24 @@
+// Package otellog provides a log.TraceExtractor which reads the OpenTelemetry span
+// context, so Event.Ctx of github.com/phuslu/log logs the trace id and span id.
+//
+//	log.DefaultTraceExtractor = otellog.TraceExtractor{}
+package otellog
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceExtractor implements log.TraceExtractor for the OpenTelemetry span context.
+type TraceExtractor struct{}
+
+// ExtractTrace returns the ids of the span context in ctx, ok is false if it is not
+// valid or not sampled.
+func (TraceExtractor) ExtractTrace(ctx context.Context) (traceID [16]byte, spanID [8]byte, ok bool) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || !sc.IsSampled() {
+		return
+	}
+	return sc.TraceID(), sc.SpanID(), true
+}
@@ -0,0 +1,42 @@
+package otellog
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/phuslu/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceExtractor(t *testing.T) {
+	defer func(x log.TraceExtractor) { log.DefaultTraceExtractor = x }(log.DefaultTraceExtractor)
+	log.DefaultTraceExtractor = TraceExtractor{}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	cases := []struct {
+		Ctx  context.Context
+		JSON string
+	}{
+		{trace.ContextWithSpanContext(context.Background(), sc), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","message":"hello"}`},
+		{trace.ContextWithSpanContext(context.Background(), sc.WithTraceFlags(0)), `"level":"info","message":"hello"}`},
+		{context.Background(), `"level":"info","message":"hello"}`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := log.Logger{Writer: &buf}
+		logger.Info().Ctx(c.Ctx).Msg("hello")
+		if s := buf.String(); !strings.HasSuffix(s, c.JSON+"\n") {
+			t.Errorf("otel trace extractor got %s, want %s", s, c.JSON)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package pgxlog provides a pgx tracelog.Logger implemented by github.com/phuslu/log.
+//
+//	conn.Tracer = &tracelog.TraceLog{Logger: &pgxlog.Logger{}, LogLevel: tracelog.LogLevelInfo}
+package pgxlog
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/phuslu/log"
+)
+
+// Logger implements tracelog.Logger, the data of pgx are added as the fields of
+// events. The "sql" and "time" data are renamed to "statement" and "duration"
+// to match the fields of sqllog.
+type Logger struct {
+	// Logger specifies the logger of pgx. It uses &log.DefaultLogger in if nil.
+	Logger *log.Logger
+
+	// LogArgs determines if the values of "args" data are logged, otherwise only the count
+	// of args is logged, or nothing if it is not a slice. It is off by default because the args may contain sensitive values.
+	LogArgs bool
+}
+
+// Log implements tracelog.Logger.
+func (l *Logger) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
+	logger := l.Logger
+	if logger == nil {
+		logger = &log.DefaultLogger
+	}
+
+	var e *log.Event
+	switch level {
+	case tracelog.LogLevelTrace, tracelog.LogLevelDebug:
+		e = logger.Debug()
+	case tracelog.LogLevelInfo:
+		e = logger.Info()
+	case tracelog.LogLevelWarn:
+		e = logger.Warn()
+	case tracelog.LogLevelError:
+		e = logger.Error()
+	default:
+		e = logger.WithLevel(log.NoLevel)
+	}
+	if e == nil {
+		return
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := data[k]
+		switch k {
+		case "sql":
+			k = "statement"
+		case "time":
+			k = "duration"
+		case "args":
+			if !l.LogArgs {
+				if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+					e = e.Int(k, rv.Len())
+				}
+				continue
+			}
+		}
+		switch v := v.(type) {
+		case string:
+			e = e.Str(k, v)
+		case error:
+			e = e.Str(k, v.Error())
+		case time.Duration:
+			e = e.Dur(k, v)
+		case bool:
+			e = e.Bool(k, v)
+		case int:
+			e = e.Int(k, v)
+		case int32:
+			e = e.Int32(k, v)
+		case int64:
+			e = e.Int64(k, v)
+		case uint32:
+			e = e.Uint32(k, v)
+		case uint64:
+			e = e.Uint64(k, v)
+		case float64:
+			e = e.Float64(k, v)
+		case nil:
+			e = e.RawJSON(k, []byte("null"))
+		default:
+			e = e.Interface(k, v)
+		}
+	}
+
+	e.Msg(msg)
+}
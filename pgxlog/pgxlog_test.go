@@ -0,0 +1,87 @@
+package pgxlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/phuslu/log"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := &Logger{
+		Logger: &log.Logger{
+			Level:  log.DebugLevel,
+			Writer: &buf,
+		},
+	}
+
+	var _ tracelog.Logger = l
+
+	cases := []struct {
+		Level tracelog.LogLevel
+		Msg   string
+		Data  map[string]any
+		JSON  string
+	}{
+		{
+			tracelog.LogLevelInfo,
+			"Query",
+			map[string]any{"sql": "select $1", "args": []any{"secret"}, "time": 3 * time.Millisecond, "commandTag": "SELECT 1", "pid": uint32(42)},
+			`"level":"info","args":1,"commandTag":"SELECT 1","pid":42,"statement":"select $1","duration":`,
+		},
+		{
+			tracelog.LogLevelError,
+			"Exec",
+			map[string]any{"sql": "fail", "err": errors.New("syntax error")},
+			`"level":"error","err":"syntax error","statement":"fail","message":"Exec"}`,
+		},
+		{
+			tracelog.LogLevelTrace,
+			"Prepare",
+			map[string]any{"name": "stmt1", "alreadyPrepared": false},
+			`"level":"debug","alreadyPrepared":false,"name":"stmt1","message":"Prepare"}`,
+		},
+		{
+			tracelog.LogLevelWarn,
+			"CopyFrom",
+			map[string]any{"tableName": nil, "rowCount": int64(10)},
+			`"level":"warn","rowCount":10,"tableName":null,"message":"CopyFrom"}`,
+		},
+		{
+			tracelog.LogLevelInfo,
+			"Exec",
+			map[string]any{"sql": "select $1", "args": []string{"secret"}},
+			`"level":"info","args":1,"statement":"select $1","message":"Exec"}`,
+		},
+		{
+			tracelog.LogLevelInfo,
+			"Exec",
+			map[string]any{"sql": "select $1", "args": "secret"},
+			`"level":"info","statement":"select $1","message":"Exec"}`,
+		},
+	}
+
+	for _, c := range cases {
+		buf.Reset()
+		l.Log(context.Background(), c.Level, c.Msg, c.Data)
+		if !strings.Contains(buf.String(), c.JSON) {
+			t.Errorf("pgxlog output %s does not contain %s", buf.String(), c.JSON)
+		}
+		if strings.Contains(buf.String(), "secret") {
+			t.Errorf("pgxlog output %s should not contain args", buf.String())
+		}
+	}
+
+	buf.Reset()
+	l.LogArgs = true
+	l.Log(context.Background(), tracelog.LogLevelInfo, "Query", map[string]any{"args": []any{"secret"}})
+	if !strings.Contains(buf.String(), "secret") {
+		t.Errorf("pgxlog output %s should contain args", buf.String())
+	}
+}
@@ -0,0 +1,15 @@
+// +build !log_reusecheck
+
+package log
+
+// reusecheck reports whether the events are checked against reuse by the log_reusecheck build tag.
+const reusecheck = false
+
+// eventPut returns the finished event to the pool.
+func eventPut(e *Event) {
+	if cap(e.buf) <= bbcap {
+		epool.Put(e)
+	}
+}
+
+func reuseCheck(e *Event) {}
@@ -0,0 +1,18 @@
+// +build log_reusecheck
+
+package log
+
+// reusecheck reports whether the events are checked against reuse by the log_reusecheck build tag.
+const reusecheck = true
+
+// eventPut marks the finished event as done instead of returning it to the pool,
+// so a later use of it panics in reuseCheck rather than corrupting another event.
+func eventPut(e *Event) {
+	e.done = true
+}
+
+func reuseCheck(e *Event) {
+	if e.done {
+		panic("log: event is used after Msg, Send or Discard")
+	}
+}
@@ -0,0 +1,39 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEventReuse(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+
+	e := logger.Info().Str("foo", "bar")
+	e.Msg("first")
+
+	if !reusecheck {
+		// the sent event goes back to the pool, so its stale pointer may be the next
+		// event and the fields added by it end up in the line of the next event.
+		next := logger.Info()
+		if next != e {
+			next.Discard()
+			t.Skip("the pool does not return the sent event")
+		}
+		e.Str("stale", "field")
+		next.Msg("second")
+		if !strings.Contains(buf.String(), `"stale":"field","message":"second"`) {
+			t.Errorf("stale event should corrupt the next event without log_reusecheck, got %s", buf.String())
+		}
+		return
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "used after Msg") {
+			t.Errorf("stale event should panic under log_reusecheck, got %v", r)
+		}
+	}()
+	e.Str("stale", "field")
+}
@@ -0,0 +1,117 @@
+package log
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Sampler is the interface of the samplers consulted by Logger before an event is
+// started, an event is dropped if Sample returns false.
+type Sampler interface {
+	// Sample returns true if the event of level should be logged.
+	Sample(level Level) bool
+}
+
+// BasicSampler is a Sampler which passes one in every N events.
+type BasicSampler struct {
+	// N specifies the sampling interval. Every event is passed in if N is zero or one.
+	N uint32
+
+	counter uint32
+}
+
+// Sample implements Sampler.
+func (s *BasicSampler) Sample(level Level) bool {
+	n := s.N
+	if n <= 1 {
+		return true
+	}
+	return (atomic.AddUint32(&s.counter, 1)-1)%n == 0
+}
+
+// String returns the configuration of the sampler.
+func (s *BasicSampler) String() string {
+	return fmt.Sprintf("BasicSampler{N:%d}", s.N)
+}
+
+// BurstSampler is a Sampler which passes the first Burst events in every Period,
+// the rest events of the period are passed to NextSampler.
+type BurstSampler struct {
+	// make 64-bit atomic operations aligned
+	resetAt int64
+
+	// Burst specifies the number of events passed in every Period.
+	Burst uint32
+
+	// Period specifies the period of Burst. It uses 1s in if zero.
+	Period time.Duration
+
+	// NextSampler specifies the sampler of the events exceeding Burst. They are
+	// dropped in if nil.
+	NextSampler Sampler
+
+	counter uint32
+}
+
+// Sample implements Sampler.
+func (s *BurstSampler) Sample(level Level) bool {
+	if s.Burst > 0 && s.inc() <= s.Burst {
+		return true
+	}
+	if s.NextSampler == nil {
+		return false
+	}
+	return s.NextSampler.Sample(level)
+}
+
+// inc increments the counter of the current period, the counter is reset when a
+// new period starts.
+func (s *BurstSampler) inc() uint32 {
+	sec, nsec := walltime()
+	now := sec*1000000000 + int64(nsec)
+
+	resetAt := atomic.LoadInt64(&s.resetAt)
+	if now < resetAt {
+		return atomic.AddUint32(&s.counter, 1)
+	}
+
+	period := s.Period
+	if period == 0 {
+		period = time.Second
+	}
+	if atomic.CompareAndSwapInt64(&s.resetAt, resetAt, now+int64(period)) {
+		atomic.StoreUint32(&s.counter, 1)
+		return 1
+	}
+	return atomic.AddUint32(&s.counter, 1)
+}
+
+// String returns the configuration of the sampler.
+func (s *BurstSampler) String() string {
+	str := fmt.Sprintf("BurstSampler{Burst:%d Period:%s}", s.Burst, s.Period)
+	if s.NextSampler != nil {
+		str += " -> " + describeSampler(s.NextSampler)
+	}
+	return str
+}
+
+// describeSampler returns the description of s by fmt.Stringer, or its type otherwise.
+func describeSampler(s Sampler) string {
+	if v, ok := s.(fmt.Stringer); ok {
+		return v.String()
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+// SampleBy keeps the event if s samples its level, the others are discarded like
+// Discard and return nil. The fatal and panic events are never sampled.
+func (e *Event) SampleBy(s Sampler) *Event {
+	if e == nil || s == nil || e.exit || e.panic {
+		return e
+	}
+	if !s.Sample(e.level) {
+		return e.Discard()
+	}
+	return e
+}
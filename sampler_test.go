@@ -0,0 +1,110 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBasicSampler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf, Sampler: &BasicSampler{N: 3}}
+
+	for i := 0; i < 9; i++ {
+		logger.Info().Int("i", i).Msg("basic sampler")
+	}
+	osExit = func(int) {}
+	defer func() { osExit = os.Exit }()
+	logger.Fatal().Msg("fatal is never sampled")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var got []string
+	for _, line := range lines {
+		if strings.Contains(line, `"level":"info"`) {
+			got = append(got, line[strings.Index(line, `"i":`):strings.Index(line, `,"message"`)])
+		}
+	}
+	if want := []string{`"i":0`, `"i":3`, `"i":6`}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("basic sampler output %v, want %v", got, want)
+	}
+	if !strings.Contains(buf.String(), "fatal is never sampled") {
+		t.Errorf("basic sampler drops the fatal event: %s", buf.String())
+	}
+
+	s := &BasicSampler{}
+	for i := 0; i < 10; i++ {
+		if !s.Sample(InfoLevel) {
+			t.Fatalf("basic sampler with zero N should pass every event")
+		}
+	}
+}
+
+func TestBurstSampler(t *testing.T) {
+	s := &BurstSampler{Burst: 3, Period: time.Hour, NextSampler: &BasicSampler{N: 2}}
+
+	var passed []int
+	for i := 0; i < 9; i++ {
+		if s.Sample(InfoLevel) {
+			passed = append(passed, i)
+		}
+	}
+	// the first 3 events pass, then 1 in 2 of the rest.
+	if got, want := len(passed), 6; got != want || passed[2] != 2 || passed[3] != 3 || passed[4] != 5 {
+		t.Errorf("burst sampler passed %v", passed)
+	}
+
+	s = &BurstSampler{Burst: 2, Period: time.Millisecond}
+	for i := 0; i < 5; i++ {
+		if pass := s.Sample(InfoLevel); pass != (i < 2) {
+			t.Errorf("burst sampler event %d passed %v", i, pass)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if !s.Sample(InfoLevel) {
+		t.Errorf("burst sampler should pass the first event of the next period")
+	}
+
+	if got, want := (&BurstSampler{Burst: 1, Period: time.Second, NextSampler: &BasicSampler{N: 10}}).String(), "BurstSampler{Burst:1 Period:1s} -> BasicSampler{N:10}"; got != want {
+		t.Errorf("burst sampler string %q, want %q", got, want)
+	}
+}
+
+func TestEventSampleBy(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+
+	s := &BasicSampler{N: 2}
+	for i := 0; i < 4; i++ {
+		logger.Info().SampleBy(s).Int("i", i).Msg("sample by")
+	}
+	if n := strings.Count(buf.String(), "sample by"); n != 2 {
+		t.Errorf("SampleBy output %d lines, want 2: %s", n, buf.String())
+	}
+
+	if e := logger.Info().SampleBy(nil); e == nil {
+		t.Errorf("SampleBy(nil) should keep the event")
+	} else {
+		e.Discard()
+	}
+
+	logger.Sampler = &BurstSampler{Burst: 1}
+	if s := logger.String(); !strings.Contains(s, " Sampler:BurstSampler{Burst:1 Period:0s} ") {
+		t.Errorf("logger string %s, want Sampler", s)
+	}
+}
+
+func BenchmarkLoggerSampler(b *testing.B) {
+	logger := Logger{
+		Writer:  ioutil.Discard,
+		Sampler: &BasicSampler{N: 10},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info().Str("foo", "bar").Msg("hello world")
+	}
+}
@@ -0,0 +1,393 @@
+// Package sqllog provides a database/sql driver.Connector wrapper which logs the
+// statements executed through it by github.com/phuslu/log.
+//
+//	db := sql.OpenDB(sqllog.New(connector))
+package sqllog
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/phuslu/log"
+)
+
+// Connector is a driver.Connector which logs the statements executed through the
+// connections of the wrapped Connector, with the statement, args count, rows,
+// duration and error fields.
+type Connector struct {
+	// Connector specifies the wrapped connector.
+	Connector driver.Connector
+
+	// Logger specifies the logger of statements. It uses &log.DefaultLogger in if nil.
+	Logger *log.Logger
+
+	// Level specifies the level of the successful statements.
+	Level log.Level
+
+	// ErrorLevel specifies the level of the failed statements.
+	ErrorLevel log.Level
+
+	// SlowLevel specifies the level of the statements slower than SlowThreshold.
+	SlowLevel log.Level
+
+	// SlowThreshold specifies the duration of slow statements. It is disabled in if zero.
+	SlowThreshold time.Duration
+
+	// MaxStatementSize truncates the statements longer than it in bytes. It is unlimited in if zero.
+	MaxStatementSize int
+
+	// Anonymize determines if the string and number literals of statements are replaced with "?".
+	Anonymize bool
+
+	// LogArgs determines if the values of args are logged. It is off by default because
+	// the args may contain sensitive values.
+	LogArgs bool
+}
+
+// New returns a Connector wrapping c, which logs the statements at debug level,
+// the slow statements at warn level and the failed statements at error level.
+func New(c driver.Connector) *Connector {
+	return &Connector{
+		Connector:  c,
+		Level:      log.DebugLevel,
+		ErrorLevel: log.ErrorLevel,
+		SlowLevel:  log.WarnLevel,
+	}
+}
+
+// Connect implements driver.Connector.
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: cn, c: c}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *Connector) Driver() driver.Driver {
+	return c.Connector.Driver()
+}
+
+func (c *Connector) log(op, query string, args []driver.NamedValue, rows int64, d time.Duration, err error) {
+	logger := c.Logger
+	if logger == nil {
+		logger = &log.DefaultLogger
+	}
+
+	level := c.Level
+	switch {
+	case err != nil:
+		level = c.ErrorLevel
+	case c.SlowThreshold > 0 && d >= c.SlowThreshold:
+		level = c.SlowLevel
+	}
+
+	e := logger.WithLevel(level)
+	if e == nil {
+		return
+	}
+
+	if c.Anonymize {
+		query = anonymize(query)
+	}
+	if n := c.MaxStatementSize; n > 0 && len(query) > n {
+		for n > 0 && !utf8.RuneStart(query[n]) {
+			n--
+		}
+		query = query[:n] + "... " + strconv.Itoa(len(query)-n) + " bytes truncated"
+	}
+
+	e = e.Str("statement", query).Int("args", len(args))
+	if c.LogArgs {
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			values[i] = arg.Value
+		}
+		e = e.Interface("values", values)
+	}
+	if rows >= 0 {
+		e = e.Int64("rows", rows)
+	}
+	if err != nil {
+		e = e.Err(err)
+	}
+	e.Dur("duration", d).Msg(op)
+}
+
+type conn struct {
+	driver.Conn
+	c *Connector
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	res, err := execer.ExecContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return res, err
+	}
+
+	c.c.log("sql exec", query, args, rowsAffected(res, err), time.Since(start), err)
+	return res, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	r, err := queryer.QueryContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return r, err
+	}
+	if err != nil {
+		c.c.log("sql query", query, args, -1, time.Since(start), err)
+		return nil, err
+	}
+
+	return &rows{Rows: r, c: c.c, query: query, args: args, start: start}, nil
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (st driver.Stmt, err error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		st, err = p.PrepareContext(ctx, query)
+	} else {
+		st, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: st, c: c.c, query: query}, nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("sqllog: driver does not support non-default transaction options")
+	}
+	return c.Conn.Begin()
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+type stmt struct {
+	driver.Stmt
+	c     *Connector
+	query string
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
+	start := time.Now()
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			res, err = s.Stmt.Exec(values)
+		}
+	}
+
+	s.c.log("sql exec", s.query, args, rowsAffected(res, err), time.Since(start), err)
+	return res, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (r driver.Rows, err error) {
+	start := time.Now()
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		r, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			r, err = s.Stmt.Query(values)
+		}
+	}
+	if err != nil {
+		s.c.log("sql query", s.query, args, -1, time.Since(start), err)
+		return nil, err
+	}
+
+	return &rows{Rows: r, c: s.c, query: s.query, args: args, start: start}, nil
+}
+
+// rows logs the query when it is closed, with the number of rows read and the
+// duration since the query started.
+type rows struct {
+	driver.Rows
+	c     *Connector
+	query string
+	args  []driver.NamedValue
+	start time.Time
+	n     int64
+	err   error
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch err {
+	case nil:
+		r.n++
+	case io.EOF:
+	default:
+		r.err = err
+	}
+	return err
+}
+
+func (r *rows) Close() error {
+	err := r.Rows.Close()
+	r.c.log("sql query", r.query, r.args, r.n, time.Since(r.start), r.err)
+	return err
+}
+
+// The optional interfaces of driver.Rows below fall back to the defaults of
+// database/sql if the wrapped rows do not implement them.
+
+func (r *rows) HasNextResultSet() bool {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *rows) NextResultSet() error {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	if rs, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return rs.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	if rs, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return rs.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *rows) ColumnTypeLength(index int) (length int64, ok bool) {
+	if rs, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return rs.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *rows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if rs, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return rs.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *rows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if rs, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return rs.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+func rowsAffected(res driver.Result, err error) int64 {
+	if err != nil || res == nil {
+		return -1
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sqllog: driver does not support the use of named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+// anonymize replaces the string and number literals of query with "?".
+func anonymize(query string) string {
+	b := make([]byte, 0, len(query))
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			// skip the string literal, a quote is escaped by doubling it.
+			for i++; i < len(query); i++ {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			b = append(b, '?')
+		case c >= '0' && c <= '9' && (i == 0 || !isIdent(query[i-1])):
+			for i+1 < len(query) && (isIdent(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			b = append(b, '?')
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
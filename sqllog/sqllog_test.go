@@ -0,0 +1,287 @@
+package sqllog
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phuslu/log"
+)
+
+type testDriver struct{}
+
+func (testDriver) Open(name string) (driver.Conn, error) { return &testConn{}, nil }
+
+type testConnector struct{}
+
+func (testConnector) Connect(context.Context) (driver.Conn, error) { return &testConn{}, nil }
+func (testConnector) Driver() driver.Driver                        { return testDriver{} }
+
+// testConn supports ExecerContext, QueryerContext and Validator, its prepared
+// statements only support the legacy Exec and Query.
+type testConn struct {
+	closed bool
+}
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) { return &testStmt{query}, nil }
+func (c *testConn) Close() error                              { c.closed = true; return nil }
+func (c *testConn) Begin() (driver.Tx, error)                 { return c, nil }
+func (c *testConn) Commit() error                             { return nil }
+func (c *testConn) Rollback() error                           { return nil }
+func (c *testConn) IsValid() bool                             { return !c.closed }
+
+func (c *testConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return testExec(query)
+}
+
+func (c *testConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return testQuery(query)
+}
+
+type testStmt struct {
+	query string
+}
+
+func (s *testStmt) Close() error                                    { return nil }
+func (s *testStmt) NumInput() int                                   { return -1 }
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) { return testExec(s.query) }
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error)  { return testQuery(s.query) }
+
+func testExec(query string) (driver.Result, error) {
+	if strings.Contains(query, "fail") {
+		return nil, errors.New("syntax error")
+	}
+	if strings.Contains(query, "slow") {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return driver.RowsAffected(2), nil
+}
+
+func testQuery(query string) (driver.Rows, error) {
+	if strings.Contains(query, "fail") {
+		return nil, errors.New("syntax error")
+	}
+	rows := &testRows{n: 3}
+	if strings.Contains(query, "multi") {
+		rows.sets = 1
+	}
+	return rows, nil
+}
+
+// testRows supports RowsNextResultSet and RowsColumnTypeDatabaseTypeName.
+type testRows struct {
+	n    int
+	sets int
+}
+
+func (r *testRows) Columns() []string                     { return []string{"id"} }
+func (r *testRows) Close() error                          { return nil }
+func (r *testRows) ColumnTypeDatabaseTypeName(int) string { return "INT8" }
+func (r *testRows) HasNextResultSet() bool                { return r.sets > 0 }
+func (r *testRows) NextResultSet() error {
+	if r.sets == 0 {
+		return io.EOF
+	}
+	r.sets--
+	r.n = 1
+	return nil
+}
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.n == 0 {
+		return io.EOF
+	}
+	r.n--
+	dest[0] = int64(r.n)
+	return nil
+}
+
+func TestConnector(t *testing.T) {
+	var buf bytes.Buffer
+	c := New(testConnector{})
+	c.Logger = &log.Logger{
+		Level:  log.DebugLevel,
+		Writer: &buf,
+	}
+	c.SlowThreshold = 10 * time.Millisecond
+
+	db := sql.OpenDB(c)
+	defer db.Close()
+
+	cases := []struct {
+		Run  func() error
+		JSON string
+	}{
+		{
+			func() error {
+				_, err := db.Exec("UPDATE users SET name = ? WHERE id = ?", "bob", 42)
+				return err
+			},
+			`"level":"debug","statement":"UPDATE users SET name = ? WHERE id = ?","args":2,"rows":2,"duration":`,
+		},
+		{
+			func() error {
+				_, err := db.Exec("UPDATE slow SET a = 1")
+				return err
+			},
+			`"level":"warn","statement":"UPDATE slow SET a = 1","args":0,"rows":2,"duration":`,
+		},
+		{
+			func() error {
+				_, err := db.Exec("fail")
+				if err == nil {
+					return errors.New("exec should fail")
+				}
+				return nil
+			},
+			`"level":"error","statement":"fail","args":0,"error":"syntax error","duration":`,
+		},
+		{
+			func() error {
+				rows, err := db.Query("SELECT id FROM users WHERE id > ?", 0)
+				if err != nil {
+					return err
+				}
+				defer rows.Close()
+				for rows.Next() {
+				}
+				return rows.Err()
+			},
+			`"level":"debug","statement":"SELECT id FROM users WHERE id > ?","args":1,"rows":3,"duration":`,
+		},
+		{
+			func() error {
+				stmt, err := db.Prepare("SELECT id FROM users")
+				if err != nil {
+					return err
+				}
+				defer stmt.Close()
+				var id int64
+				return stmt.QueryRow().Scan(&id)
+			},
+			`"level":"debug","statement":"SELECT id FROM users","args":0,"rows":1,"duration":`,
+		},
+		{
+			func() error {
+				tx, err := db.Begin()
+				if err != nil {
+					return err
+				}
+				if _, err = tx.Exec("DELETE FROM users"); err != nil {
+					return err
+				}
+				return tx.Commit()
+			},
+			`"level":"debug","statement":"DELETE FROM users","args":0,"rows":2,"duration":`,
+		},
+	}
+
+	for i, c := range cases {
+		buf.Reset()
+		if err := c.Run(); err != nil {
+			t.Fatalf("case %d error: %+v", i, err)
+		}
+		if !strings.Contains(buf.String(), c.JSON) {
+			t.Errorf("case %d got %s, want %s", i, buf.String(), c.JSON)
+		}
+		if strings.Contains(buf.String(), "bob") {
+			t.Errorf("case %d should not log the args values: %s", i, buf.String())
+		}
+	}
+}
+
+func TestConnectorInterfaces(t *testing.T) {
+	var buf bytes.Buffer
+	c := New(testConnector{})
+	c.Logger = &log.Logger{
+		Level:  log.DebugLevel,
+		Writer: &buf,
+	}
+
+	cn, err := c.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("connect error: %+v", err)
+	}
+	v, ok := cn.(driver.Validator)
+	if !ok || !v.IsValid() {
+		t.Errorf("conn should forward IsValid")
+	}
+	cn.Close()
+	if v.IsValid() {
+		t.Errorf("conn should be invalid after close")
+	}
+
+	db := sql.OpenDB(c)
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id FROM multi")
+	if err != nil {
+		t.Fatalf("query error: %+v", err)
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil || len(types) != 1 || types[0].DatabaseTypeName() != "INT8" {
+		t.Errorf("rows should forward the column types, got %v, %+v", types, err)
+	}
+	n := 0
+	for {
+		for rows.Next() {
+			n++
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	rows.Close()
+	if n != 4 {
+		t.Errorf("rows should forward the next result set, got %d rows, want 4", n)
+	}
+	if !strings.Contains(buf.String(), `"statement":"SELECT id FROM multi","args":0,"rows":4,`) {
+		t.Errorf("unexpected output %s", buf.String())
+	}
+}
+
+func TestConnectorOptions(t *testing.T) {
+	var buf bytes.Buffer
+	c := New(testConnector{})
+	c.Logger = &log.Logger{
+		Level:  log.DebugLevel,
+		Writer: &buf,
+	}
+	c.Anonymize = true
+	c.MaxStatementSize = 40
+	c.LogArgs = true
+
+	db := sql.OpenDB(c)
+	defer db.Close()
+
+	_, err := db.Exec("UPDATE users SET name = 'it''s', age = 42, t1 = $1 WHERE id = 7", "bob")
+	if err != nil {
+		t.Fatalf("exec error: %+v", err)
+	}
+	if s := buf.String(); !strings.Contains(s, `"statement":"UPDATE users SET name = ?, age = ?, t1 =... 16 bytes truncated","args":1,"values":`) || !strings.Contains(s, "bob") {
+		t.Errorf("unexpected output %s", s)
+	}
+}
+
+func TestAnonymize(t *testing.T) {
+	cases := []struct {
+		Query string
+		Want  string
+	}{
+		{`SELECT * FROM t WHERE a = 'x' AND b = 42`, `SELECT * FROM t WHERE a = ? AND b = ?`},
+		{`SELECT * FROM t2 WHERE a = 'it''s' AND b IN (1, 2.5, 3e10)`, `SELECT * FROM t2 WHERE a = ? AND b IN (?, ?, ?)`},
+		{`SELECT $1, $2, col_1 FROM t`, `SELECT $1, $2, col_1 FROM t`},
+		{`INSERT INTO t VALUES ('unterminated`, `INSERT INTO t VALUES (?`},
+	}
+
+	for _, c := range cases {
+		if got := anonymize(c.Query); got != c.Want {
+			t.Errorf("anonymize(%q) got %q, want %q", c.Query, got, c.Want)
+		}
+	}
+}
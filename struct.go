@@ -0,0 +1,284 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Struct adds the exported fields of struct v as individual fields to the event.
+// The keys are prefixed with "prefix." if prefix is not empty, nested structs are
+// flattened into dotted keys. The field names can be customized by the "log" tag,
+// e.g. `log:"name"` renames the field, `log:"name,omitempty"` skips the field if it
+// is empty and `log:"-"` always skips the field.
+// The fields of a type are resolved by reflection only once and cached afterwards.
+func (e *Event) Struct(prefix string, v interface{}) *Event {
+	if e == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			break
+		}
+		rv = rv.Elem()
+	}
+	switch {
+	case !rv.IsValid() || rv.Kind() == reflect.Ptr:
+		e.key(prefix)
+		e.buf = append(e.buf, "null"...)
+	case rv.Kind() == reflect.Struct && rv.Type() != timeType:
+		e.structFields(prefix, rv, structFieldsOf(rv.Type()))
+	default:
+		e.key(prefix)
+		structEncoderOf(rv.Type())(e, rv)
+	}
+	return e
+}
+
+type structField struct {
+	name      string
+	index     int
+	omitempty bool
+	encode    structEncoder
+	fields    []structField
+}
+
+type structEncoder func(e *Event, v reflect.Value)
+
+var structCache sync.Map // map[reflect.Type][]structField
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+func structFieldsOf(t reflect.Type) []structField {
+	if v, ok := structCache.Load(t); ok {
+		return v.([]structField)
+	}
+	fields := compileStruct(t, "", map[reflect.Type]bool{})
+	structCache.Store(t, fields)
+	return fields
+}
+
+func compileStruct(t reflect.Type, parent string, visiting map[reflect.Type]bool) (fields []structField) {
+	visiting[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("log")
+		if sf.PkgPath != "" || tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if j := strings.IndexByte(tag, ','); j >= 0 {
+			name, opts = tag[:j], tag[j:]
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		nested := ft.Kind() == reflect.Struct && ft != timeType && !visiting[ft] &&
+			!ft.Implements(stringerType) && !reflect.PtrTo(ft).Implements(stringerType)
+		if name == "" && !(sf.Anonymous && nested) {
+			name = sf.Name
+		}
+		f := structField{
+			name:      name,
+			index:     i,
+			omitempty: strings.Contains(opts+",", ",omitempty,"),
+		}
+		if parent != "" {
+			if name == "" {
+				f.name = parent
+			} else {
+				f.name = parent + "." + name
+			}
+		}
+		if nested {
+			f.fields = compileStruct(ft, f.name, visiting)
+		} else {
+			f.encode = structEncoderOf(sf.Type)
+		}
+		fields = append(fields, f)
+	}
+	delete(visiting, t)
+	return
+}
+
+// structKey appends the dotted key of prefix and name, it is escaped like key.
+func (e *Event) structKey(prefix, name string) {
+	if !e.plain(prefix) || !e.plain(name) {
+		if prefix != "" && name != "" {
+			name = prefix + "." + name
+		} else {
+			name = prefix + name
+		}
+		e.key(name)
+		return
+	}
+	e.buf = append(e.buf, ',', '"')
+	e.buf = append(e.buf, prefix...)
+	if prefix != "" && name != "" {
+		e.buf = append(e.buf, '.')
+	}
+	e.buf = append(e.buf, name...)
+	e.buf = append(e.buf, '"', ':')
+}
+
+func (e *Event) structFields(prefix string, v reflect.Value, fields []structField) {
+	for _, f := range fields {
+		fv := v.Field(f.index)
+		if f.fields != nil {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					if !f.omitempty {
+						e.structKey(prefix, f.name)
+						e.buf = append(e.buf, "null"...)
+					}
+					continue
+				}
+				fv = fv.Elem()
+			}
+			e.structFields(prefix, fv, f.fields)
+			continue
+		}
+		if f.omitempty && isEmptyValue(fv) {
+			continue
+		}
+		e.structKey(prefix, f.name)
+		f.encode(e, fv)
+	}
+}
+
+func structEncoderOf(t reflect.Type) structEncoder {
+	switch {
+	case t == timeType:
+		return encodeStructTime
+	case t == durationType:
+		return encodeStructDuration
+	case t.Implements(errorType):
+		return encodeStructError
+	case t.Implements(stringerType):
+		return encodeStructStringer
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return encodeStructString
+	case reflect.Bool:
+		return encodeStructBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return encodeStructInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return encodeStructUint
+	case reflect.Float32, reflect.Float64:
+		return encodeStructFloat
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return encodeStructBytes
+		}
+	case reflect.Ptr:
+		elem := structEncoderOf(t.Elem())
+		return func(e *Event, v reflect.Value) {
+			if v.IsNil() {
+				e.buf = append(e.buf, "null"...)
+			} else {
+				elem(e, v.Elem())
+			}
+		}
+	case reflect.Interface:
+		return encodeStructInterface
+	}
+	return encodeStructJSON
+}
+
+func encodeStructTime(e *Event, v reflect.Value) {
+	e.buf = append(e.buf, '"')
+	e.buf = v.Interface().(time.Time).AppendFormat(e.buf, time.RFC3339Nano)
+	e.buf = append(e.buf, '"')
+}
+
+func encodeStructDuration(e *Event, v reflect.Value) {
+	e.buf = append(e.buf, '"')
+	e.buf = append(e.buf, time.Duration(v.Int()).String()...)
+	e.buf = append(e.buf, '"')
+}
+
+func encodeStructError(e *Event, v reflect.Value) {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		e.buf = append(e.buf, "null"...)
+	} else {
+		e.string(v.Interface().(error).Error())
+	}
+}
+
+func encodeStructStringer(e *Event, v reflect.Value) {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		e.buf = append(e.buf, "null"...)
+	} else {
+		e.string(v.Interface().(fmt.Stringer).String())
+	}
+}
+
+func encodeStructString(e *Event, v reflect.Value) {
+	e.string(v.String())
+}
+
+func encodeStructBool(e *Event, v reflect.Value) {
+	e.buf = strconv.AppendBool(e.buf, v.Bool())
+}
+
+func encodeStructInt(e *Event, v reflect.Value) {
+	e.buf = strconv.AppendInt(e.buf, v.Int(), 10)
+}
+
+func encodeStructUint(e *Event, v reflect.Value) {
+	e.buf = strconv.AppendUint(e.buf, v.Uint(), 10)
+}
+
+func encodeStructFloat(e *Event, v reflect.Value) {
+	e.float(v.Float(), v.Type().Bits())
+}
+
+func encodeStructBytes(e *Event, v reflect.Value) {
+	e.bytes(v.Bytes())
+}
+
+func encodeStructInterface(e *Event, v reflect.Value) {
+	if v.IsNil() {
+		e.buf = append(e.buf, "null"...)
+	} else {
+		structEncoderOf(v.Elem().Type())(e, v.Elem())
+	}
+}
+
+func encodeStructJSON(e *Event, v reflect.Value) {
+	e.iface(v.Interface())
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+	}
+	return false
+}
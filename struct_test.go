@@ -0,0 +1,143 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+type testStructAddr struct {
+	City string `log:"city"`
+	Zip  string `log:"zip,omitempty"`
+}
+
+type Embedded struct {
+	ID int64 `log:"id"`
+}
+
+type testStruct struct {
+	Embedded
+	Name     string          `log:"name"`
+	Age      int             `log:"age,omitempty"`
+	Admin    bool            `log:"admin"`
+	Score    float64         `log:"score"`
+	Quota    uint32          `log:"quota"`
+	Timeout  time.Duration   `log:"timeout"`
+	Created  time.Time       `log:"created"`
+	Addr     testStructAddr  `log:"addr"`
+	Backup   *testStructAddr `log:"backup"`
+	Err      error           `log:"err"`
+	Tags     []string        `log:"tags"`
+	Raw      []byte          `log:"raw"`
+	Password string          `log:"-"`
+	Untagged string
+	secret   string
+}
+
+func TestStruct(t *testing.T) {
+	v := testStruct{
+		Embedded: Embedded{ID: 42},
+		Name:     "bob",
+		Admin:    true,
+		Score:    1.5,
+		Quota:    7,
+		Timeout:  time.Second,
+		Created:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Addr:     testStructAddr{City: "hk"},
+		Err:      errors.New("oops"),
+		Tags:     []string{"a", "b"},
+		Raw:      []byte("raw"),
+		Password: "123456",
+		Untagged: "untagged",
+		secret:   "secret",
+	}
+
+	cases := []struct {
+		Prefix string
+		Value  interface{}
+		JSON   string
+	}{
+		{"user", v, `,"user.id":42,"user.name":"bob","user.admin":true,"user.score":1.5,"user.quota":7,"user.timeout":"1s","user.created":"2020-01-02T03:04:05Z","user.addr.city":"hk","user.backup":null,"user.err":"oops","user.tags":`},
+		{"", &v, `,"id":42,"name":"bob","admin":true,"score":1.5,"quota":7,"timeout":"1s","created":"2020-01-02T03:04:05Z","addr.city":"hk","backup":null,"err":"oops","tags":`},
+		{"addr", &testStructAddr{"sz", "518000"}, `,"addr.city":"sz","addr.zip":"518000"}`},
+		{"addr", (*testStructAddr)(nil), `,"addr":null}`},
+		{"number", 42, `,"number":42}`},
+		{"ratio", struct {
+			Ratio float32 `log:"r"`
+		}{0.1}, `,"ratio.r":0.1}`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		logger := Logger{Writer: &buf}
+		logger.Info().Struct(c.Prefix, c.Value).Msg("")
+		if !bytes.Contains(buf.Bytes(), []byte(c.JSON)) {
+			t.Errorf("struct output %s does not contain %s", buf.Bytes(), c.JSON)
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("struct output %s is not valid json", buf.Bytes())
+		}
+		for _, s := range []string{"123456", `"secret"`, "Password"} {
+			if bytes.Contains(buf.Bytes(), []byte(s)) {
+				t.Errorf("struct output %s should not contain %s", buf.Bytes(), s)
+			}
+		}
+	}
+}
+
+type testStructEscape struct {
+	Quote   int `log:"a\"b"`
+	Control int `log:"c\nd"`
+}
+
+func TestStructEscapeKey(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+
+	logger.Info().Struct("p\"q\x01", testStructEscape{1, 2}).Msg("")
+	want := `,"p\"q\u0001.a\"b":1,"p\"q\u0001.c\nd":2}`
+	if !bytes.HasSuffix(buf.Bytes(), []byte(want+"\n")) {
+		t.Errorf("struct output %s does not end with %s", buf.Bytes(), want)
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Errorf("struct output %s is not valid json", buf.Bytes())
+	}
+
+	buf.Reset()
+	logger.Info().Struct("", testStructEscape{1, 2}).Msg("")
+	if want := `,"a\"b":1,"c\nd":2}`; !bytes.HasSuffix(buf.Bytes(), []byte(want+"\n")) {
+		t.Errorf("struct output %s does not end with %s", buf.Bytes(), want)
+	}
+}
+
+func BenchmarkStruct(b *testing.B) {
+	logger := Logger{
+		Timestamp: true,
+		Writer:    ioutil.Discard,
+	}
+	v := &testStructAddr{City: "hk", Zip: "999077"}
+
+	b.Run("Struct", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logger.Info().Struct("addr", v).Msg("")
+		}
+	})
+
+	b.Run("Str", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logger.Info().Str("addr.city", v.City).Str("addr.zip", v.Zip).Msg("")
+		}
+	})
+
+	b.Run("Interface", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logger.Info().Interface("addr", v).Msg("")
+		}
+	})
+}
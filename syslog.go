@@ -0,0 +1,262 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SyslogFormat defines the header format of SyslogWriter.
+type SyslogFormat int
+
+const (
+	// SyslogRFC3164 is the BSD syslog format, e.g. `<14>Jan  2 15:04:05 host app[42]: `.
+	SyslogRFC3164 SyslogFormat = iota
+	// SyslogRFC5424 is the IETF syslog format without structured data, e.g.
+	// `<14>1 2006-01-02T15:04:05.000000Z host app 42 - - `.
+	SyslogRFC5424
+)
+
+// SyslogWriter is an io.WriteCloser and LevelWriter that writes the JSON lines to
+// a syslog server, each line is prefixed by a syslog header whose severity is
+// mapped from the level of the event, i.e. trace and debug to 7, info to 6, warn
+// to 4, error to 3, fatal to 2 and panic to 0.
+//
+// The connection is dialed on the first write. After the connection drops, it is
+// redialed with an exponential backoff, and the lines written in between are kept
+// in a buffer of BufferSize lines, the oldest lines are dropped if it is full.
+type SyslogWriter struct {
+	// make aligncheck happy
+	dropped uint64
+
+	// Network specifies the network of the syslog server, e.g. "udp", "tcp" or "unixgram".
+	// It uses "unixgram" in if empty.
+	Network string
+
+	// Address specifies the address of the syslog server. It uses "/dev/log" in if empty.
+	Address string
+
+	// Format specifies the header format. It uses SyslogRFC3164 in if empty.
+	Format SyslogFormat
+
+	// Facility specifies the syslog facility, e.g. 16 for local0. It uses 1 (user) in if zero.
+	Facility int
+
+	// Hostname specifies the hostname in the header. It uses os.Hostname() in if empty.
+	Hostname string
+
+	// Tag specifies the tag or app-name in the header. It uses the base name of os.Args[0] in if empty.
+	Tag string
+
+	// BufferSize specifies the maximum number of lines buffered while disconnected.
+	// The lines are dropped while disconnected if zero.
+	BufferSize int
+
+	// DialTimeout specifies the timeout of dialing. It uses 5s in if zero.
+	DialTimeout time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	backoff time.Duration
+	retry   time.Time
+	pending [][]byte
+	buf     []byte
+}
+
+const (
+	syslogMinBackoff = 100 * time.Millisecond
+	syslogMaxBackoff = 30 * time.Second
+)
+
+// Write implements io.Writer, the level is parsed from the level field of p.
+func (w *SyslogWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(levelOf(p), p)
+}
+
+// WriteLevel implements LevelWriter.
+func (w *SyslogWriter) WriteLevel(level Level, p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = w.header(w.buf[:0], level)
+	w.buf = append(w.buf, p...)
+	if len(p) == 0 || p[len(p)-1] != '\n' {
+		w.buf = append(w.buf, '\n')
+	}
+
+	if w.conn == nil {
+		if timeNow().Before(w.retry) {
+			w.buffer(w.buf)
+			return len(p), nil
+		}
+		if err = w.dial(); err != nil {
+			w.buffer(w.buf)
+			return len(p), err
+		}
+	}
+
+	for len(w.pending) > 0 {
+		if _, err = w.conn.Write(w.pending[0]); err != nil {
+			w.disconnect()
+			w.buffer(w.buf)
+			return len(p), err
+		}
+		w.pending = w.pending[1:]
+	}
+
+	if _, err = w.conn.Write(w.buf); err != nil {
+		w.disconnect()
+		w.buffer(w.buf)
+		return len(p), err
+	}
+
+	return len(p), nil
+}
+
+// Dropped returns the number of lines dropped while disconnected.
+func (w *SyslogWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Close implements io.Closer, and closes the connection. The buffered lines are discarded.
+func (w *SyslogWriter) Close() (err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		err = w.conn.Close()
+		w.conn = nil
+	}
+	w.pending = nil
+	return
+}
+
+// Describe implements WriterDescriber.
+func (w *SyslogWriter) Describe() string {
+	network, address := w.network()
+	return fmt.Sprintf("SyslogWriter{Network:%s Address:%s Format:%d Facility:%d}", network, address, w.Format, w.facility())
+}
+
+func (w *SyslogWriter) network() (network, address string) {
+	network, address = w.Network, w.Address
+	if network == "" {
+		network = "unixgram"
+	}
+	if address == "" {
+		address = "/dev/log"
+	}
+	return
+}
+
+func (w *SyslogWriter) facility() int {
+	if w.Facility == 0 {
+		return 1
+	}
+	return w.Facility
+}
+
+func (w *SyslogWriter) dial() (err error) {
+	timeout := w.DialTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	network, address := w.network()
+	w.conn, err = net.DialTimeout(network, address, timeout)
+	if err != nil {
+		w.conn = nil
+		w.disconnect()
+		return fmt.Errorf("log: SyslogWriter dial error: %v", err)
+	}
+	w.backoff = 0
+	return nil
+}
+
+// disconnect closes the connection and schedules the next dial.
+func (w *SyslogWriter) disconnect() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	switch {
+	case w.backoff == 0:
+		w.backoff = syslogMinBackoff
+	case w.backoff < syslogMaxBackoff:
+		w.backoff *= 2
+		if w.backoff > syslogMaxBackoff {
+			w.backoff = syslogMaxBackoff
+		}
+	}
+	w.retry = timeNow().Add(w.backoff)
+}
+
+// buffer copies line into the pending lines, or drops the oldest line if it is full.
+func (w *SyslogWriter) buffer(line []byte) {
+	if w.BufferSize <= 0 {
+		atomic.AddUint64(&w.dropped, 1)
+		return
+	}
+	if len(w.pending) >= w.BufferSize {
+		w.pending = w.pending[1:]
+		atomic.AddUint64(&w.dropped, 1)
+	}
+	w.pending = append(w.pending, append([]byte(nil), line...))
+}
+
+func (w *SyslogWriter) header(dst []byte, level Level) []byte {
+	host := w.Hostname
+	if host == "" {
+		host = hostname
+	}
+	tag := w.Tag
+	if tag == "" {
+		tag = program
+	}
+
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(w.facility()*8+syslogSeverity(level)), 10)
+	dst = append(dst, '>')
+	switch w.Format {
+	case SyslogRFC5424:
+		dst = append(dst, '1', ' ')
+		dst = timeNow().AppendFormat(dst, "2006-01-02T15:04:05.000000Z07:00")
+		dst = append(dst, ' ')
+		dst = append(dst, host...)
+		dst = append(dst, ' ')
+		dst = append(dst, tag...)
+		dst = append(dst, ' ')
+		dst = strconv.AppendInt(dst, int64(pid), 10)
+		dst = append(dst, " - - "...)
+	default:
+		dst = timeNow().AppendFormat(dst, time.Stamp)
+		dst = append(dst, ' ')
+		dst = append(dst, host...)
+		dst = append(dst, ' ')
+		dst = append(dst, tag...)
+		dst = append(dst, '[')
+		dst = strconv.AppendInt(dst, int64(pid), 10)
+		dst = append(dst, ']', ':', ' ')
+	}
+	return dst
+}
+
+// syslogSeverity returns the syslog severity of level.
+func syslogSeverity(level Level) int {
+	switch {
+	case level == NoLevel:
+		return 6
+	case level >= PanicLevel:
+		return 0
+	case level >= FatalLevel:
+		return 2
+	case level >= ErrorLevel:
+		return 3
+	case level >= WarnLevel:
+		return 4
+	case level >= InfoLevel:
+		return 6
+	}
+	return 7
+}
@@ -0,0 +1,183 @@
+package log
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogWriterUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen udp error: %+v", err)
+	}
+	defer conn.Close()
+
+	w := &SyslogWriter{
+		Network:  "udp",
+		Address:  conn.LocalAddr().String(),
+		Tag:      "myapp",
+		Hostname: "myhost",
+		Facility: 16,
+	}
+	defer w.Close()
+
+	logger := Logger{Writer: w}
+	logger.Error().Str("foo", "bar").Msg("hello syslog")
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("read udp error: %+v", err)
+	}
+
+	line := string(buf[:n])
+	if !strings.HasPrefix(line, "<131>") || !strings.Contains(line, " myhost myapp[") {
+		t.Errorf("syslog writer got unexpected rfc3164 header: %q", line)
+	}
+	if !strings.HasSuffix(line, `"message":"hello syslog"}`+"\n") {
+		t.Errorf("syslog writer got unexpected line: %q", line)
+	}
+}
+
+func TestSyslogWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen tcp error: %+v", err)
+	}
+	defer ln.Close()
+
+	lines := make(chan string, 8)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- line
+		}
+	}()
+
+	w := &SyslogWriter{
+		Network:  "tcp",
+		Address:  ln.Addr().String(),
+		Format:   SyslogRFC5424,
+		Tag:      "myapp",
+		Hostname: "myhost",
+	}
+	defer w.Close()
+
+	logger := Logger{Writer: w}
+	logger.Info().Msg("hello rfc5424")
+	logger.Warn().Msg("hello rfc5424")
+
+	for _, prefix := range []string{"<14>1 ", "<12>1 "} {
+		select {
+		case line := <-lines:
+			if !strings.HasPrefix(line, prefix) || !strings.Contains(line, " myhost myapp ") || !strings.Contains(line, " - - {") {
+				t.Errorf("syslog writer got unexpected rfc5424 line: %q", line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("syslog writer timed out")
+		}
+	}
+}
+
+func TestSyslogWriterReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen tcp error: %+v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	w := &SyslogWriter{
+		Network:    "tcp",
+		Address:    addr,
+		BufferSize: 2,
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte(`{"level":"info","message":"line 1"}` + "\n")); err == nil {
+		t.Errorf("syslog writer should return the dial error")
+	}
+	w.Write([]byte(`{"level":"info","message":"line 2"}` + "\n"))
+	w.Write([]byte(`{"level":"info","message":"line 3"}` + "\n"))
+	if n := w.Dropped(); n != 1 {
+		t.Errorf("syslog writer dropped %d lines, want 1", n)
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("listen tcp error: %+v", err)
+	}
+	defer ln.Close()
+
+	lines := make(chan string, 8)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- line
+		}
+	}()
+
+	// skip the backoff
+	w.mu.Lock()
+	w.retry = time.Time{}
+	w.mu.Unlock()
+
+	if _, err := w.Write([]byte(`{"level":"info","message":"line 4"}` + "\n")); err != nil {
+		t.Fatalf("syslog writer should reconnect: %+v", err)
+	}
+
+	for _, msg := range []string{"line 2", "line 3", "line 4"} {
+		select {
+		case line := <-lines:
+			if !strings.Contains(line, msg) {
+				t.Errorf("syslog writer got %q, want %s", line, msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("syslog writer timed out")
+		}
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	cases := []struct {
+		Level    Level
+		Severity int
+	}{
+		{TraceLevel, 7},
+		{DebugLevel, 7},
+		{InfoLevel, 6},
+		{WarnLevel, 4},
+		{ErrorLevel, 3},
+		{FatalLevel, 2},
+		{PanicLevel, 0},
+		{NoLevel, 6},
+	}
+
+	for _, c := range cases {
+		if s := syslogSeverity(c.Level); s != c.Severity {
+			t.Errorf("syslog severity of %s got %d, want %d", c.Level, s, c.Severity)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package log
+
+import (
+	"context"
+)
+
+// TraceExtractor extracts the ids of the active span from a context.Context, e.g.
+// an OpenTelemetry span.
+type TraceExtractor interface {
+	// ExtractTrace returns the trace id and span id of the active span in ctx, ok
+	// reports whether the span is valid and sampled.
+	ExtractTrace(ctx context.Context) (traceID [16]byte, spanID [8]byte, ok bool)
+}
+
+// TraceExtractorFunc is an adapter to allow the use of an ordinary function as a TraceExtractor.
+type TraceExtractorFunc func(ctx context.Context) (traceID [16]byte, spanID [8]byte, ok bool)
+
+// ExtractTrace calls f(ctx).
+func (f TraceExtractorFunc) ExtractTrace(ctx context.Context) (traceID [16]byte, spanID [8]byte, ok bool) {
+	return f(ctx)
+}
+
+// DefaultTraceExtractor is the TraceExtractor used by Event.Ctx, e.g. the
+// otellog.TraceExtractor of github.com/phuslu/log/otellog for OpenTelemetry.
+// Event.Ctx is a no-op in if nil.
+var DefaultTraceExtractor TraceExtractor
+
+// Ctx adds the trace id and span id of the active span in ctx as hex strings to the
+// event, it is a no-op if there is no valid and sampled span. The keys are the
+// TraceIDField and SpanIDField of the logger.
+func (e *Event) Ctx(ctx context.Context) *Event {
+	if e == nil || DefaultTraceExtractor == nil || ctx == nil {
+		return e
+	}
+	traceID, spanID, ok := DefaultTraceExtractor.ExtractTrace(ctx)
+	if !ok {
+		return e
+	}
+	traceKey, spanKey := "trace_id", "span_id"
+	switch {
+	case e.ecs:
+		traceKey, spanKey = "trace.id", "span.id"
+	case e.parent != nil:
+		if e.parent.TraceIDField != "" {
+			traceKey = e.parent.TraceIDField
+		}
+		if e.parent.SpanIDField != "" {
+			spanKey = e.parent.SpanIDField
+		}
+	}
+	return e.Hex(traceKey, traceID[:]).Hex(spanKey, spanID[:])
+}
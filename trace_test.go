@@ -0,0 +1,80 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+type testSpanKey struct{}
+
+type testSpan struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+func testExtractTrace(ctx context.Context) (traceID [16]byte, spanID [8]byte, ok bool) {
+	span, _ := ctx.Value(testSpanKey{}).(*testSpan)
+	if span == nil || !span.Sampled {
+		return
+	}
+	return span.TraceID, span.SpanID, true
+}
+
+func TestEventCtx(t *testing.T) {
+	defer func(x TraceExtractor) { DefaultTraceExtractor = x }(DefaultTraceExtractor)
+	DefaultTraceExtractor = TraceExtractorFunc(testExtractTrace)
+
+	span := &testSpan{
+		TraceID: [16]byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  [8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		Sampled: true,
+	}
+	ctx := context.WithValue(context.Background(), testSpanKey{}, span)
+
+	cases := []struct {
+		Logger Logger
+		Ctx    context.Context
+		Output string
+	}{
+		{Logger{}, ctx, `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","message":"hello"}`},
+		{Logger{TraceIDField: "trace", SpanIDField: "span"}, ctx, `"trace":"4bf92f3577b34da6a3ce929d0e0e4736","span":"00f067aa0ba902b7","message":"hello"}`},
+		{Logger{Schema: ECS}, ctx, `"trace.id":"4bf92f3577b34da6a3ce929d0e0e4736","span.id":"00f067aa0ba902b7","message":"hello"}`},
+		{Logger{}, context.WithValue(ctx, testSpanKey{}, &testSpan{TraceID: span.TraceID}), `"level":"info","message":"hello"}`},
+		{Logger{}, context.Background(), `"level":"info","message":"hello"}`},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		c.Logger.Writer = &buf
+		c.Logger.Info().Ctx(c.Ctx).Msg("hello")
+		if s := buf.String(); !strings.HasSuffix(s, c.Output+"\n") {
+			t.Errorf("event ctx output %s does not end with %s", s, c.Output)
+		}
+	}
+
+	logger := Logger{Writer: ioutil.Discard}
+	base := allocsPerRun(100, func() {
+		logger.Info().Msg("hello")
+	})
+	if n := allocsPerRun(100, func() {
+		logger.Info().Ctx(context.Background()).Msg("hello")
+	}); n > base {
+		t.Errorf("event ctx without span allocates %v times, want %v", n, base)
+	}
+}
+
+func TestEventCtxNoExtractor(t *testing.T) {
+	defer func(x TraceExtractor) { DefaultTraceExtractor = x }(DefaultTraceExtractor)
+	DefaultTraceExtractor = nil
+
+	var buf bytes.Buffer
+	logger := Logger{Writer: &buf}
+	logger.Info().Ctx(context.Background()).Msg("hello")
+	if s := buf.String(); !strings.HasSuffix(s, `"level":"info","message":"hello"}`+"\n") {
+		t.Errorf("event ctx without extractor got %s", s)
+	}
+}